@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/leavengood/donation_tracker/paypal"
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// payPalAccount is one of the PayPal accounts being tracked, with the source
+// of its transactions.
+type payPalAccount struct {
+	*paypal.Config
+	source paypal.TransactionSource
+}
+
+// newPayPalAccounts makes the accounts from the config, only including the
+// named one if a name is given.
+func newPayPalAccounts(configs []*paypal.Config, only string) ([]*payPalAccount, error) {
+	result := []*payPalAccount{}
+
+	for _, c := range configs {
+		if only != "" && c.Name != only {
+			continue
+		}
+
+		source := paypal.NewTransactionSource(c)
+		if c.FetchDetails {
+			cache, err := paypal.LoadDetailsCache(c.Name)
+			if err != nil {
+				return nil, fmt.Errorf("could not load the transaction details cache: %w", err)
+			}
+			source = paypal.WithDetails(source, cache)
+		}
+		result = append(result, &payPalAccount{Config: c, source: source})
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("there is no PayPal account named %q in %s", only, ConfigFile)
+	}
+
+	return result, nil
+}
+
+// accountName is how an account is shown
+func accountName(name string) string {
+	if name == "" {
+		return "default"
+	}
+
+	return name
+}
+
+// wrapAccountError adds the name of the account to errors when there are
+// several accounts.
+func wrapAccountError(name string, err error) error {
+	if name == "" || err == nil {
+		return err
+	}
+
+	return fmt.Errorf("PayPal account %s: %w", name, err)
+}
+
+// loadYear loads the files of every account for the year, returning the file
+// manager of each account and one with all of them combined.
+func loadYear(accounts []*payPalAccount, year int) (*paypal.FileManager, []*paypal.FileManager, error) {
+	fms := make([]*paypal.FileManager, 0, len(accounts))
+
+	for _, acct := range accounts {
+		fm, err := paypal.NewFileManager(acct.Name, year)
+		if err != nil {
+			return nil, nil, wrapAccountError(acct.Name,
+				fmt.Errorf("could not load PayPal files for year %d: %w", year, err))
+		}
+		fms = append(fms, fm)
+	}
+
+	return paypal.CombineFileManagers(year, fms...), fms, nil
+}
+
+// printAccountBreakdown prints the donation totals of each account.
+func printAccountBreakdown(year int, eurToUsdRate float32, fms []*paypal.FileManager) {
+	fmt.Printf("%s\n\n", util.Colorize(util.Green, fmt.Sprintf("Totals by PayPal Account for %d", year)))
+
+	for _, fm := range fms {
+		all := paypal.Transactions{}
+		for _, month := range fm.GetExistingMonths() {
+			all = append(all, fm.Months[month]...)
+		}
+		all.LinkReturns()
+		all.LinkConversions()
+		counted, _ := all.WithoutVoided(all)
+		donations, _ := counted.FilterDonations()
+
+		total := donations.Summarize().Total()
+		grossTotal := total.GrossTotal()
+
+		fmt.Printf("%s: %s\n", util.Colorize(util.Yellow, accountName(fm.Account)), total)
+		fmt.Printf("    Combined Total: %s, Grand Total: %.02f\n\n", grossTotal, grossTotal.GrandTotal(eurToUsdRate))
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leavengood/donation_tracker/paypal"
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// snapshotDateFormat is used for the -from and -to flags of reconcile
+const snapshotDateFormat = "2006-01-02"
+
+// saveBalanceSnapshot gets the current balance of the PayPal account and adds
+// it to its balance history.
+func saveBalanceSnapshot(ctx context.Context, account string, src paypal.BalanceSource) error {
+	history, err := paypal.LoadBalanceHistory(account)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := src.GetBalance(ctx)
+	if err != nil {
+		return err
+	}
+	history.Add(snapshot)
+	if err := history.Save(); err != nil {
+		return fmt.Errorf("could not save the balance snapshot: %w", err)
+	}
+
+	fmt.Printf("The balance of the %s PayPal account on %s is %s\n",
+		accountName(account), util.FormatDateTime(snapshot.Time), snapshot.Balances)
+
+	return nil
+}
+
+// findSnapshots returns the snapshots for the from and to dates, defaulting
+// to the last two.
+func findSnapshots(history *paypal.BalanceHistory, fromDate, toDate string) (*paypal.BalanceSnapshot, *paypal.BalanceSnapshot, error) {
+	snapshots := history.Snapshots
+	if len(snapshots) < 2 {
+		return nil, nil, errors.New("at least two balance snapshots are needed, run the balance command to take one")
+	}
+
+	find := func(date string, fallback *paypal.BalanceSnapshot) (*paypal.BalanceSnapshot, error) {
+		if date == "" {
+			return fallback, nil
+		}
+		d, err := time.Parse(snapshotDateFormat, date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q, it should be like %s: %w", date, snapshotDateFormat, err)
+		}
+		snapshot := history.OnDate(d)
+		if snapshot == nil {
+			return nil, fmt.Errorf("there is no balance snapshot for %s", date)
+		}
+		return snapshot, nil
+	}
+
+	from, err := find(fromDate, snapshots[len(snapshots)-2])
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := find(toDate, snapshots[len(snapshots)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	if !from.Time.Before(to.Time) {
+		return nil, nil, errors.New("the from snapshot must be before the to snapshot")
+	}
+
+	return from, to, nil
+}
+
+// reconcile compares the change in balance of the PayPal account between two
+// snapshots with its stored transactions, and returns false if there is a gap.
+func reconcile(account *payPalAccount, fromDate, toDate string) (bool, error) {
+	history, err := paypal.LoadBalanceHistory(account.Name)
+	if err != nil {
+		return false, err
+	}
+	from, to, err := findSnapshots(history, fromDate, toDate)
+	if err != nil {
+		return false, err
+	}
+
+	// The year the last month belongs to in the organization's time zone
+	year, _ := util.MonthOf(to.Time)
+	txns, err := loadTransactionsThrough([]*payPalAccount{account}, year)
+	if err != nil {
+		return false, err
+	}
+	r := paypal.Reconcile(from, to, txns)
+
+	fmt.Printf("Reconciling %d transactions of the %s PayPal account from %s to %s\n\n", r.Count, accountName(account.Name),
+		util.FormatDateTime(from.Time), util.FormatDateTime(to.Time))
+	for _, currency := range r.Currencies() {
+		fmt.Printf("  %s: balance %0.02f -> %0.02f, change %0.02f, transactions %0.02f",
+			currency, from.Balances[currency], to.Balances[currency], r.Change(currency), r.Net[currency])
+		if gap := r.Gap(currency); gap != 0 {
+			fmt.Printf(" %s\n", util.Colorize(util.Red, fmt.Sprintf("GAP OF %0.02f", gap)))
+		} else {
+			fmt.Printf(" %s\n", util.Colorize(util.Green, "✓"))
+		}
+	}
+
+	return r.Balanced(), nil
+}
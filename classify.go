@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/leavengood/donation_tracker/paypal"
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// printClassification lists the saved transactions of the year, or of the
+// month when one is given, with the category and rule each one matched,
+// followed by how many are in each category.
+func printClassification(accounts []*payPalAccount, year, month int, monthGiven bool) error {
+	fm, _, err := loadYear(accounts, year)
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	for _, m := range fm.GetExistingMonths() {
+		if monthGiven && m != month {
+			continue
+		}
+		fmt.Printf("%s\n", util.Colorize(util.Blue, fmt.Sprintf("%s %d", time.Month(m), year)))
+		for _, t := range fm.Months[m] {
+			// Subscription changes are not money
+			if t.IsProfileEvent() {
+				continue
+			}
+			category, rule := paypal.Classify(t)
+			counts[category]++
+			fmt.Printf("  %s %-20s %s\n", util.Colorize(util.Yellow, fmt.Sprintf("%-12s", category)), paypal.RuleName(rule), t)
+		}
+		fmt.Println("")
+	}
+
+	names := make([]string, 0, len(counts))
+	for category := range counts {
+		names = append(names, category)
+	}
+	sort.Strings(names)
+	for _, category := range names {
+		fmt.Printf("%s: %d transactions\n", category, counts[category])
+	}
+
+	return nil
+}
@@ -3,18 +3,48 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"regexp"
 	"strings"
+	"time"
+	// So time zones work without the system time zone database
+	_ "time/tzdata"
 
+	"github.com/leavengood/donation_tracker/opencollective"
 	"github.com/leavengood/donation_tracker/paypal"
+	"github.com/leavengood/donation_tracker/stripe"
 )
 
 // Config is the main configuration for the whole program
 type Config struct {
-	PayPal *paypal.Config `json:"paypal"`
+	PayPal *paypal.Config `json:"paypal,omitempty"`
+	// Several named PayPal accounts, instead of the one above
+	PayPalAccounts []*paypal.Config `json:"paypal_accounts,omitempty"`
+
+	// Decide which PayPal transactions are donations, subscriptions, sales,
+	// expenses or transfers, before the default rules
+	ClassificationRules paypal.Rules `json:"classification_rules,omitempty"`
+
+	// Card donations through Stripe, which are optional
+	Stripe *stripe.Config `json:"stripe,omitempty"`
+	// Open Collective contributions can be imported from the transaction
+	// export without this, which is only for getting them from the API
+	OpenCollective *opencollective.Config `json:"open_collective,omitempty"`
 
 	// For getting the EUR to USD conversion rate
 	FixerIoAccessKey string `json:"fixer_io_access_key"`
+	// Only needed to use something besides fixer.io, like the fake-paypal
+	// server
+	FixerIoUrl string `json:"fixer_io_url,omitempty"`
+
+	// Where PayPal transactions are saved, either "json" for a file for each
+	// month or "bolt" for one database file. The default is "json".
+	Storage string `json:"storage,omitempty"`
+
+	// The time zone the books are kept in, like "America/New_York", which
+	// decides which month a transaction is in. The default is UTC.
+	TimeZone string `json:"time_zone,omitempty"`
 
 	// For updating the donations.json file on cdn.haiku-os.org
 	Minio struct {
@@ -23,20 +53,104 @@ type Config struct {
 	} `json:"minio"`
 }
 
+// accountNameRegexp is what account names can be, since they are used as
+// directory names
+var accountNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// validatePayPal returns the problems with the config for one PayPal account.
+func validatePayPal(c *paypal.Config) []string {
+	errorList := []string{}
+
+	switch c.API {
+	case "", paypal.APINvp:
+		if c.Endpoint == "" {
+			errorList = append(errorList, "no PayPal endpoint was provided")
+		}
+		if c.User == "" {
+			errorList = append(errorList, "no PayPal user was provided")
+		}
+		if c.Password == "" {
+			errorList = append(errorList, "no PayPal password was provided")
+		}
+		if c.Signature == "" {
+			errorList = append(errorList, "no PayPal signature was provided")
+		}
+	case paypal.APIRest:
+		if c.RestEndpoint == "" {
+			errorList = append(errorList, "no PayPal REST endpoint was provided")
+		}
+		if c.ClientID == "" {
+			errorList = append(errorList, "no PayPal client ID was provided")
+		}
+		if c.Secret == "" {
+			errorList = append(errorList, "no PayPal secret was provided")
+		}
+	default:
+		errorList = append(errorList, fmt.Sprintf("unknown PayPal API %q, it should be %q or %q",
+			c.API, paypal.APINvp, paypal.APIRest))
+	}
+
+	if c.Name != "" {
+		for i, e := range errorList {
+			errorList[i] = fmt.Sprintf("%s for account %s", e, c.Name)
+		}
+	}
+
+	return errorList
+}
+
+// Accounts returns the config of every PayPal account.
+func (c *Config) Accounts() []*paypal.Config {
+	if len(c.PayPalAccounts) > 0 {
+		return c.PayPalAccounts
+	}
+
+	return []*paypal.Config{c.PayPal}
+}
+
 func (c *Config) Validate() error {
 	errorList := []string{}
 
-	if c.PayPal.Endpoint == "" {
-		errorList = append(errorList, "no PayPal endpoint was provided")
+	switch {
+	case c.PayPal != nil && len(c.PayPalAccounts) > 0:
+		errorList = append(errorList, "only one of paypal or paypal_accounts can be provided")
+	case c.PayPal != nil:
+		errorList = append(errorList, validatePayPal(c.PayPal)...)
+	case len(c.PayPalAccounts) > 0:
+		names := map[string]bool{}
+		for _, account := range c.PayPalAccounts {
+			if !accountNameRegexp.MatchString(account.Name) {
+				errorList = append(errorList, fmt.Sprintf("invalid PayPal account name %q, it should only have "+
+					"letters, numbers, dashes and underscores", account.Name))
+			}
+			if names[account.Name] {
+				errorList = append(errorList, fmt.Sprintf("there is more than one PayPal account named %q", account.Name))
+			}
+			names[account.Name] = true
+			errorList = append(errorList, validatePayPal(account)...)
+		}
+	default:
+		errorList = append(errorList, "no PayPal config was provided")
 	}
-	if c.PayPal.User == "" {
-		errorList = append(errorList, "no PayPal user was provided")
+
+	errorList = append(errorList, c.ClassificationRules.Validate()...)
+
+	if c.Stripe != nil && c.Stripe.SecretKey == "" {
+		errorList = append(errorList, "no Stripe secret key was provided")
 	}
-	if c.PayPal.Password == "" {
-		errorList = append(errorList, "no PayPal password was provided")
+	if c.OpenCollective != nil && c.OpenCollective.Slug == "" {
+		errorList = append(errorList, "no Open Collective slug was provided")
 	}
-	if c.PayPal.Signature == "" {
-		errorList = append(errorList, "no PayPal signature was provided")
+
+	switch c.Storage {
+	case "", paypal.StorageJSON, paypal.StorageBolt:
+	default:
+		errorList = append(errorList, fmt.Sprintf("unknown storage %q, it should be %q or %q",
+			c.Storage, paypal.StorageJSON, paypal.StorageBolt))
+	}
+
+	if _, err := time.LoadLocation(c.TimeZone); err != nil {
+		errorList = append(errorList, fmt.Sprintf("unknown time zone %q", c.TimeZone))
 	}
 
 	if c.FixerIoAccessKey == "" {
@@ -57,6 +171,17 @@ func (c *Config) Validate() error {
 	return errors.New(strings.Join(errorList, ", "))
 }
 
+// Location returns the time zone from the config. It should have been
+// validated already.
+func (c *Config) Location() *time.Location {
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
 const ConfigFile = "config.json"
 
 var config Config
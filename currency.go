@@ -2,8 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 )
 
 const FixerIoUrl = "http://data.fixer.io/api/latest?format=1&symbols=USD&access_key="
@@ -32,3 +34,25 @@ func GetExchangeRate(accessKey string) (float32, error) {
 	}
 	return fir.Rates["USD"], nil
 }
+
+// GetUsdRate returns how many USD one of the currency is worth. fixer.io only
+// gives rates from EUR, so the rates of both USD and the currency are asked
+// for and divided.
+func GetUsdRate(accessKey string, currency string) (float32, error) {
+	url := strings.Replace(exchangeRateUrl, "symbols=USD", "symbols=USD,"+currency, 1)
+	resp, err := http.Get(url + accessKey)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	fir := new(FixerIoResponse)
+	if err := json.NewDecoder(resp.Body).Decode(fir); err != nil {
+		return 0, err
+	}
+	if fir.Rates["USD"] == 0 || fir.Rates[currency] == 0 {
+		return 0, fmt.Errorf("fixer.io has no rate for %s", currency)
+	}
+
+	return fir.Rates["USD"] / fir.Rates[currency], nil
+}
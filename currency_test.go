@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -24,3 +25,30 @@ func TestGetExchangeRate(t *testing.T) {
 		t.Errorf("Expected: %v, but got: %v", expected, rate)
 	}
 }
+
+func TestGetUsdRate(t *testing.T) {
+	symbols := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		symbols = r.URL.Query().Get("symbols")
+		fmt.Fprint(w, `{"base":"EUR","date":"2014-09-27","rates":{"USD":1.25,"GBP":0.8}}`)
+	}))
+	defer ts.Close()
+
+	exchangeRateUrl = ts.URL + "?format=1&symbols=USD&access_key="
+
+	rate, err := GetUsdRate("fake-access-key", "GBP")
+	if err != nil {
+		t.Errorf("Received unexpected error: %v", err)
+	}
+	if symbols != "USD,GBP" {
+		t.Errorf("Expected the USD and GBP rates to be asked for, but got: %v", symbols)
+	}
+	if rate != 1.5625 {
+		t.Errorf("Expected: %v, but got: %v", 1.5625, rate)
+	}
+
+	_, err = GetUsdRate("fake-access-key", "CAD")
+	if err == nil || !strings.Contains(err.Error(), "no rate for CAD") {
+		t.Errorf("Expected an error for the missing rate, but got: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/leavengood/donation_tracker/fakepaypal"
+)
+
+// runFakePayPal runs a fake PayPal NVP API server until it fails. It does not
+// need a config file.
+func runFakePayPal(args []string) error {
+	flagSet := flag.NewFlagSet("fake-paypal", flag.ExitOnError)
+	addr := flagSet.String("addr", "localhost:8081", "The address to listen on")
+	dataFile := flagSet.String("data", "", "A JSON data set to serve, instead of generating one")
+	saveFile := flagSet.String("save", "", "Save the generated data set to this JSON file")
+	seed := flagSet.Int64("seed", 1, "The random seed used to generate the data set")
+	perDay := flagSet.Float64("per-day", 3, "How many donations to generate per day on average")
+	maxResults := flagSet.Int("max-results", 0, "The most results a TransactionSearch returns before it is truncated")
+	errorCode := flagSet.String("error-code", "", "Make every call fail with this error code, like 10002 for bad credentials")
+	failEvery := flagSet.Int("fail-every", 0, "Make every Nth call fail with a temporary error")
+	flagSet.Parse(args)
+
+	var dataset *fakepaypal.Dataset
+	if *dataFile != "" {
+		var err error
+		dataset, err = fakepaypal.LoadDataset(*dataFile)
+		if err != nil {
+			return err
+		}
+	} else {
+		end := time.Now().UTC()
+		start := time.Date(end.Year()-1, time.January, 1, 0, 0, 0, 0, time.UTC)
+		dataset = fakepaypal.Generate(*seed, start, end, *perDay)
+	}
+	if *saveFile != "" {
+		if err := dataset.Save(*saveFile); err != nil {
+			return err
+		}
+	}
+
+	server := fakepaypal.NewServer(dataset)
+	if *maxResults > 0 {
+		server.MaxResults = *maxResults
+	}
+	server.ErrorCode = *errorCode
+	server.FailEvery = *failEvery
+
+	fmt.Printf("Serving %d fake PayPal transactions at http://%s\n", len(dataset.Transactions), *addr)
+	fmt.Printf("Set the PayPal \"endpoint\" in %s to http://%s and \"fixer_io_url\" to http://%s%s\n",
+		ConfigFile, *addr, *addr, fakepaypal.FixerPath)
+
+	return http.ListenAndServe(*addr, server)
+}
@@ -0,0 +1,270 @@
+package fakepaypal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/leavengood/donation_tracker/paypal"
+)
+
+// Dataset is the synthetic data served by the fake server.
+type Dataset struct {
+	Transactions paypal.Transactions `json:"transactions"`
+}
+
+// LoadDataset loads a data set saved as JSON, in the same format as the
+// PayPal files in the data directory.
+func LoadDataset(filename string) (*Dataset, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dataset := &Dataset{}
+	if err := json.NewDecoder(f).Decode(dataset); err != nil {
+		return nil, fmt.Errorf("could not load data set %s: %w", filename, err)
+	}
+	dataset.Transactions.Sort()
+
+	return dataset, nil
+}
+
+// Save writes the data set as JSON, so it can be edited and loaded again.
+func (d *Dataset) Save(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// Between returns the transactions from start to end inclusive, oldest first.
+func (d *Dataset) Between(start, end time.Time) paypal.Transactions {
+	result := paypal.Transactions{}
+	for _, t := range d.Transactions {
+		if !t.Timestamp.Before(start) && !t.Timestamp.After(end) {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
+// Find returns the transaction with the given ID, or nil.
+func (d *Dataset) Find(transactionID string) *paypal.Transaction {
+	for _, t := range d.Transactions {
+		if t.TransactionID == transactionID {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// Some made up donors
+var donors = []struct {
+	name, email, country string
+}{
+	{"Bruce Wayne", "bruce@wayneenterprises.com", "US"},
+	{"Selina Kyle", "cat@woman.com", "US"},
+	{"Clark Kent", "clarkkent@gmail.com", "US"},
+	{"Diana Prince", "diana@themyscira.gr", "GR"},
+	{"Hans Müller", "hans.mueller@example.de", "DE"},
+	{"Marie Dubois", "marie.dubois@example.fr", "FR"},
+	{"Anonymous Person", "anonymous_person@gmail.com", "NL"},
+	{"Kenji Sato", "kenji@example.jp", "JP"},
+}
+
+var amounts = []float32{5, 10, 10, 20, 25, 50, 100, 250}
+
+var notes = []string{"", "", "", "Keep up the good work!", "Thanks for R1!", "For the BeOS memories"}
+
+// generator builds a data set with repeatable random data
+type generator struct {
+	rng     *rand.Rand
+	dataset *Dataset
+	nextID  int
+}
+
+func (g *generator) id() string {
+	g.nextID++
+	return fmt.Sprintf("FAKE%013d", g.nextID)
+}
+
+func (g *generator) add(t *paypal.Transaction) *paypal.Transaction {
+	t.NetAmt = cents(float64(t.Amt + t.FeeAmt))
+	t.Receiver = "donations@haiku-inc.org"
+	g.dataset.Transactions = append(g.dataset.Transactions, t)
+	return t
+}
+
+// cents rounds an amount to whole cents, like PayPal does
+func cents(amt float64) float32 {
+	return float32(math.Round(amt*100) / 100)
+}
+
+func fee(amt float32) float32 {
+	return -cents(float64(amt)*0.029 + 0.30)
+}
+
+func (g *generator) currency() string {
+	if g.rng.Intn(3) == 0 {
+		return "EUR"
+	}
+	return "USD"
+}
+
+// conversionRate is what PayPal gives for EUR, a bit less than FixerRate
+const conversionRate = 1.07
+
+// convert adds the two sides of PayPal converting the net amount of a donation
+// to USD.
+func (g *generator) convert(donation *paypal.Transaction) {
+	usd := cents(float64(donation.NetAmt) * conversionRate)
+	for _, side := range []struct {
+		amt      float32
+		currency string
+	}{{-donation.NetAmt, "EUR"}, {usd, "USD"}} {
+		g.add(&paypal.Transaction{
+			Timestamp:     donation.Timestamp,
+			Type:          "Currency Conversion",
+			Name:          "From Euro",
+			TransactionID: g.id(),
+			Status:        "Completed",
+			Amt:           side.amt,
+			CurrencyCode:  side.currency,
+		})
+	}
+}
+
+// Generate makes a data set from start to end with about perDay donations
+// each day, plus some subscriptions with their monthly payments and
+// cancellations, conversions of EUR donations to USD and the occasional
+// refund. The same seed always makes the same data set.
+func Generate(seed int64, start, end time.Time, perDay float64) *Dataset {
+	g := &generator{
+		rng:     rand.New(rand.NewSource(seed)),
+		dataset: &Dataset{},
+	}
+
+	// One time donations
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		count := int(perDay)
+		if g.rng.Float64() < perDay-float64(count) {
+			count++
+		}
+		for i := 0; i < count; i++ {
+			ts := day.Add(time.Duration(g.rng.Int63n(int64(24 * time.Hour)))).Truncate(time.Second)
+			if ts.After(end) {
+				continue
+			}
+			donor := donors[g.rng.Intn(len(donors))]
+			amt := amounts[g.rng.Intn(len(amounts))]
+			donation := g.add(&paypal.Transaction{
+				Timestamp:     ts,
+				Type:          "Donation",
+				Email:         donor.email,
+				Name:          donor.name,
+				TransactionID: g.id(),
+				Status:        "Completed",
+				Amt:           amt,
+				FeeAmt:        fee(amt),
+				CurrencyCode:  g.currency(),
+				Note:          notes[g.rng.Intn(len(notes))],
+				CountryCode:   donor.country,
+				ItemName:      "Donation to Haiku, Inc.",
+			})
+
+			// Some EUR donations are converted to USD straight away
+			if donation.CurrencyCode == "EUR" && g.rng.Intn(2) == 0 {
+				g.convert(donation)
+			}
+
+			// Some donations get refunded a few days later
+			if g.rng.Intn(50) == 0 {
+				refundTs := ts.AddDate(0, 0, 1+g.rng.Intn(5))
+				if refundTs.Before(end) {
+					g.add(&paypal.Transaction{
+						Timestamp:     refundTs,
+						Type:          "Refund",
+						Email:         donor.email,
+						Name:          donor.name,
+						TransactionID: g.id(),
+						Status:        "Completed",
+						Amt:           -amt,
+						FeeAmt:        -fee(amt) - 0.30,
+						CurrencyCode:  donation.CurrencyCode,
+
+						ParentTransactionID: donation.TransactionID,
+					})
+				}
+			}
+		}
+	}
+
+	// Subscriptions
+	for i, donor := range donors[:4] {
+		profileID := fmt.Sprintf("I-FAKE%08d", i+1)
+		created := start.AddDate(0, 0, g.rng.Intn(28)).Add(time.Duration(g.rng.Intn(86400)) * time.Second)
+		amt := amounts[g.rng.Intn(4)]
+		currency := g.currency()
+		if !created.Before(end) {
+			continue
+		}
+		subscription := func(ts time.Time, status string) {
+			g.add(&paypal.Transaction{
+				Timestamp:     ts,
+				Type:          "Recurring Payment",
+				Email:         donor.email,
+				Name:          donor.name,
+				TransactionID: profileID,
+				Status:        status,
+				CurrencyCode:  currency,
+			})
+		}
+		subscription(created, "Created")
+
+		// Every other subscription is cancelled after a while
+		cancelAfter := -1
+		if i%2 == 1 {
+			cancelAfter = 2 + g.rng.Intn(6)
+		}
+		for month := 0; ; month++ {
+			ts := created.AddDate(0, month, 0)
+			if !ts.Before(end) {
+				break
+			}
+			if month == cancelAfter {
+				subscription(ts.Add(-time.Hour), "Canceled")
+				break
+			}
+			g.add(&paypal.Transaction{
+				Timestamp:     ts,
+				Type:          "Recurring Payment",
+				Email:         donor.email,
+				Name:          donor.name,
+				TransactionID: g.id(),
+				Status:        "Completed",
+				Amt:           amt,
+				FeeAmt:        fee(amt),
+				CurrencyCode:  currency,
+				CountryCode:   donor.country,
+				ItemName:      "Monthly donation to Haiku, Inc.",
+				ProfileID:     profileID,
+			})
+		}
+	}
+
+	g.dataset.Transactions.Sort()
+
+	return g.dataset
+}
@@ -0,0 +1,263 @@
+// Package fakepaypal is a fake PayPal NVP API server for local development.
+// It serves TransactionSearch, GetTransactionDetails and GetBalance calls from
+// a synthetic data set, including truncated results and error ACKs, so the
+// whole update process can be run without touching the real PayPal account.
+package fakepaypal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leavengood/donation_tracker/paypal"
+)
+
+// FixerPath serves a fixed EUR to USD rate in the same format as fixer.io
+const FixerPath = "/fixer/latest"
+
+// FixerRate is the rate served at FixerPath
+const FixerRate = 1.1
+
+// Server answers NVP API calls from a Dataset.
+type Server struct {
+	Dataset *Dataset
+
+	// The most results a TransactionSearch returns, defaulting to
+	// paypal.MaxSearchResults
+	MaxResults int
+	// If set, every call fails with this error code
+	ErrorCode string
+	// If more than zero, every call with this number fails with the
+	// temporarily unavailable error code, to exercise retries
+	FailEvery int
+
+	mu    sync.Mutex
+	calls int
+}
+
+func NewServer(dataset *Dataset) *Server {
+	return &Server{
+		Dataset:    dataset,
+		MaxResults: paypal.MaxSearchResults,
+	}
+}
+
+// Calls returns how many NVP calls have been made to the server.
+func (s *Server) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == FixerPath {
+		fmt.Fprintf(w, `{"success":true,"base":"EUR","date":"%s","rates":{"USD":%v}}`,
+			time.Now().UTC().Format("2006-01-02"), FixerRate)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "NVP calls must be a POST", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := paypal.DecodeNvp(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.calls++
+	call := s.calls
+	s.mu.Unlock()
+
+	method := req.Fields[paypal.MethodKey]
+	fmt.Printf("[fake-paypal] %s %s\n", method, paypal.EncodeNvp(paramsOf(req.Fields)))
+
+	var result *paypal.NvpResult
+	switch {
+	case s.ErrorCode != "":
+		result = failure(s.ErrorCode, "Forced error", "This error was requested when starting the fake server.")
+	case s.FailEvery > 0 && call%s.FailEvery == 0:
+		result = failure(paypal.ErrorCodeUnavailable, "Temporarily unavailable",
+			"This API is temporarily unavailable. Please try later.")
+	default:
+		switch method {
+		case "TransactionSearch":
+			result = s.transactionSearch(req.Fields)
+		case "GetTransactionDetails":
+			result = s.transactionDetails(req.Fields)
+		case "GetBalance":
+			result = s.balance()
+		default:
+			result = failure("81002", "Unspecified Method", "Method Specified is not Supported")
+		}
+	}
+
+	result.Fields["TIMESTAMP"] = time.Now().UTC().Format(paypal.PayPalDateFormat)
+	result.Fields["CORRELATIONID"] = strconv.FormatInt(rand.Int63(), 16)
+	result.Fields["VERSION"] = req.Fields[paypal.VersionKey]
+	result.Fields["BUILD"] = "fake"
+
+	fmt.Fprint(w, result.Encode())
+}
+
+// paramsOf returns the request fields without the credentials
+func paramsOf(fields paypal.NameValues) paypal.NameValues {
+	params := paypal.NameValues{}
+	for name, value := range fields {
+		switch name {
+		case paypal.UserKey, paypal.PasswordKey, paypal.SignatureKey:
+		default:
+			params[name] = value
+		}
+	}
+
+	return params
+}
+
+func newResult(ack string) *paypal.NvpResult {
+	return &paypal.NvpResult{
+		Ack:    ack,
+		Fields: paypal.NameValues{},
+		List:   map[int]paypal.NameValues{},
+	}
+}
+
+func failure(code, short, long string) *paypal.NvpResult {
+	result := newResult("Failure")
+	result.Errors = []paypal.ErrorDetail{{
+		Code:         code,
+		ShortMessage: short,
+		LongMessage:  long,
+		SeverityCode: "Error",
+	}}
+
+	return result
+}
+
+func (s *Server) transactionSearch(fields paypal.NameValues) *paypal.NvpResult {
+	start, err := time.Parse(paypal.PayPalDateFormat, fields["STARTDATE"])
+	if err != nil {
+		return failure("10004", "Invalid argument", "The STARTDATE is not valid.")
+	}
+	end := time.Now().UTC()
+	if fields["ENDDATE"] != "" {
+		end, err = time.Parse(paypal.PayPalDateFormat, fields["ENDDATE"])
+		if err != nil {
+			return failure("10004", "Invalid argument", "The ENDDATE is not valid.")
+		}
+	}
+
+	found := s.Dataset.Between(start, end)
+
+	result := newResult("Success")
+	max := s.MaxResults
+	if max <= 0 {
+		max = paypal.MaxSearchResults
+	}
+	if len(found) > max {
+		// Like PayPal, only the newest results are returned
+		found = found[len(found)-max:]
+		result.Ack = "SuccessWithWarning"
+		result.Errors = []paypal.ErrorDetail{{
+			Code:         paypal.ErrorCodeSearchLimit,
+			ShortMessage: "Search warning",
+			LongMessage:  "The number of results were truncated. Add additional criteria to your search.",
+			SeverityCode: "Warning",
+		}}
+	}
+
+	// Newest first
+	for i := range found {
+		t := found[len(found)-1-i]
+		result.List[i] = paypal.NameValues{
+			"TIMESTAMP":     t.Timestamp.UTC().Format(paypal.PayPalDateFormat),
+			"TIMEZONE":      "GMT",
+			"TYPE":          t.Type,
+			"EMAIL":         t.Email,
+			"NAME":          t.Name,
+			"TRANSACTIONID": t.TransactionID,
+			"STATUS":        t.Status,
+			"AMT":           formatAmount(t.Amt),
+			"CURRENCYCODE":  t.CurrencyCode,
+			"FEEAMT":        formatAmount(t.FeeAmt),
+			"NETAMT":        formatAmount(t.NetAmt),
+		}
+	}
+
+	return result
+}
+
+func (s *Server) transactionDetails(fields paypal.NameValues) *paypal.NvpResult {
+	t := s.Dataset.Find(fields["TRANSACTIONID"])
+	if t == nil {
+		return failure("10004", "Transaction refused because of an invalid argument",
+			"The transaction id is not valid")
+	}
+
+	result := newResult("Success")
+	result.Fields["TRANSACTIONID"] = t.TransactionID
+	result.Fields["EMAIL"] = t.Email
+	result.Fields["RECEIVEREMAIL"] = t.Receiver
+	result.Fields["COUNTRYCODE"] = t.CountryCode
+	result.Fields["NOTE"] = t.Note
+	result.Fields["CUSTOM"] = t.Custom
+	result.Fields["INVNUM"] = t.InvoiceID
+	result.Fields["AMT"] = formatAmount(t.Amt)
+	result.Fields["FEEAMT"] = formatAmount(t.FeeAmt)
+	result.Fields["CURRENCYCODE"] = t.CurrencyCode
+	result.Fields["PAYMENTSTATUS"] = t.Status
+	result.Fields["ORDERTIME"] = t.Timestamp.UTC().Format(paypal.PayPalDateFormat)
+	if t.ProfileID != "" {
+		result.Fields["SUBSCRIPTIONID"] = t.ProfileID
+	}
+	if t.ParentTransactionID != "" {
+		result.Fields["PARENTTRANSACTIONID"] = t.ParentTransactionID
+	}
+	if t.ItemName != "" {
+		result.List[0] = paypal.NameValues{"NAME": t.ItemName, "QTY": "1"}
+	}
+
+	return result
+}
+
+// balance is the sum of all transactions up to now, in every currency
+func (s *Server) balance() *paypal.NvpResult {
+	balances := map[string]float64{}
+	for _, t := range s.Dataset.Between(time.Time{}, time.Now().UTC()) {
+		if t.Status != "Pending" {
+			balances[t.CurrencyCode] += float64(t.NetAmt)
+		}
+	}
+	currencies := make([]string, 0, len(balances))
+	for currency := range balances {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	result := newResult("Success")
+	for i, currency := range currencies {
+		result.List[i] = paypal.NameValues{
+			"AMT":          strconv.FormatFloat(balances[currency], 'f', 2, 64),
+			"CURRENCYCODE": currency,
+		}
+	}
+
+	return result
+}
+
+func formatAmount(amt float32) string {
+	return strconv.FormatFloat(float64(amt), 'f', 2, 32)
+}
@@ -0,0 +1,104 @@
+package fakepaypal
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/leavengood/donation_tracker/paypal"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(url string) *paypal.Client {
+	return paypal.NewClient(&paypal.Config{
+		Endpoint:          url,
+		User:              "fake",
+		Password:          "fake",
+		Signature:         "fake",
+		MaxRetries:        -1,
+		RequestsPerSecond: 1000,
+	})
+}
+
+func TestGenerateIsRepeatable(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 3, 0)
+
+	first := Generate(42, start, end, 2)
+	second := Generate(42, start, end, 2)
+
+	assert.True(t, len(first.Transactions) > 150)
+	assert.Equal(t, first, second)
+}
+
+func TestServerWithTruncatedSearches(t *testing.T) {
+	start := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0).Add(-time.Second)
+	dataset := Generate(1, start, end, 10)
+
+	server := NewServer(dataset)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	txns, err := newTestClient(ts.URL).GetTransactions(context.Background(),
+		start.Format(paypal.PayPalDateFormat), end.Format(paypal.PayPalDateFormat))
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(dataset.Between(start, end)), len(txns))
+	assert.True(t, server.Calls() > 1)
+	for _, txn := range txns {
+		expected := dataset.Find(txn.TransactionID)
+		if !assert.NotNil(t, expected, txn.TransactionID) {
+			continue
+		}
+		assert.Equal(t, expected.Amt, txn.Amt)
+		assert.Equal(t, expected.CurrencyCode, txn.CurrencyCode)
+		assert.True(t, expected.Timestamp.Equal(txn.Timestamp))
+	}
+}
+
+func TestServerTransactionDetails(t *testing.T) {
+	start := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	dataset := Generate(1, start, start.AddDate(0, 0, 7), 2)
+	donation := dataset.Transactions[0]
+
+	ts := httptest.NewServer(NewServer(dataset))
+	defer ts.Close()
+
+	details, err := newTestClient(ts.URL).GetTransactionDetails(context.Background(), donation.TransactionID)
+	assert.NoError(t, err)
+
+	txn := &paypal.Transaction{}
+	txn.ApplyDetails(details)
+	assert.Equal(t, donation.CountryCode, txn.CountryCode)
+	assert.Equal(t, donation.ItemName, txn.ItemName)
+	assert.Equal(t, "donations@haiku-inc.org", txn.Receiver)
+}
+
+func TestServerErrorCode(t *testing.T) {
+	server := NewServer(&Dataset{})
+	server.ErrorCode = paypal.ErrorCodeAuthentication
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	_, err := newTestClient(ts.URL).GetTransactions(context.Background(), "2020-03-01T00:00:00Z", "")
+
+	assert.True(t, paypal.IsAuthError(err))
+}
+
+func TestServerBalance(t *testing.T) {
+	dataset := &Dataset{Transactions: paypal.Transactions{
+		{Timestamp: time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC), NetAmt: 9.41, CurrencyCode: "USD"},
+		{Timestamp: time.Date(2020, time.March, 2, 0, 0, 0, 0, time.UTC), NetAmt: 48.25, CurrencyCode: "EUR"},
+		{Timestamp: time.Date(2020, time.March, 3, 0, 0, 0, 0, time.UTC), NetAmt: 23.97, CurrencyCode: "USD"},
+	}}
+	ts := httptest.NewServer(NewServer(dataset))
+	defer ts.Close()
+
+	snapshot, err := newTestClient(ts.URL).GetBalance(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, float32(33.38), snapshot.Balances["USD"])
+	assert.Equal(t, float32(48.25), snapshot.Balances["EUR"])
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/leavengood/donation_tracker/githubsponsors"
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// importGitHubSponsorsCSV merges the payments in GitHub Sponsors sponsor or
+// payout exports into the saved years. Payments which are already saved are
+// only replaced when their status changed, like when they were refunded.
+// Payout rows which are also in a sponsor export are left out, even when they
+// were saved before it was imported.
+func importGitHubSponsorsCSV(files []string) error {
+	imported := githubsponsors.Transactions{}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		txns, err := githubsponsors.ParseCSV(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("could not import %s: %w", name, err)
+		}
+		fmt.Printf("Read %d sponsorship payments from %s\n", len(txns), name)
+		imported = append(imported, txns...)
+	}
+	if len(imported) == 0 {
+		fmt.Println("There are no sponsorship payments to import")
+		return nil
+	}
+
+	// A payout can be in the year after the payment, so every year is matched
+	years, err := githubsponsors.SavedYears()
+	if err != nil {
+		return err
+	}
+	previous := map[int]githubsponsors.Transactions{}
+	saved := githubsponsors.Transactions{}
+	for _, year := range years {
+		txns, err := githubsponsors.LoadYear(year)
+		if err != nil {
+			return err
+		}
+		previous[year] = txns
+		saved = append(saved, txns...)
+	}
+
+	// Merge will remove any duplicates and update any whose status changed
+	merged := saved.Merge(imported)
+	all := merged.WithoutMatchedPayouts()
+	if matched := len(merged) - len(all); matched > 0 {
+		fmt.Printf("Left out %d payout rows which are also in a sponsor export\n", matched)
+	}
+
+	byYear := map[int]githubsponsors.Transactions{}
+	for year := range previous {
+		byYear[year] = githubsponsors.Transactions{}
+	}
+	for _, t := range all {
+		year, _ := util.MonthOf(t.Date)
+		byYear[year] = append(byYear[year], t)
+	}
+	years = years[:0]
+	for year := range byYear {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+
+	changed := false
+	for _, year := range years {
+		txns := byYear[year]
+		txns.Sort()
+
+		before := map[string]*githubsponsors.Transaction{}
+		for _, t := range previous[year] {
+			before[t.ID] = t
+		}
+		added, updated := 0, 0
+		for _, t := range txns {
+			if old, found := before[t.ID]; !found {
+				added++
+			} else if old.Status != t.Status {
+				updated++
+			}
+		}
+		removed := len(previous[year]) - (len(txns) - added)
+		if added == 0 && updated == 0 && removed == 0 {
+			continue
+		}
+		changed = true
+
+		fmt.Printf("GitHub Sponsors %d: %d new, %d updated and %d removed payments\n", year, added, updated, removed)
+		if err := githubsponsors.SaveYear(year, txns); err != nil {
+			return fmt.Errorf("could not save GitHub Sponsors payments for year %d: %w", year, err)
+		}
+	}
+	if !changed {
+		fmt.Println("All of the sponsorship payments were already saved")
+	}
+
+	return nil
+}
+
+// AddGitHubSponsors adds the GitHub sponsorships and refunds to the monthly
+// summaries and returns their total.
+func AddGitHubSponsors(year int, m util.MonthlySummaries, txns githubsponsors.Transactions) *util.Summary {
+	total := util.NewSummary()
+	if len(txns) == 0 {
+		return total
+	}
+
+	fmt.Printf("\n%s\n\n", util.Colorize(util.Green, "GitHub Sponsors Monthly Totals"))
+	sums := txns.Summarize()
+	for month := time.January; month <= time.December; month++ {
+		summary := sums[month]
+		if summary == nil {
+			continue
+		}
+		monthStr := util.Colorize(util.Blue, fmt.Sprintf("%s %d", month, year))
+		fmt.Printf("%s: There are %d GitHub sponsorship payments\n", monthStr, summary.SubscriptionCount)
+		fmt.Printf("    Sponsorships: %s\n", summary)
+		for _, t := range txns {
+			if _, tMonth := util.MonthOf(t.Date); tMonth == month && t.IsReturn() {
+				fmt.Printf("    Refunded: %s\n", util.Colorize(util.Red, t.String()))
+			}
+		}
+
+		m.ForMonth(month).Add(summary)
+		total.Add(summary)
+	}
+	fmt.Printf("Total for GitHub Sponsors: %s\n", total)
+
+	return total
+}
@@ -0,0 +1,182 @@
+package githubsponsors
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvColumns maps each field to the headers the sponsor and payout exports
+// have used for it, in lower case without any question mark, in order of
+// preference.
+var csvColumns = map[string][]string{
+	"id":       {"transaction id", "id"},
+	"date":     {"transaction date", "processed date", "payout date", "date"},
+	"status":   {"status"},
+	"amount":   {"processed amount", "amount", "tier monthly amount"},
+	"currency": {"currency"},
+	"login":    {"sponsor handle", "sponsor login", "sponsor", "login", "handle"},
+	"name":     {"sponsor profile name", "sponsor name", "name"},
+	"email":    {"sponsor public email", "sponsor email", "email"},
+	"public":   {"is public"},
+	"privacy":  {"privacy level", "visibility"},
+	"yearly":   {"is yearly"},
+	"tier":     {"tier name", "tier"},
+}
+
+var csvDateFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// csvStatuses maps the statuses in the exports to those used here. Any other
+// status, like pending, is kept as it is and not counted.
+var csvStatuses = map[string]string{
+	"":          StatusSettled,
+	"settled":   StatusSettled,
+	"succeeded": StatusSettled,
+	"paid":      StatusSettled,
+	"completed": StatusSettled,
+	"refunded":  StatusRefunded,
+}
+
+// ParseCSV parses a sponsor or payout export of GitHub Sponsors with a row
+// for each payment of a sponsorship. Rows without a sponsor, like the totals
+// of a payout, are skipped.
+func ParseCSV(r io.Reader) (Transactions, error) {
+	// Skip any byte order mark
+	br := bufio.NewReader(r)
+	if bom, _ := br.Peek(3); string(bom) == "\ufeff" {
+		br.Discard(3)
+	}
+
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the CSV: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("the CSV is empty")
+	}
+
+	headers := map[string]int{}
+	for i, h := range rows[0] {
+		headers[strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(h), "?")))] = i
+	}
+	columns := map[string]int{}
+	for field, names := range csvColumns {
+		for _, name := range names {
+			if i, found := headers[name]; found {
+				columns[field] = i
+				break
+			}
+		}
+	}
+	// A payout export is dated when GitHub paid the sponsorships out
+	payoutDate, found := headers["payout date"]
+	payout := found && columns["date"] == payoutDate
+
+	for _, field := range []string{"login", "date", "amount"} {
+		if _, found := columns[field]; !found {
+			return nil, fmt.Errorf("this does not look like a GitHub Sponsors export, there is no %s column", field)
+		}
+	}
+
+	result := make(Transactions, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		get := func(field string) string {
+			c, found := columns[field]
+			if !found || c >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[c])
+		}
+
+		if get("login") == "" {
+			continue
+		}
+		t := &Transaction{
+			ID:       get("id"),
+			Currency: strings.ToUpper(get("currency")),
+			Login:    get("login"),
+			Name:     get("name"),
+			Email:    get("email"),
+			Yearly:   isTrue(get("yearly")),
+			Tier:     get("tier"),
+			Payout:   payout,
+		}
+		if t.Currency == "" {
+			t.Currency = "USD"
+		}
+		status := strings.ToLower(get("status"))
+		if mapped, found := csvStatuses[status]; found {
+			status = mapped
+		}
+		t.Status = status
+		if _, found := columns["public"]; found {
+			t.Private = !isTrue(get("public"))
+		} else {
+			t.Private = strings.EqualFold(get("privacy"), "private")
+		}
+
+		parsed := false
+		for _, format := range csvDateFormats {
+			if ts, err := time.Parse(format, get("date")); err == nil {
+				t.Date = ts.UTC()
+				parsed = true
+				break
+			}
+		}
+		if !parsed {
+			return nil, fmt.Errorf("line %d: invalid date %q", i+2, get("date"))
+		}
+
+		amount, err := parseAmount(get("amount"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+2, err)
+		}
+		t.Amount = amount
+
+		// Older exports have no transaction ID, so make one which is the same
+		// each time the file is imported
+		if t.ID == "" {
+			t.ID = fmt.Sprintf("%s-%s-%.02f", t.Login, t.Date.Format(time.RFC3339), t.Amount)
+		}
+
+		result = append(result, t)
+	}
+	result.Sort()
+
+	return result, nil
+}
+
+func parseAmount(s string) (float32, error) {
+	cleaned := strings.NewReplacer("$", "", ",", "", "USD", "", " ", "").Replace(s)
+	if cleaned == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(cleaned, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	return float32(f), nil
+}
+
+func isTrue(s string) bool {
+	switch strings.ToLower(s) {
+	case "true", "yes", "1":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,146 @@
+package githubsponsors
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// ParseCSV
+//==============================================================================
+
+const sponsorsCSV = `Sponsor Handle,Sponsor Profile Name,Sponsor Public Email,Sponsorship Started On,Is Public?,Is Yearly?,Transaction ID,Tier Name,Tier Monthly Amount,Processed Amount,Is Prorated?,Status,Transaction Date,Metadata,Country,Region,VAT
+bwayne,Bruce Wayne,bruce@wayne.com,2020-01-05 10:00:00 +0000,true,false,ch_1,Supporter,$10.00,$10.00,false,settled,2020-03-05 10:00:00 +0000,,US,,
+dprince,Diana Prince,,2020-02-10 12:00:00 +0000,false,false,ch_2,Friend,"$1,000.00","$1,000.00",false,settled,2020-03-10 12:00:00 +0000,,GR,,
+bwayne,Bruce Wayne,bruce@wayne.com,2020-01-05 10:00:00 +0000,true,false,ch_3,Supporter,$10.00,$10.00,false,refunded,2020-04-05 10:00:00 +0000,,US,,
+ckent,Clark Kent,,2020-04-01 00:00:00 +0000,true,false,ch_4,Supporter,$10.00,$10.00,false,pending,2020-04-30 00:00:00 +0000,,US,,
+`
+
+const payoutCSV = `Payout Date,Sponsor,Amount,Status
+2020-05-01,bwayne,10.00,paid
+2020-05-01,pparker,5.00,paid
+2020-05-01,,15.00,paid
+`
+
+func TestParseCSV(t *testing.T) {
+	txns, err := ParseCSV(strings.NewReader(sponsorsCSV))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(txns))
+
+	first := txns[0]
+	assert.Equal(t, "ch_1", first.ID)
+	assert.Equal(t, "bwayne", first.Login)
+	assert.Equal(t, "bruce@wayne.com", first.Email)
+	assert.Equal(t, time.Date(2020, time.March, 5, 10, 0, 0, 0, time.UTC), first.Date)
+	assert.Equal(t, float32(10), first.Amount)
+	assert.Equal(t, "USD", first.Currency)
+	assert.False(t, first.Private)
+	assert.False(t, first.Payout)
+	assert.True(t, first.IsSubscription())
+
+	private := txns[1]
+	assert.True(t, private.Private)
+	assert.Equal(t, float32(1000), private.Amount)
+
+	refunded := txns[2]
+	assert.True(t, refunded.IsReturn())
+	assert.False(t, refunded.IsSubscription())
+	assert.Equal(t, float32(10), refunded.Amount)
+
+	pending := txns[3]
+	assert.False(t, pending.IsSubscription() || pending.IsReturn())
+}
+
+func TestParsePayoutCSV(t *testing.T) {
+	txns, err := ParseCSV(strings.NewReader(payoutCSV))
+	assert.Nil(t, err)
+	// The row without a sponsor is skipped
+	assert.Equal(t, 2, len(txns))
+	assert.True(t, txns[0].IsSubscription())
+	assert.True(t, txns[0].Payout)
+
+	// The made up ID is the same each time
+	again, err := ParseCSV(strings.NewReader(payoutCSV))
+	assert.Nil(t, err)
+	assert.Equal(t, txns[0].ID, again[0].ID)
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader("name,total\nBruce,10\n"))
+	assert.Contains(t, err.Error(), "does not look like a GitHub Sponsors export")
+
+	_, err = ParseCSV(strings.NewReader("Sponsor Handle,Processed Amount,Transaction Date\nbwayne,ten,2020-01-01\n"))
+	assert.Contains(t, err.Error(), `line 2: invalid amount "ten"`)
+}
+
+//==============================================================================
+// Summarize
+//==============================================================================
+
+func TestSummarize(t *testing.T) {
+	txns, err := ParseCSV(strings.NewReader(sponsorsCSV))
+	assert.Nil(t, err)
+
+	sums := txns.Summarize()
+	march := sums[time.March]
+	assert.Equal(t, 2, march.SubscriptionCount)
+	assert.Equal(t, float32(1010), march.SubscriptionAmt["USD"])
+
+	// The refunded payment comes to nothing
+	april := sums[time.April]
+	assert.Equal(t, 1, april.SubscriptionCount)
+	assert.Equal(t, 1, april.ReturnedCount)
+	assert.Equal(t, float32(-10), april.ReturnedAmt["USD"])
+	assert.Equal(t, float32(0), april.NetTotal()["USD"])
+}
+
+//==============================================================================
+// Merge
+//==============================================================================
+
+func TestMergeRefunded(t *testing.T) {
+	txns, err := ParseCSV(strings.NewReader(sponsorsCSV))
+	assert.Nil(t, err)
+
+	// The payment was settled when it was first imported
+	settled := *txns[2]
+	settled.Status = StatusSettled
+	previous := Transactions{txns[0], txns[1], &settled}
+
+	merged, updated := previous.MergeUpdated(txns)
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, 4, len(merged))
+	assert.Equal(t, StatusRefunded, merged[2].Status)
+	assert.Equal(t, float32(0), merged.Summarize()[time.April].NetTotal()["USD"])
+}
+
+//==============================================================================
+// WithoutMatchedPayouts
+//==============================================================================
+
+func TestImportSponsorAndPayoutExports(t *testing.T) {
+	sponsors, err := ParseCSV(strings.NewReader(sponsorsCSV))
+	assert.Nil(t, err)
+	payouts, err := ParseCSV(strings.NewReader(payoutCSV))
+	assert.Nil(t, err)
+
+	// In either order the payout of the March payment is only counted once
+	for _, all := range []Transactions{sponsors.Merge(payouts), payouts.Merge(sponsors)} {
+		txns := all.WithoutMatchedPayouts()
+		assert.Equal(t, 5, len(txns))
+
+		sums := txns.Summarize()
+		assert.Equal(t, float32(1010), sums[time.March].SubscriptionAmt["USD"])
+		// Only the payout which is not in the sponsor export is left
+		assert.Equal(t, 1, sums[time.May].SubscriptionCount)
+		assert.Equal(t, float32(5), sums[time.May].SubscriptionAmt["USD"])
+	}
+
+	// A payout is not matched to a payment after it
+	later := Transactions{{ID: "ch_9", Date: time.Date(2020, time.May, 5, 0, 0, 0, 0, time.UTC),
+		Status: StatusSettled, Amount: 10, Currency: "USD", Login: "bwayne"}}
+	assert.Equal(t, 3, len(payouts.Merge(later).WithoutMatchedPayouts()))
+}
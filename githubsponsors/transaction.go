@@ -0,0 +1,236 @@
+package githubsponsors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// Statuses of a sponsorship payment which matter here
+const (
+	StatusSettled  = "settled"
+	StatusRefunded = "refunded"
+)
+
+// Transaction is one payment of a sponsorship, from a sponsor or payout
+// export of GitHub Sponsors.
+type Transaction struct {
+	ID     string    `json:"id"`
+	Date   time.Time `json:"date"`
+	Status string    `json:"status"`
+
+	// GitHub pays the processing fees, so the amount is what was received.
+	// It is still what was paid once the payment is refunded.
+	Amount   float32 `json:"amount"`
+	Currency string  `json:"currency"`
+
+	Login string `json:"login"`
+	Name  string `json:"name,omitempty"`
+	// Only known when the sponsor made it public
+	Email string `json:"email,omitempty"`
+	// Sponsors can choose to be private, which means anonymous here
+	Private bool   `json:"private,omitempty"`
+	Yearly  bool   `json:"yearly,omitempty"`
+	Tier    string `json:"tier,omitempty"`
+
+	// From a payout export, which is dated when GitHub paid it out and has
+	// no transaction ID
+	Payout bool `json:"payout,omitempty"`
+}
+
+// IsSubscription is true for a settled payment of a sponsorship. Every
+// sponsorship is counted as a subscription, even those paid once.
+func (t *Transaction) IsSubscription() bool {
+	return t.Status == StatusSettled && t.Amount > 0
+}
+
+// IsReturn is true for a payment which was refunded. The export has no row
+// for the refund itself, only the payment with its status changed.
+func (t *Transaction) IsReturn() bool {
+	return t.Status == StatusRefunded
+}
+
+func (t *Transaction) String() string {
+	name := t.Name
+	if t.Private {
+		name = "Private"
+	}
+
+	return fmt.Sprintf("%s: %s (%s) GitHub Sponsors %s, %s %0.02f",
+		util.FormatDateTime(t.Date), name, t.Login, t.Status, t.Currency, t.Amount)
+}
+
+type Transactions []*Transaction
+
+// Sort sorts the transactions by date.
+func (p Transactions) Sort() {
+	sort.SliceStable(p, func(i, j int) bool {
+		return p[i].Date.Before(p[j].Date)
+	})
+}
+
+// Merge adds the other transactions which are not already in these. A
+// transaction which is already here is replaced when the other one has a
+// different status, such as a payment which has since been refunded.
+func (p Transactions) Merge(other Transactions) Transactions {
+	result, _ := p.MergeUpdated(other)
+
+	return result
+}
+
+// MergeUpdated is Merge which also returns how many transactions were
+// replaced because their status changed.
+func (p Transactions) MergeUpdated(other Transactions) (Transactions, int) {
+	result := make(Transactions, 0, len(p)+len(other))
+	ids := map[string]int{}
+	updated := 0
+
+	for _, t := range p {
+		ids[t.ID] = len(result)
+		result = append(result, t)
+	}
+	for _, t := range other {
+		i, found := ids[t.ID]
+		if !found {
+			ids[t.ID] = len(result)
+			result = append(result, t)
+		} else if result[i].Status != t.Status {
+			result[i] = t
+			updated++
+		}
+	}
+
+	return result, updated
+}
+
+// WithoutMatchedPayouts returns the transactions without the payout export
+// rows which are also in a sponsor export, so they are only counted once, on
+// the day they were paid. With no transaction ID to go by, each payout row is
+// matched to the latest settled payment by the same sponsor of the same
+// amount on or before its date, which was not matched already.
+func (p Transactions) WithoutMatchedPayouts() Transactions {
+	payouts := Transactions{}
+	for _, t := range p {
+		if t.Payout {
+			payouts = append(payouts, t)
+		}
+	}
+	payouts.Sort()
+
+	matched := map[*Transaction]bool{}
+	for _, payout := range payouts {
+		var payment *Transaction
+		for _, t := range p {
+			if t.Payout || !t.IsSubscription() || matched[t] || t.Date.After(payout.Date) ||
+				!strings.EqualFold(t.Login, payout.Login) || t.Amount != payout.Amount ||
+				t.Currency != payout.Currency {
+				continue
+			}
+			if payment == nil || t.Date.After(payment.Date) {
+				payment = t
+			}
+		}
+		if payment != nil {
+			matched[payment] = true
+			matched[payout] = true
+		}
+	}
+
+	result := make(Transactions, 0, len(p))
+	for _, t := range p {
+		if !t.Payout || !matched[t] {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
+// Summarize adds the sponsorships and refunds to the summary of their month.
+// A refunded payment is added along with its refund, so it comes to nothing.
+func (p Transactions) Summarize() util.MonthlySummaries {
+	result := make(util.MonthlySummaries)
+
+	for _, t := range p {
+		_, month := util.MonthOf(t.Date)
+
+		switch {
+		case t.IsSubscription():
+			result.ForMonth(month).AddSubscription(t.Amount, 0, t.Currency)
+		case t.IsReturn():
+			result.ForMonth(month).AddSubscription(t.Amount, 0, t.Currency)
+			result.ForMonth(month).AddReturn(-t.Amount, 0, t.Currency)
+		}
+	}
+
+	return result
+}
+
+func fileName(year int) string {
+	return filepath.Join(util.DataDir(), fmt.Sprintf("githubsponsors-%d.json", year))
+}
+
+var fileYearRegexp = regexp.MustCompile(`^githubsponsors-(\d{4})\.json$`)
+
+// SavedYears returns the years which have saved transactions, in order.
+func SavedYears() ([]int, error) {
+	result := []int{}
+
+	files, err := ioutil.ReadDir(util.DataDir())
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range files {
+		if match := fileYearRegexp.FindStringSubmatch(info.Name()); match != nil && !info.IsDir() {
+			year, err := strconv.Atoi(match[1])
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, year)
+		}
+	}
+	sort.Ints(result)
+
+	return result, nil
+}
+
+// LoadYear loads the saved transactions of the year, which is empty if none
+// have been saved.
+func LoadYear(year int) (Transactions, error) {
+	f, err := os.Open(fileName(year))
+	if os.IsNotExist(err) {
+		return Transactions{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	txns := Transactions{}
+	if err := json.NewDecoder(f).Decode(&txns); err != nil {
+		return nil, fmt.Errorf("could not load %s: %w", fileName(year), err)
+	}
+
+	return txns, nil
+}
+
+// SaveYear saves the transactions of the year, replacing what was saved.
+func SaveYear(year int, txns Transactions) error {
+	if err := os.MkdirAll(util.DataDir(), 0755); err != nil {
+		return err
+	}
+	return util.WriteJSONFile(fileName(year), txns)
+}
@@ -1,11 +1,21 @@
 module github.com/leavengood/donation_tracker
 
-go 1.13
+go 1.18
 
 require (
-	github.com/go-ini/ini v1.67.0 // indirect
 	github.com/minio/minio-go v6.0.14+incompatible
-	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/stretchr/testify v1.4.0
+	go.etcd.io/bbolt v1.3.6
+	golang.org/x/sys v0.8.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/go-ini/ini v1.67.0 // indirect
+	github.com/mitchellh/go-homedir v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/crypto v0.9.0 // indirect
+	golang.org/x/net v0.10.0 // indirect
+	golang.org/x/text v0.9.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
@@ -0,0 +1,320 @@
+// Package httprecord records HTTP requests and their responses to a directory
+// and replays them later, so a run of the donation tracker can be reproduced
+// exactly without network access. Credentials are scrubbed from everything
+// which is saved.
+package httprecord
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redacted replaces any credentials in the recorded requests and responses
+const Redacted = "REDACTED"
+
+// SensitiveParams are the query and form parameters which are scrubbed from
+// recorded requests. The names are not case sensitive.
+var SensitiveParams = []string{
+	// PayPal NVP API
+	"USER", "PWD", "SIGNATURE",
+	// fixer.io
+	"access_key",
+	// OAuth2
+	"client_secret", "access_token",
+}
+
+// sensitiveJSONKeys are scrubbed from JSON responses
+var sensitiveJSONKeys = []string{"access_token", "refresh_token", "id_token"}
+
+const sessionFile = "session.json"
+
+// Exchange is one recorded request and its response.
+type Exchange struct {
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestBody    string      `json:"request_body,omitempty"`
+	StatusCode     int         `json:"status_code"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	ResponseBody   string      `json:"response_body"`
+}
+
+// Session has information about the whole recorded run.
+type Session struct {
+	// When the recording was made, which a replay should pretend is now
+	Time time.Time `json:"time"`
+	Args []string  `json:"args"`
+}
+
+// SaveSession saves the session information to the directory.
+func SaveSession(dir string, session *Session) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, sessionFile), b, 0644)
+}
+
+// LoadSession loads the session information from the directory.
+func LoadSession(dir string) (*Session, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, sessionFile))
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{}
+	if err := json.Unmarshal(b, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Recorder is an http.RoundTripper which saves every request and response
+// made through it to a directory.
+type Recorder struct {
+	Dir       string
+	Transport http.RoundTripper
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewRecorder returns a Recorder which saves to the directory, creating it if
+// needed, and uses the transport to make the requests.
+func NewRecorder(dir string, transport http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Recorder{
+		Dir:       dir,
+		Transport: transport,
+		counts:    map[string]int{},
+	}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	ex := &Exchange{
+		Method:         req.Method,
+		URL:            scrubURL(req.URL),
+		RequestBody:    scrubBody(reqBody),
+		StatusCode:     resp.StatusCode,
+		ResponseHeader: resp.Header,
+		ResponseBody:   scrubResponse(respBody),
+	}
+
+	r.mu.Lock()
+	key := ex.key()
+	n := r.counts[key]
+	r.counts[key]++
+	r.mu.Unlock()
+
+	b, err := json.MarshalIndent(ex, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(exchangeFile(r.Dir, key, n), b, 0644); err != nil {
+		return nil, fmt.Errorf("could not record %s %s: %w", ex.Method, ex.URL, err)
+	}
+
+	return resp, nil
+}
+
+// Replayer is an http.RoundTripper which answers requests with the responses
+// saved by a Recorder. Identical requests get their responses in the order
+// they were recorded.
+type Replayer struct {
+	Dir string
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func NewReplayer(dir string) (*Replayer, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	return &Replayer{
+		Dir:    dir,
+		counts: map[string]int{},
+	}, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ex := &Exchange{
+		Method:      req.Method,
+		URL:         scrubURL(req.URL),
+		RequestBody: scrubBody(reqBody),
+	}
+
+	r.mu.Lock()
+	key := ex.key()
+	n := r.counts[key]
+	r.counts[key]++
+	r.mu.Unlock()
+
+	b, err := ioutil.ReadFile(exchangeFile(r.Dir, key, n))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no recorded response for %s %s in %s", ex.Method, ex.URL, r.Dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, ex); err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", ex.StatusCode, http.StatusText(ex.StatusCode)),
+		StatusCode:    ex.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        ex.ResponseHeader,
+		Body:          ioutil.NopCloser(strings.NewReader(ex.ResponseBody)),
+		ContentLength: int64(len(ex.ResponseBody)),
+		Request:       req,
+	}, nil
+}
+
+// key identifies the request, after credentials are scrubbed
+func (ex *Exchange) key() string {
+	sum := sha256.Sum256([]byte(ex.Method + " " + ex.URL + "\n" + ex.RequestBody))
+	return hex.EncodeToString(sum[:8])
+}
+
+func exchangeFile(dir, key string, n int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s-%03d.json", key, n))
+}
+
+func readRequestBody(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", nil
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	return string(b), nil
+}
+
+func isSensitive(name string) bool {
+	for _, s := range SensitiveParams {
+		if strings.EqualFold(name, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scrubValues replaces the values of sensitive parameters. The result is
+// sorted by name so it is the same for the same parameters.
+func scrubValues(v url.Values) string {
+	for name := range v {
+		if isSensitive(name) {
+			v[name] = []string{Redacted}
+		}
+	}
+
+	return v.Encode()
+}
+
+func scrubURL(u *url.URL) string {
+	scrubbed := *u
+	scrubbed.User = nil
+	if scrubbed.RawQuery != "" {
+		if v, err := url.ParseQuery(scrubbed.RawQuery); err == nil {
+			scrubbed.RawQuery = scrubValues(v)
+		}
+	}
+
+	return scrubbed.String()
+}
+
+// scrubBody scrubs form encoded request bodies. Anything else is left alone.
+func scrubBody(body string) string {
+	if body == "" || strings.HasPrefix(body, "{") {
+		return body
+	}
+
+	v, err := url.ParseQuery(body)
+	if err != nil {
+		return body
+	}
+
+	return scrubValues(v)
+}
+
+// scrubResponse removes tokens from JSON responses.
+func scrubResponse(body []byte) string {
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return string(body)
+	}
+
+	scrubbed := false
+	for _, key := range sensitiveJSONKeys {
+		if _, found := obj[key]; found {
+			obj[key] = Redacted
+			scrubbed = true
+		}
+	}
+	if !scrubbed {
+		return string(body)
+	}
+
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return string(body)
+	}
+
+	return string(b)
+}
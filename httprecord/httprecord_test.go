@@ -0,0 +1,107 @@
+package httprecord
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.NoError(t, r.ParseForm())
+		fmt.Fprintf(w, "ACK=Success&CALL=%d&START=%s", calls, r.Form.Get("STARTDATE"))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, http.DefaultTransport)
+	assert.NoError(t, err)
+
+	form := url.Values{
+		"METHOD":    {"TransactionSearch"},
+		"USER":      {"api-user"},
+		"PWD":       {"secret-password"},
+		"SIGNATURE": {"secret-signature"},
+		"STARTDATE": {"2020-03-01T00:00:00Z"},
+	}
+	post := func(client *http.Client) string {
+		resp, err := client.PostForm(ts.URL, form)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return string(b)
+	}
+
+	recording := &http.Client{Transport: recorder}
+	first := post(recording)
+	second := post(recording)
+	assert.Equal(t, "ACK=Success&CALL=1&START=2020-03-01T00:00:00Z", first)
+	assert.Equal(t, "ACK=Success&CALL=2&START=2020-03-01T00:00:00Z", second)
+
+	// The credentials are not saved anywhere
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(files))
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		assert.NoError(t, err)
+		assert.False(t, strings.Contains(string(b), "secret"), f)
+		assert.False(t, strings.Contains(string(b), "api-user"), f)
+		assert.True(t, strings.Contains(string(b), Redacted), f)
+	}
+
+	// Replays come back in the same order, with different credentials, and
+	// without calling the server
+	ts.Close()
+	replayer, err := NewReplayer(dir)
+	assert.NoError(t, err)
+	form.Set("PWD", "another-password")
+	replaying := &http.Client{Transport: replayer}
+	assert.Equal(t, first, post(replaying))
+	assert.Equal(t, second, post(replaying))
+	assert.Equal(t, 2, calls)
+
+	// Nothing else was recorded
+	_, err = replaying.PostForm(ts.URL, form)
+	assert.Error(t, err)
+}
+
+func TestRecordScrubsTokensAndQueryParams(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token": "secret-token", "expires_in": 32400}`)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, http.DefaultTransport)
+	assert.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: recorder}).Get(ts.URL + "/latest?symbols=USD&access_key=secret-key")
+	assert.NoError(t, err)
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NoError(t, err)
+
+	// The caller still gets the real response
+	assert.Contains(t, string(b), "secret-token")
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(files))
+	saved, err := ioutil.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.NotContains(t, string(saved), "secret")
+	assert.Contains(t, string(saved), "symbols=USD")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/leavengood/donation_tracker/paypal"
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// importPayPalCSV merges the transactions in PayPal Activity download CSV
+// files into the month files of the account. Transactions which are already
+// saved are left alone, unless they are saved as pending and the file has
+// them with another status.
+func importPayPalCSV(acct *payPalAccount, files []string, dateOrder string) error {
+	all := paypal.Transactions{}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		txns, err := paypal.ParseActivityCSV(f, dateOrder)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("could not import %s: %w", name, err)
+		}
+		fmt.Printf("Read %d transactions from %s\n", len(txns), name)
+		all = append(all, txns...)
+	}
+	if len(all) == 0 {
+		fmt.Println("There are no transactions to import")
+		return nil
+	}
+	all.Sort()
+
+	// Group them by the month they belong to
+	byYear := map[int]map[int]paypal.Transactions{}
+	for _, t := range all {
+		year, month := util.MonthOf(t.Timestamp)
+		if byYear[year] == nil {
+			byYear[year] = map[int]paypal.Transactions{}
+		}
+		byYear[year][int(month)] = append(byYear[year][int(month)], t)
+	}
+	years := make([]int, 0, len(byYear))
+	for year := range byYear {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+
+	// The files are taken to cover from the day of the first transaction up to
+	// the last one, which may not be the whole of its day
+	first, last := util.DayStart(all[0].Timestamp), all[len(all)-1].Timestamp
+
+	for _, year := range years {
+		fm, err := paypal.NewFileManager(acct.Name, year)
+		if err != nil {
+			return fmt.Errorf("could not load PayPal files for year %d: %w", year, err)
+		}
+
+		for month := 1; month <= 12; month++ {
+			txns, found := byYear[year][month]
+			if !found {
+				continue
+			}
+			previous := fm.Months[month]
+			// Merge will remove any duplicates and update any which were pending,
+			// since the statuses of the file may not be those of the API
+			merged, updated := previous.MergePending(txns)
+			merged.Sort()
+
+			added := len(merged) - len(previous)
+			fmt.Printf("%s %d: %d new and %d updated of %d transactions\n", time.Month(month), year, added, updated, len(txns))
+
+			start, end := util.MonthStart(year, time.Month(month)), util.MonthEnd(year, time.Month(month))
+			if first.After(start) {
+				start = first
+			}
+			if last.Before(end) {
+				end = last
+			}
+			coverage := fm.CoverageOf(month).Extend(year, month, start, end, paypal.SourceCSV)
+			if err := fm.SaveMonth(month, merged, coverage); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Printf("\nThe files cover %s to %s. The rest of any month they only partly cover is fetched by "+
+		"update for its year, if the PayPal API can still be used.\n",
+		util.FormatDate(all[0].Timestamp), util.FormatDate(all[len(all)-1].Timestamp))
+
+	return nil
+}
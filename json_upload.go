@@ -14,6 +14,15 @@ type DonationSummary struct {
 	EurDonations   float32   `json:"eur_donations"`
 	EurToUsdRate   float32   `json:"eur_to_usd_rate"`
 	TotalDonations float32   `json:"total_donations"`
+	// Refunded, reversed or charged back, which the donations are net of
+	UsdReturned float32 `json:"usd_returned"`
+	EurReturned float32 `json:"eur_returned"`
+	// The part of the total donated through Stripe, in USD
+	StripeDonations float32 `json:"stripe_donations,omitempty"`
+	// The part of the total contributed through Open Collective, in USD
+	OpenCollectiveDonations float32 `json:"open_collective_donations,omitempty"`
+	// The part of the total from GitHub Sponsors, in USD
+	GitHubSponsorsDonations float32 `json:"github_sponsors_donations,omitempty"`
 }
 
 const minioHost = "s3.us-west-1.wasabisys.com"
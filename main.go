@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"os"
+	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/leavengood/donation_tracker/paypal"
+	"github.com/leavengood/donation_tracker/stripe"
 	"github.com/leavengood/donation_tracker/util"
 )
 
@@ -17,63 +21,254 @@ Usage: %s [command]
 If no command is given, the default command of "update" is performed for the
 current year.
 
+The -record <dir> and -replay <dir> flags can be used with any command. The
+first saves all HTTP requests to PayPal and fixer.io, and their responses, to
+the directory with credentials removed, along with a copy of the data
+directory. The second runs the command again offline using those saved
+responses, as of the time they were recorded, on a scratch copy of the saved
+data, so it never changes the data directory or uploads anything.
+
+When several PayPal accounts are configured every command uses all of them,
+unless the -account <name> flag picks one.
+
+Only one command at a time changes the data directory. Another command waits
+for it to finish for up to the -wait duration, two minutes by default, and then
+gives up. Commands which only read the data, like summarize, donors and
+subscriptions, can run together.
+
 Commands:
-    update [-year int] [-skip-upload]
+    update [-year int] [-skip-upload] [-by-account]
         Update the donation information for the given year, defaulting to the
         current year. Summary information is uploaded as JSON to the Haiku CDN
         for the current year only unless the --skip-upload flag is provided.
+        With -by-account the totals of each PayPal account are also printed.
+        Updating the current year first finishes any earlier year which is not
+        fully fetched, like the end of December in January, and prints the
+        final totals of that year.
 
-    summarize [-year int]
+    summarize [-year int] [-by-account]
         Provide a summary of a given year, defaulting to the current year. No
         new data is downloaded.
 
     fetch <-month int> [-year int]
-        Fetch and save a single month of transactions from PayPal given a
-        numeric month and optionally a year. The default year is the current
-        year. Overwrites any existing data.
+        Fetch and save a single month of transactions from PayPal, and Stripe
+        if it is configured, given a numeric month and optionally a year. The
+        default year is the current year. Overwrites any existing data.
 
-    donors [-year int]
+    donors [-year int] [-emails] [-details]
         Collect information for donors in the given year, defaulting to the
         current year, and print out their name, email, how much and how many
-        times they have donated, in descending order of donation amount.
+        times they have donated, in descending order of donation amount. With
+        -details their country and any notes they left are included, when the
+        transaction details have been fetched.
+
+    donor-thanks [-year int] [-details]
+        Print a Markdown list of the donors for the given year who did not ask
+        to be anonymous. With -details their country and notes are included.
+
+    subscriptions [-year int] [-month int]
+        List the subscriptions which were active in the given year, or month of
+        that year if one is given, and those which were cancelled or lapsed
+        then, with their amount, start and last payment as of the end of that
+        time. No new data is downloaded.
+
+    classify [-year int] [-month int]
+        Show the category each saved PayPal transaction of the given year, or
+        month of that year, is classified into and the rule which decided it,
+        using the classification_rules in the config and then the defaults.
+
+    balance
+        Get the current balance of each PayPal account in every currency and
+        add it to the dated snapshots in paypal-balances.json in the data
+        directory of the account.
+
+    reconcile [-from YYYY-MM-DD] [-to YYYY-MM-DD]
+        Compare the change in PayPal balance between two snapshots, defaulting
+        to the last two, with the net amounts of the stored transactions in
+        between, including fees, conversions and withdrawals, and flag any gap.
+        Fetch the months in between first so the transactions are complete.
+
+    import-paypal-csv [-date-order dmy|mdy|ymd] <file>...
+        Import CSV files from the Activity download of the PayPal website, in
+        any language, into the saved months, skipping transactions which are
+        already saved. This needs no API access, so it works for years the API
+        no longer returns. The order of the dates is detected unless given.
+        With several PayPal accounts, -account picks the one to import into.
+
+    import-opencollective <file>...
+        Import CSV transaction exports of the collective from Open Collective
+        into data/opencollective-YYYY.json, skipping transactions which are
+        already saved. Its contributions, refunds and fees are then included
+        by the other commands. If open_collective is configured, update also
+        gets new transactions from the Open Collective API.
+
+    import-github-sponsors <file>...
+        Import CSV sponsor or payout exports from GitHub Sponsors into
+        data/githubsponsors-YYYY.json, skipping payments which are already
+        saved. Every sponsorship payment counts as a subscription, and private
+        sponsors are anonymous in the donor commands.
+
+    migrate-storage <from> <to>
+        Copy every saved month of PayPal transactions from one kind of storage
+        to the other, either "json" or "bolt", replacing any months already
+        in the destination. Afterwards set "storage" in the config to use it.
+
+    fake-paypal [-addr host:port] [-data file] [-save file] [-seed int]
+                [-per-day float] [-max-results int] [-error-code code]
+                [-fail-every int]
+        Run a fake PayPal NVP API server for local development, serving a
+        generated or loaded data set of donations, subscriptions and refunds.
+        Point the PayPal endpoint in the config at it to run the other
+        commands without real credentials. This does not need a config file.
 
     help
         Show this usage.
 
 A config file named config.json should be defined as described in the README.`
 
-func donorInfo(year int) (util.Donors, error) {
-	fm, err := paypal.NewFileManager(year)
+// Where donors gave, as shown by the donor commands
+const (
+	sourcePayPal         = "PayPal"
+	sourceStripe         = "Stripe"
+	sourceOpenCollective = "Open Collective"
+	sourceGitHubSponsors = "GitHub Sponsors"
+)
+
+func donorInfo(accounts []*payPalAccount, year int) (util.Donors, error) {
+	fm, _, err := loadYear(accounts, year)
 	if err != nil {
-		return nil, fmt.Errorf("could not load PayPal files for year %d: %w", year, err)
+		return nil, err
+	}
+	others, err := loadOtherSources(year)
+	if err != nil {
+		return nil, err
 	}
 	config, err := util.LoadDonorConfig()
 	if err != nil {
 		return nil, fmt.Errorf("could not load the donor config file: %w", err)
 	}
 
+	return collectDonors(fm, others, config), nil
+}
+
+// collectDonors adds up the donations of each donor in the PayPal
+// transactions and those of the other sources, sorted by how much they gave.
+func collectDonors(fm *paypal.FileManager, others *otherSources, config *util.DonorConfig) util.Donors {
+	// Donors are found by email, or by the key of the source when there is
+	// none
 	donorMap := map[string]*util.Donor{}
+	addDonation := func(source, key, name, email string, amt float32, currency, countryCode, note string) {
+		donor, found := donorMap[key]
+		if !found {
+			donor = &util.Donor{
+				Name:  name,
+				Email: email,
+				Total: util.CurrencyAmounts{},
+				Count: 0,
+			}
+			// Correct their name or set the anoymous flag
+			config.Handle(donor)
+			donorMap[key] = donor
+		}
+		donor.Total[currency] += amt
+		donor.Count++
+		donor.AddSource(source)
+		if countryCode != "" {
+			donor.CountryCode = countryCode
+		}
+		if note != "" {
+			donor.Notes = append(donor.Notes, note)
+		}
+	}
+	// Anything returned by each donor, which is negative
+	returned := map[string]util.CurrencyAmounts{}
+	addReturn := func(key string, amt float32, currency string) {
+		if returned[key] == nil {
+			returned[key] = util.CurrencyAmounts{}
+		}
+		returned[key][currency] += amt
+	}
+
+	// Returns of payments which never counted are left out
+	all := paypal.Transactions{}
 	for _, txns := range fm.Months {
-		for _, t := range txns {
-			if t.IsDonation() || t.IsSubscription() {
+		all = append(all, txns...)
+	}
+	all.LinkReturns()
+	counted, _ := all.WithoutVoided(all)
+	for _, t := range counted {
+		if t.IsReturn() {
+			addReturn(t.Email, t.Amt, t.CurrencyCode)
+		} else if t.IsDonation() || t.IsSubscription() {
+			addDonation(sourcePayPal, t.Email, t.Name, t.Email, t.Amt, t.CurrencyCode, t.CountryCode, t.Note)
+		}
+	}
+	// Stripe charges often have no email, so those donors are found by the
+	// charge, which a refund of it also has
+	if others.Stripe != nil {
+		for _, txns := range others.Stripe.Months {
+			for _, t := range txns {
 				key := t.Email
-				donor, found := donorMap[key]
-				if !found {
-					donor = &util.Donor{
-						Name:  t.Name,
-						Email: t.Email,
-						Total: util.CurrencyAmounts{},
-						Count: 0,
-					}
-					// Correct their name or set the anoymous flag
-					config.Handle(donor)
-					donorMap[key] = donor
+				if key == "" && t.ChargeID != "" {
+					key = "stripe/" + t.ChargeID
+				} else if key == "" {
+					key = "stripe/" + t.ID
+				}
+				if t.IsReturn() {
+					addReturn(key, t.Amount, t.Currency)
+				} else if t.IsDonation() || t.IsSubscription() {
+					addDonation(sourceStripe, key, t.Name, t.Email, t.Amount, t.Currency, t.CountryCode, "")
 				}
-				donor.Total[t.CurrencyCode] += t.Amt
-				donor.Count++
 			}
 		}
 	}
+	// Open Collective does not give out emails
+	for _, t := range others.OpenCollective {
+		key := "opencollective/" + t.ContributorSlug
+		if t.IsReturn() {
+			addReturn(key, t.Amount, t.Currency)
+		} else if t.IsDonation() || t.IsSubscription() {
+			addDonation(sourceOpenCollective, key, t.ContributorName, "", t.Amount, t.Currency, "", "")
+			if t.Incognito {
+				donorMap[key].Anonymous = true
+			}
+		}
+	}
+	// Sponsors are found by their public email when they have one, in any of
+	// their payments, so they are the same donor as on PayPal
+	sponsorEmails := map[string]string{}
+	for _, t := range others.GitHubSponsors {
+		if t.Email != "" {
+			sponsorEmails[t.Login] = t.Email
+		}
+	}
+	for _, t := range others.GitHubSponsors {
+		email := sponsorEmails[t.Login]
+		key := email
+		if key == "" {
+			key = "github/" + t.Login
+		}
+		name := t.Name
+		if name == "" {
+			name = t.Login
+		}
+		if t.IsReturn() {
+			addReturn(key, t.Amount, t.Currency)
+		} else if t.IsSubscription() {
+			addDonation(sourceGitHubSponsors, key, name, email, t.Amount, t.Currency, "", "")
+			// Private sponsors are anonymous, like those in the donor config
+			if t.Private {
+				donorMap[key].Anonymous = true
+			}
+		}
+	}
+
+	// Anything returned is taken off what they gave
+	for key, amounts := range returned {
+		if donor, found := donorMap[key]; found {
+			donor.Total = donor.Total.Add(amounts)
+		}
+	}
 
 	donors := make(util.Donors, 0, len(donorMap))
 	for _, person := range donorMap {
@@ -81,18 +276,43 @@ func donorInfo(year int) (util.Donors, error) {
 	}
 	donors.Sort()
 
-	return donors, nil
+	return donors
 }
 
-func main() {
-	exit := func(msg string, exitCode int) {
-		fmt.Println(msg)
-		os.Exit(exitCode)
+// payPalErrorHint returns some advice for errors from the PayPal API which
+// the user can do something about.
+func payPalErrorHint(err error) string {
+	switch {
+	case paypal.IsAuthError(err):
+		return "Check the PayPal credentials in " + ConfigFile
+	case paypal.IsRateLimited(err):
+		return "PayPal is limiting API calls right now, try again later"
 	}
 
-	err := LoadConfig()
-	if err != nil {
-		exit(fmt.Sprintf("Could not load config file %v because of error: %v\n", ConfigFile, err), 1)
+	return ""
+}
+
+// The commands which only read the data directory, so several of them can
+// use it at once
+var readOnlyCommands = map[string]bool{
+	"summarize":     true,
+	"donors":        true,
+	"donor-thanks":  true,
+	"subscriptions": true,
+	"classify":      true,
+	"reconcile":     true,
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// run performs the command and returns the exit code, after anything it
+// opened is closed.
+func run() int {
+	fail := func(msg string, exitCode int) int {
+		fmt.Println(msg)
+		return exitCode
 	}
 
 	// Default command is update
@@ -107,15 +327,40 @@ func main() {
 		}
 	}
 
-	currentYear, currentMonth, _ := time.Now().UTC().Date()
+	// This does not need the config
+	if cmd == "fake-paypal" {
+		if err := runFakePayPal(args); err != nil {
+			return fail(fmt.Sprintf("Error: %v", err), 1)
+		}
+		return 0
+	}
+
+	err := LoadConfig()
+	if err != nil {
+		return fail(fmt.Sprintf("Could not load config file %v because of error: %v\n", ConfigFile, err), 1)
+	}
+	util.SetLocation(config.Location())
+	paypal.SetRules(config.ClassificationRules)
+	if config.FixerIoUrl != "" {
+		exchangeRateUrl = config.FixerIoUrl + "?format=1&symbols=USD&access_key="
+	}
 
 	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-	year := currentYear
-	flagSet.IntVar(&year, "year", currentYear, "Specifies the year to operate on")
-	month := int(currentMonth)
-	flagSet.IntVar(&month, "month", int(currentMonth), "Specifies the month to operate on")
+	year := 0
+	flagSet.IntVar(&year, "year", 0, "Specifies the year to operate on, defaulting to the current year")
+	month := 0
+	flagSet.IntVar(&month, "month", 0, "Specifies the month to operate on, defaulting to the current month")
 	skipUpload := flagSet.Bool("skip-upload", false, "Skip uploading data to the server on the 'update' command")
 	emails := flagSet.Bool("emails", false, "Print only emails in the 'donors' command")
+	details := flagSet.Bool("details", false, "Include countries and notes in the 'donors' and 'donor-thanks' commands")
+	recordDir := flagSet.String("record", "", "Record all PayPal and fixer.io HTTP traffic to this directory")
+	fromDate := flagSet.String("from", "", "The date of the first balance snapshot in the 'reconcile' command")
+	toDate := flagSet.String("to", "", "The date of the second balance snapshot in the 'reconcile' command")
+	replayDir := flagSet.String("replay", "", "Replay HTTP traffic recorded with -record from this directory, without network access")
+	accountFlag := flagSet.String("account", "", "Only use the PayPal account with this name from the config")
+	dateOrder := flagSet.String("date-order", "", "The order of dates in the 'import-paypal-csv' command: dmy, mdy or ymd, detected by default")
+	lockWait := flagSet.Duration("wait", 2*time.Minute, "How long to wait for another command using the data directory to finish")
+	byAccount := flagSet.Bool("by-account", false, "Also print the totals of each PayPal account in the 'update' and 'summarize' commands")
 
 	printUsage := func() {
 		fmt.Println(fmt.Sprintf(usage, exe))
@@ -125,6 +370,34 @@ func main() {
 	flagSet.Usage = printUsage
 	flagSet.Parse(args)
 
+	if cmd == "help" {
+		printUsage()
+		return 0
+	}
+
+	if err := setupRecording(*recordDir, *replayDir); err != nil {
+		return fail(fmt.Sprintf("Error: %v", err), 1)
+	}
+	replaying := *replayDir != ""
+	paypal.SetNow(now)
+	stripe.SetNow(now)
+	if replaying {
+		removeReplayData, err := useReplayData(*replayDir)
+		if err != nil {
+			return fail(fmt.Sprintf("Error: %v", err), 1)
+		}
+		defer removeReplayData()
+	}
+
+	currentYear, currentMonth, _ := util.InLocation(now()).Date()
+	monthGiven := month != 0
+	if year == 0 {
+		year = currentYear
+	}
+	if month == 0 {
+		month = int(currentMonth)
+	}
+
 	introPrint := func(msg string) {
 		fmt.Printf("%s %s...\n\n", util.Colorize(util.Green, "✷"), msg)
 	}
@@ -132,68 +405,118 @@ func main() {
 	greenCheck := util.Colorize(util.Green, "✓")
 	blueArrow := util.Colorize(util.Blue, "❯")
 
-	getExchangeRate := func() float32 {
+	getExchangeRate := func() (float32, error) {
 		fmt.Print("Fetching exchange rate for EUR to USD...")
 		rate, err := GetExchangeRate(config.FixerIoAccessKey)
 		if err != nil {
-			exit(fmt.Sprintf("Error: could not get EUR to USD exchange rate: %v", err), 1)
+			return 0, fmt.Errorf("could not get EUR to USD exchange rate: %w", err)
 		}
 		if rate == float32(0) {
-			exit("Error: we got a 0 exchange rate from fixer.io, is the access key correct?", 1)
+			return 0, errors.New("we got a 0 exchange rate from fixer.io, is the access key correct?")
 		}
 		fmt.Printf("got rate of %f.\n\n", rate)
-		return rate
+		return rate, nil
 	}
 
 	// Sanity check the year
 	if year < 2010 || year > currentYear {
-		exit(fmt.Sprintf("Error: Please provide a year between 2010 and %d", currentYear), 1)
+		return fail(fmt.Sprintf("Error: Please provide a year between 2010 and %d", currentYear), 1)
 	}
 
 	util.PrintLogo()
 
-	client := paypal.NewClient(config.PayPal)
+	// Stop any PayPal calls cleanly on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Only one command at a time changes the data directory, while those
+	// which only read it can run together, and anything a command which
+	// crashed was writing is cleaned up
+	dataDir := util.DataDir()
+	readOnly := readOnlyCommands[cmd]
+	lockDir := util.LockDir
+	if readOnly {
+		lockDir = util.LockDirShared
+	}
+	lock, err := lockDir(ctx, dataDir, *lockWait)
+	if err != nil {
+		return fail(fmt.Sprintf("Error: %v", err), 1)
+	}
+	defer lock.Unlock()
+	if !readOnly {
+		removed, err := util.RemoveTempFiles(dataDir)
+		if err != nil {
+			return fail(fmt.Sprintf("Error: could not check for partly written files: %v", err), 1)
+		}
+		for _, name := range removed {
+			fmt.Printf("%s Removed %s, which was partly written when a command stopped, the file it was "+
+				"replacing is unchanged.\n", util.Colorize(util.BrightYellow, "!"), name)
+		}
+	}
+	if *recordDir != "" {
+		if err := saveRecordedData(*recordDir); err != nil {
+			return fail(fmt.Sprintf("Error: %v", err), 1)
+		}
+	}
+
+	// Copying between storages opens both itself
+	if cmd != "migrate-storage" {
+		openStorage := paypal.OpenStorage
+		if readOnly {
+			openStorage = paypal.OpenStorageReadOnly
+		}
+		store, err := openStorage(config.Storage)
+		if err != nil {
+			return fail(fmt.Sprintf("Error: %v", err), 1)
+		}
+		paypal.SetStorage(store)
+		defer store.Close()
+	}
+
+	accounts, err := newPayPalAccounts(config.Accounts(), *accountFlag)
+	if err != nil {
+		return fail(fmt.Sprintf("Error: %v", err), 1)
+	}
 
 	switch cmd {
-	case "help":
-		printUsage()
-		os.Exit(0)
-
 	case "update":
-		fmt.Printf("Running on %s\n\n", time.Now().Format("Mon, Jan 2, 2006 at 03:04 pm"))
+		fmt.Printf("Running on %s\n\n", util.InLocation(now()).Format("Mon, Jan 2, 2006 at 03:04 pm MST"))
 
 		extraMsg := ""
-		if *skipUpload {
+		if *skipUpload || replaying {
 			extraMsg = ", skipping upload of data."
 		}
 
 		introPrint(fmt.Sprintf("Updating donation information for %d%s", year, extraMsg))
 
 		// Start with this so we fail fast if it has an error
-		eurToUsdRate := getExchangeRate()
+		eurToUsdRate, err := getExchangeRate()
+		if err != nil {
+			return fail(fmt.Sprintf("Error: %v", err), 1)
+		}
 
-		ds, err := ProcessYear(client, year, eurToUsdRate)
+		ds, err := ProcessYear(ctx, accounts, year, eurToUsdRate, *byAccount)
 		if err != nil {
-			exit(fmt.Sprintf("Error: could not process year %d: %v\n", year, err), 1)
+			return fail(fmt.Sprintf("Error: could not process year %d: %v\n%s", year, err, payPalErrorHint(err)), 1)
 		}
 
 		fmt.Printf("Donation Summary: %#v\n", ds)
 
 		// Update the JSON file, if this is the current year and the skip flag was not set
-		if year == currentYear && !*skipUpload {
+		if year == currentYear && !*skipUpload && !replaying {
 			fmt.Printf("%s Uploading donation summary...\n", blueArrow)
 			err = UploadJson(ds)
 			if err != nil {
-				log.Fatalln(err)
+				return fail(fmt.Sprintf("Error: could not upload the donation summary: %v", err), 1)
 			}
 		}
 
 		fmt.Printf("\n%s Update complete!\n", greenCheck)
 
 	case "summarize":
-		fm, err := paypal.NewFileManager(year)
+		fm, fms, err := loadYear(accounts, year)
 		if err != nil {
-			exit(fmt.Sprintf("Error: could not load PayPal files for year %d: %v\n", year, err), 1)
+			return fail(fmt.Sprintf("Error: %v\n", err), 1)
 		}
 		latest := fm.GetLatestTransaction()
 		if latest != nil {
@@ -202,7 +525,20 @@ func main() {
 			introPrint(fmt.Sprintf("There does not seem to be any PayPal transactions for %d", year))
 		}
 
-		SummarizeYear(year, getExchangeRate(), fm)
+		others, err := loadOtherSources(year)
+		if err != nil {
+			return fail(fmt.Sprintf("Error: %v\n", err), 1)
+		}
+
+		eurToUsdRate, err := getExchangeRate()
+		if err != nil {
+			return fail(fmt.Sprintf("Error: %v", err), 1)
+		}
+		SummarizeYear(year, eurToUsdRate, fm, others)
+		if *byAccount {
+			fmt.Println("")
+			printAccountBreakdown(year, eurToUsdRate, fms)
+		}
 
 	case "fetch":
 		// Sanity check the month
@@ -211,39 +547,62 @@ func main() {
 			maxMonth = int(currentMonth)
 		}
 		if month < 1 || month > maxMonth {
-			exit(fmt.Sprintf("Error: Please provide a month between 1 and %d", maxMonth), 1)
+			return fail(fmt.Sprintf("Error: Please provide a month between 1 and %d", maxMonth), 1)
 		}
 
-		fm := paypal.NewEmptyFileManager(year)
-		if err := client.GetAndSaveMonth(year, month, fm); err != nil {
-			exit(fmt.Sprintf("Error: could not save transactions: %s", err), 1)
+		for _, acct := range accounts {
+			fm := paypal.NewEmptyFileManager(acct.Name, year)
+			if err := paypal.GetAndSaveMonth(ctx, acct.source, year, month, fm); err != nil {
+				err = wrapAccountError(acct.Name, err)
+				return fail(fmt.Sprintf("Error: could not save transactions: %s\n%s", err, payPalErrorHint(err)), 1)
+			}
+		}
+		if config.Stripe != nil {
+			fm := stripe.NewEmptyFileManager(year)
+			if err := stripe.GetAndSaveMonth(ctx, stripe.NewClient(config.Stripe), year, month, fm); err != nil {
+				return fail(fmt.Sprintf("Error: could not save Stripe transactions: %s", err), 1)
+			}
 		}
 
 	case "donors":
-		donors, err := donorInfo(year)
+		donors, err := donorInfo(accounts, year)
 		if err != nil {
-			exit(err.Error(), 1)
+			return fail(err.Error(), 1)
 		}
 
 		fmt.Printf("There were donations from %d donors:\n", len(donors))
 		for _, person := range donors {
 			if *emails {
-				fmt.Println(person.Email)
+				if person.Email != "" {
+					fmt.Println(person.Email)
+				}
 			} else {
 				anon := ""
 				if person.Anonymous {
 					anon = util.Colorize(util.BrightYellow, "{Wishes to be Anonymous}")
 				}
-				fmt.Printf("  %s: %s (%d) %s\n",
-					util.Colorize(util.Yellow, fmt.Sprintf("%s <%s>", person.Name, person.Email)),
-					person.Total, person.Count, anon)
+				who := person.Name
+				if person.Email != "" {
+					who = fmt.Sprintf("%s <%s>", person.Name, person.Email)
+				}
+				fmt.Printf("  %s: %s (%d) via %s %s\n",
+					util.Colorize(util.Yellow, who), person.Total, person.Count,
+					strings.Join(person.Sources, ", "), anon)
+				if *details {
+					if person.CountryCode != "" {
+						fmt.Printf("      Country: %s\n", person.CountryCode)
+					}
+					for _, note := range person.Notes {
+						fmt.Printf("      Note: %s\n", note)
+					}
+				}
 			}
 		}
 
 	case "donor-thanks":
-		donors, err := donorInfo(year)
+		donors, err := donorInfo(accounts, year)
 		if err != nil {
-			exit(err.Error(), 1)
+			return fail(err.Error(), 1)
 		}
 
 		fmt.Printf("## Donor Thanks for %d\n", year)
@@ -254,13 +613,115 @@ func main() {
 				anonCount++
 				continue
 			}
-			fmt.Printf("* %s\n", donor.Name)
+			line := "* " + donor.Name
+			if *details && donor.CountryCode != "" {
+				line += fmt.Sprintf(" (%s)", donor.CountryCode)
+			}
+			// Most donations come through PayPal, so only the others are marked
+			if !donor.OnlyThrough(sourcePayPal) {
+				line += fmt.Sprintf(" via %s", strings.Join(donor.Sources, ", "))
+			}
+			fmt.Println(line)
+			if *details {
+				for _, note := range donor.Notes {
+					fmt.Printf("    > %s\n", note)
+				}
+			}
 		}
 
 		fmt.Printf("\nThere were %d donors who wished to remain anonymous.", anonCount)
 
+	case "subscriptions":
+		start := util.MonthStart(year, time.January)
+		end := util.MonthEnd(year, time.December)
+		if monthGiven {
+			start = util.MonthStart(year, time.Month(month))
+			end = util.MonthEnd(year, time.Month(month))
+		}
+		if end.After(now()) {
+			end = now()
+		}
+
+		if err := printSubscriptions(accounts, start, end); err != nil {
+			return fail(fmt.Sprintf("Error: %v", err), 1)
+		}
+
+	case "classify":
+		if err := printClassification(accounts, year, month, monthGiven); err != nil {
+			return fail(fmt.Sprintf("Error: %v", err), 1)
+		}
+
+	case "import-paypal-csv":
+		if flagSet.NArg() == 0 {
+			return fail("Error: Please provide the CSV files to import", 1)
+		}
+		switch *dateOrder {
+		case paypal.DateOrderAuto, paypal.DateOrderDMY, paypal.DateOrderMDY, paypal.DateOrderYMD:
+		default:
+			return fail(fmt.Sprintf("Error: unknown date order %q, it should be dmy, mdy or ymd", *dateOrder), 1)
+		}
+		if len(accounts) > 1 {
+			return fail("Error: Please choose the PayPal account to import into with -account", 1)
+		}
+
+		if err := importPayPalCSV(accounts[0], flagSet.Args(), *dateOrder); err != nil {
+			return fail(fmt.Sprintf("Error: %v", wrapAccountError(accounts[0].Name, err)), 1)
+		}
+
+	case "import-opencollective":
+		if flagSet.NArg() == 0 {
+			return fail("Error: Please provide the Open Collective transaction exports to import", 1)
+		}
+
+		if err := importOpenCollectiveCSV(flagSet.Args()); err != nil {
+			return fail(fmt.Sprintf("Error: %v", err), 1)
+		}
+
+	case "import-github-sponsors":
+		if flagSet.NArg() == 0 {
+			return fail("Error: Please provide the GitHub Sponsors exports to import", 1)
+		}
+
+		if err := importGitHubSponsorsCSV(flagSet.Args()); err != nil {
+			return fail(fmt.Sprintf("Error: %v", err), 1)
+		}
+
+	case "migrate-storage":
+		if flagSet.NArg() != 2 {
+			return fail("Error: Please provide the storage to copy from and the one to copy to, json or bolt", 1)
+		}
+
+		if err := migrateStorage(accounts, flagSet.Arg(0), flagSet.Arg(1)); err != nil {
+			return fail(fmt.Sprintf("Error: %v", err), 1)
+		}
+
+	case "balance":
+		for _, acct := range accounts {
+			if err := saveBalanceSnapshot(ctx, acct.Name, paypal.NewBalanceSource(acct.Config)); err != nil {
+				err = wrapAccountError(acct.Name, err)
+				return fail(fmt.Sprintf("Error: could not get the PayPal balance: %v\n%s", err, payPalErrorHint(err)), 1)
+			}
+		}
+
+	case "reconcile":
+		balanced := true
+		for _, acct := range accounts {
+			ok, err := reconcile(acct, *fromDate, *toDate)
+			if err != nil {
+				return fail(fmt.Sprintf("Error: %v", wrapAccountError(acct.Name, err)), 1)
+			}
+			balanced = balanced && ok
+			fmt.Println("")
+		}
+		if !balanced {
+			return fail("The stored transactions do not account for the change in balance.", 2)
+		}
+		fmt.Printf("%s The stored transactions account for the change in balance.\n", greenCheck)
+
 	default:
 		fmt.Printf("Error: Unknown command %s.\n\n", cmd)
-		exit(fmt.Sprintf(usage, args[0]), 1)
+		return fail(fmt.Sprintf(usage, args[0]), 1)
 	}
+
+	return 0
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leavengood/donation_tracker/paypal"
+	"github.com/leavengood/donation_tracker/stripe"
+	"github.com/leavengood/donation_tracker/util"
+)
+
+func TestCollectDonorsWithoutStripeEmails(t *testing.T) {
+	march := func(day int) time.Time {
+		return time.Date(2020, time.March, day, 12, 0, 0, 0, time.UTC)
+	}
+	fm := paypal.NewEmptyFileManager("", 2020)
+	fm.Months[3] = paypal.Transactions{
+		{Timestamp: march(1), Type: "Donation", Status: "Completed", TransactionID: "1A", Name: "No Email",
+			Amt: 5, CurrencyCode: "USD"},
+	}
+	stripeFM := stripe.NewEmptyFileManager(2020)
+	stripeFM.Months[3] = stripe.Transactions{
+		{ID: "txn_1", Created: march(2), Type: stripe.TypeCharge, Amount: 25, Currency: "USD",
+			ChargeID: "ch_1", Name: "Ann"},
+		{ID: "txn_2", Created: march(3), Type: stripe.TypeCharge, Amount: 10, Currency: "USD",
+			ChargeID: "ch_2", Name: "Bob"},
+		{ID: "txn_3", Created: march(4), Type: stripe.TypeRefund, Amount: -10, Currency: "USD",
+			ChargeID: "ch_2"},
+	}
+
+	donors := collectDonors(fm, &otherSources{Stripe: stripeFM}, &util.DonorConfig{})
+
+	if len(donors) != 3 {
+		t.Fatalf("Expected 3 donors, but got %d: %v", len(donors), donors)
+	}
+	totals := map[string]float32{}
+	for _, donor := range donors {
+		totals[donor.Name] = donor.Total["USD"]
+	}
+	expected := map[string]float32{"Ann": 25, "Bob": 0, "No Email": 5}
+	for name, total := range expected {
+		if totals[name] != total {
+			t.Errorf("Expected %s to give %v, but got %v", name, total, totals[name])
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/leavengood/donation_tracker/opencollective"
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// importOpenCollectiveCSV merges the transactions in Open Collective
+// transaction exports into the saved years. Transactions which are already
+// saved are left alone.
+func importOpenCollectiveCSV(files []string) error {
+	all := opencollective.Transactions{}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		txns, err := opencollective.ParseCSV(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("could not import %s: %w", name, err)
+		}
+		fmt.Printf("Read %d transactions from %s\n", len(txns), name)
+		all = append(all, txns...)
+	}
+	if len(all) == 0 {
+		fmt.Println("There are no transactions to import")
+		return nil
+	}
+
+	byYear := map[int]opencollective.Transactions{}
+	for _, t := range all {
+		year, _ := util.MonthOf(t.CreatedAt)
+		byYear[year] = append(byYear[year], t)
+	}
+	years := make([]int, 0, len(byYear))
+	for year := range byYear {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+
+	for _, year := range years {
+		if _, err := mergeOpenCollectiveYear(year, byYear[year]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeOpenCollectiveYear adds the transactions to those saved for the year,
+// saving them if any are new, and returns them all.
+func mergeOpenCollectiveYear(year int, txns opencollective.Transactions) (opencollective.Transactions, error) {
+	previous, err := opencollective.LoadYear(year)
+	if err != nil {
+		return nil, err
+	}
+	// Merge will remove any duplicates
+	merged := previous.Merge(txns)
+	merged.Sort()
+
+	added := len(merged) - len(previous)
+	fmt.Printf("Open Collective %d: %d new of %d transactions\n", year, added, len(txns))
+	if added > 0 {
+		if err := opencollective.SaveYear(year, merged); err != nil {
+			return nil, fmt.Errorf("could not save Open Collective transactions for year %d: %w", year, err)
+		}
+	}
+
+	return merged, nil
+}
+
+// updateOpenCollectiveYear gets the transactions of the year from the Open
+// Collective API, when it is configured, and merges them into those saved.
+// Otherwise it loads what was imported.
+func updateOpenCollectiveYear(ctx context.Context, year int) (opencollective.Transactions, error) {
+	if config.OpenCollective == nil {
+		return opencollective.LoadYear(year)
+	}
+
+	start := util.MonthStart(year, time.January)
+	end := util.MonthEnd(year, time.December)
+	if end.After(now()) {
+		end = now()
+	}
+	fmt.Printf("Fetching Open Collective transactions for %d\n", year)
+	txns, err := opencollective.NewClient(config.OpenCollective).GetTransactions(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := mergeOpenCollectiveYear(year, txns)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("")
+
+	return merged, nil
+}
+
+// AddOpenCollective adds the Open Collective contributions, refunds and fees
+// to the monthly summaries and returns their total.
+func AddOpenCollective(year int, m util.MonthlySummaries, txns opencollective.Transactions) *util.Summary {
+	total := util.NewSummary()
+	if len(txns) == 0 {
+		return total
+	}
+
+	fmt.Printf("\n%s\n\n", util.Colorize(util.Green, "Open Collective Monthly Totals"))
+	sums := txns.Summarize()
+	for month := time.January; month <= time.December; month++ {
+		summary := sums[month]
+		if summary == nil {
+			continue
+		}
+		monthStr := util.Colorize(util.Blue, fmt.Sprintf("%s %d", month, year))
+		fmt.Printf("%s: There are %d Open Collective contributions\n",
+			monthStr, summary.OneTimeCount+summary.SubscriptionCount)
+		fmt.Printf("    Contributions: %s\n", summary)
+		for _, t := range txns {
+			if _, tMonth := util.MonthOf(t.CreatedAt); tMonth == month && t.IsReturn() {
+				fmt.Printf("    Refunded: %s\n", util.Colorize(util.Red, t.String()))
+			}
+		}
+
+		m.ForMonth(month).Add(summary)
+		total.Add(summary)
+	}
+	fmt.Printf("Total for Open Collective: %s\n", total)
+
+	return total
+}
@@ -0,0 +1,224 @@
+package opencollective
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultEndpoint is the Open Collective GraphQL API
+const DefaultEndpoint = "https://api.opencollective.com/graphql/v2"
+
+// pageSize is how many transactions are asked for at once
+const pageSize = 500
+
+// Config has the settings for getting transactions from the Open Collective
+// API. Only the transaction export is needed without it.
+type Config struct {
+	// The slug of the collective, as in its URL
+	Slug string `json:"slug"`
+	// A personal token, which is only needed for private details
+	Token string `json:"token,omitempty"`
+	// Only needed to use something besides the Open Collective API
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+const transactionsQuery = `query Transactions($slug: String!, $dateFrom: DateTime!, $dateTo: DateTime!, $limit: Int!, $offset: Int!) {
+  transactions(account: {slug: $slug}, dateFrom: $dateFrom, dateTo: $dateTo, limit: $limit, offset: $offset) {
+    totalCount
+    nodes {
+      id
+      legacyId
+      group
+      kind
+      type
+      description
+      createdAt
+      isRefund
+      amount { value currency }
+      netAmount { value }
+      platformFee { value }
+      hostFee { value }
+      paymentProcessorFee { value }
+      oppositeAccount { slug name isIncognito }
+      order { frequency }
+    }
+  }
+}`
+
+type money struct {
+	Value    float32 `json:"value"`
+	Currency string  `json:"currency"`
+}
+
+type transactionNode struct {
+	ID                  string    `json:"id"`
+	LegacyID            int       `json:"legacyId"`
+	Group               string    `json:"group"`
+	Kind                string    `json:"kind"`
+	Type                string    `json:"type"`
+	Description         string    `json:"description"`
+	CreatedAt           time.Time `json:"createdAt"`
+	IsRefund            bool      `json:"isRefund"`
+	Amount              money     `json:"amount"`
+	NetAmount           money     `json:"netAmount"`
+	PlatformFee         *money    `json:"platformFee"`
+	HostFee             *money    `json:"hostFee"`
+	PaymentProcessorFee *money    `json:"paymentProcessorFee"`
+	OppositeAccount     *struct {
+		Slug        string `json:"slug"`
+		Name        string `json:"name"`
+		IsIncognito bool   `json:"isIncognito"`
+	} `json:"oppositeAccount"`
+	Order *struct {
+		Frequency string `json:"frequency"`
+	} `json:"order"`
+}
+
+func (m *money) value() float32 {
+	if m == nil {
+		return 0
+	}
+	return m.Value
+}
+
+type transactionsResponse struct {
+	Data struct {
+		Transactions struct {
+			TotalCount int                `json:"totalCount"`
+			Nodes      []*transactionNode `json:"nodes"`
+		} `json:"transactions"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Client gets the transactions of a collective from the GraphQL API
+type Client struct {
+	config *Config
+	client *http.Client
+}
+
+func NewClient(config *Config) *Client {
+	return &Client{
+		config: config,
+		client: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// GetTransactions gets the transactions of the collective from start up to
+// end, sorted by date.
+func (c *Client) GetTransactions(ctx context.Context, start, end time.Time) (Transactions, error) {
+	result := Transactions{}
+
+	for offset := 0; ; {
+		resp, err := c.query(ctx, map[string]interface{}{
+			"slug":     c.config.Slug,
+			"dateFrom": start.UTC().Format(time.RFC3339),
+			"dateTo":   end.UTC().Format(time.RFC3339),
+			"limit":    pageSize,
+			"offset":   offset,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not get Open Collective transactions: %w", err)
+		}
+
+		nodes := resp.Data.Transactions.Nodes
+		for _, node := range nodes {
+			result = append(result, node.transaction())
+		}
+		// Fewer than asked for may be returned
+		offset += len(nodes)
+		if len(nodes) == 0 || offset >= resp.Data.Transactions.TotalCount {
+			break
+		}
+	}
+	result.Sort()
+
+	return result, nil
+}
+
+func (c *Client) query(ctx context.Context, variables map[string]interface{}) (*transactionsResponse, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     transactionsQuery,
+		"variables": variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := c.config.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.Token != "" {
+		req.Header.Set("Personal-Token", c.config.Token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Open Collective returned HTTP status %d: %s", resp.StatusCode, respBody)
+	}
+
+	result := &transactionsResponse{}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return nil, err
+	}
+	if len(result.Errors) > 0 {
+		messages := make([]string, len(result.Errors))
+		for i, e := range result.Errors {
+			messages[i] = e.Message
+		}
+		return nil, fmt.Errorf("Open Collective returned errors: %s", strings.Join(messages, "; "))
+	}
+
+	return result, nil
+}
+
+func (n *transactionNode) transaction() *Transaction {
+	t := &Transaction{
+		ID:                  n.ID,
+		LegacyID:            n.LegacyID,
+		Group:               n.Group,
+		CreatedAt:           n.CreatedAt.UTC(),
+		Kind:                n.Kind,
+		Type:                n.Type,
+		Description:         n.Description,
+		IsRefund:            n.IsRefund,
+		Amount:              n.Amount.Value,
+		PlatformFee:         n.PlatformFee.value(),
+		HostFee:             n.HostFee.value(),
+		PaymentProcessorFee: n.PaymentProcessorFee.value(),
+		NetAmount:           n.NetAmount.Value,
+		Currency:            n.Amount.Currency,
+	}
+	if n.OppositeAccount != nil {
+		t.ContributorName = n.OppositeAccount.Name
+		t.ContributorSlug = n.OppositeAccount.Slug
+		t.Incognito = n.OppositeAccount.IsIncognito || isIncognito(t.ContributorName, t.ContributorSlug)
+	}
+	if n.Order != nil {
+		t.Frequency = n.Order.Frequency
+	}
+
+	return t
+}
@@ -0,0 +1,124 @@
+package opencollective
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// Client
+//==============================================================================
+
+// newTestServer stands in for the GraphQL API, returning the nodes one per
+// page.
+func newTestServer(t *testing.T, nodes []map[string]interface{}, calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		assert.Equal(t, "token", r.Header.Get("Personal-Token"))
+
+		req := struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Variables["slug"] != "haiku" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"errors": []map[string]interface{}{{"message": "No collective found"}},
+			})
+			return
+		}
+
+		offset := int(req.Variables["offset"].(float64))
+		page := []map[string]interface{}{}
+		if offset < len(nodes) {
+			page = nodes[offset : offset+1]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"transactions": map[string]interface{}{"totalCount": len(nodes), "nodes": page},
+			},
+		})
+	}))
+}
+
+func TestClientGetTransactions(t *testing.T) {
+	nodes := []map[string]interface{}{
+		{"id": "a1", "group": "g1", "kind": "CONTRIBUTION", "type": "CREDIT", "createdAt": "2020-03-02T10:15:00Z",
+			"amount": map[string]interface{}{"value": 25, "currency": "USD"}, "netAmount": map[string]interface{}{"value": 23.97},
+			"paymentProcessorFee": map[string]interface{}{"value": -1.03},
+			"oppositeAccount":     map[string]interface{}{"slug": "bruce-wayne", "name": "Bruce Wayne"},
+			"order":               map[string]interface{}{"frequency": "ONETIME"}},
+		{"id": "b1", "group": "g2", "kind": "CONTRIBUTION", "type": "CREDIT", "createdAt": "2020-03-01T08:00:00Z",
+			"amount": map[string]interface{}{"value": 10, "currency": "USD"}, "netAmount": map[string]interface{}{"value": 10},
+			"oppositeAccount": map[string]interface{}{"slug": "guest-1", "name": "Guest", "isIncognito": true},
+			"order":           map[string]interface{}{"frequency": "MONTHLY"}},
+	}
+	calls := 0
+	ts := newTestServer(t, nodes, &calls)
+	defer ts.Close()
+
+	client := NewClient(&Config{Slug: "haiku", Token: "token", Endpoint: ts.URL})
+	txns, err := client.GetTransactions(context.Background(),
+		time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, len(txns))
+
+	// Sorted by date
+	assert.Equal(t, "b1", txns[0].ID)
+	assert.True(t, txns[0].Incognito)
+	assert.True(t, txns[0].IsSubscription())
+
+	assert.Equal(t, float32(25), txns[1].Amount)
+	assert.Equal(t, float32(-1.03), txns[1].Fee())
+	assert.Equal(t, "USD", txns[1].Currency)
+	assert.Equal(t, "Bruce Wayne", txns[1].ContributorName)
+	assert.True(t, txns[1].IsDonation())
+}
+
+func TestMergeExportAndClient(t *testing.T) {
+	nodes := []map[string]interface{}{
+		{"id": "a1", "legacyId": 2001, "kind": "CONTRIBUTION", "type": "CREDIT", "createdAt": "2020-03-02T10:15:00Z",
+			"amount": map[string]interface{}{"value": 25, "currency": "USD"}},
+		// Only the legacy ID is in the older export
+		{"id": "x1", "legacyId": 1001, "kind": "CONTRIBUTION", "type": "CREDIT", "createdAt": "2019-11-20T14:00:00Z",
+			"amount": map[string]interface{}{"value": 10, "currency": "EUR"}},
+		{"id": "e1", "legacyId": 2006, "kind": "CONTRIBUTION", "type": "CREDIT", "createdAt": "2020-05-01T09:00:00Z",
+			"amount": map[string]interface{}{"value": 5, "currency": "USD"}},
+	}
+	calls := 0
+	ts := newTestServer(t, nodes, &calls)
+	defer ts.Close()
+
+	client := NewClient(&Config{Slug: "haiku", Token: "token", Endpoint: ts.URL})
+	fetched, err := client.GetTransactions(context.Background(),
+		time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+
+	exported, err := ParseCSV(strings.NewReader(exportCSV))
+	assert.Nil(t, err)
+	legacy, err := ParseCSV(strings.NewReader(legacyExportCSV))
+	assert.Nil(t, err)
+	exported = append(exported, legacy...)
+
+	// Only the new contribution is added either way
+	assert.Equal(t, 7, len(exported.Merge(fetched)))
+	assert.Equal(t, 7, len(fetched.Merge(exported)))
+}
+
+func TestClientReturnsErrors(t *testing.T) {
+	calls := 0
+	ts := newTestServer(t, nil, &calls)
+	defer ts.Close()
+
+	client := NewClient(&Config{Slug: "nobody", Token: "token", Endpoint: ts.URL})
+	_, err := client.GetTransactions(context.Background(), time.Now(), time.Now())
+	assert.Contains(t, err.Error(), "No collective found")
+}
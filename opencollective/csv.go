@@ -0,0 +1,170 @@
+package opencollective
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvColumns maps each field to the headers the transaction export has used
+// for it, in lower case, in order of preference. The short ID is not used,
+// since it is not the whole ID the API has.
+var csvColumns = map[string][]string{
+	"id":          {"id"},
+	"legacyid":    {"legacyid"},
+	"group":       {"group", "shortgroup"},
+	"date":        {"datetime", "createdat", "date"},
+	"kind":        {"kind"},
+	"type":        {"type"},
+	"description": {"description"},
+	"isrefund":    {"isrefund"},
+	"amount":      {"amount", "amountincents"},
+	"platformfee": {"platformfee", "platformfeeincents"},
+	"hostfee":     {"hostfee", "hostfeeincents"},
+	"processor":   {"paymentprocessorfee", "paymentprocessorfeeincents"},
+	"net":         {"netamount", "netamountincents"},
+	"currency":    {"currency"},
+	"name":        {"oppositeaccountname", "fromcollectivename", "fromaccountname"},
+	"slug":        {"oppositeaccountslug", "fromcollectiveslug", "fromaccountslug"},
+	"frequency":   {"orderfrequency", "frequency"},
+}
+
+var csvDateFormats = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// ParseCSV parses the transaction export of an Open Collective collective.
+// Both the current export and the older one, with amounts in cents and no
+// kind, are understood.
+func ParseCSV(r io.Reader) (Transactions, error) {
+	// Skip any byte order mark
+	br := bufio.NewReader(r)
+	if bom, _ := br.Peek(3); string(bom) == "\ufeff" {
+		br.Discard(3)
+	}
+
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the CSV: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("the CSV is empty")
+	}
+
+	headers := map[string]int{}
+	for i, h := range rows[0] {
+		headers[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	columns := map[string]int{}
+	inCents := map[string]bool{}
+	for field, names := range csvColumns {
+		for _, name := range names {
+			if i, found := headers[name]; found {
+				columns[field] = i
+				inCents[field] = strings.HasSuffix(name, "incents")
+				break
+			}
+		}
+	}
+	for _, field := range []string{"id", "date", "type", "amount", "currency"} {
+		if _, found := columns[field]; !found {
+			return nil, fmt.Errorf("this does not look like an Open Collective transaction export, there is no %s column", field)
+		}
+	}
+
+	result := make(Transactions, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		get := func(field string) string {
+			c, found := columns[field]
+			if !found || c >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[c])
+		}
+		amount := func(field string) (float32, error) {
+			s := get(field)
+			if s == "" {
+				return 0, nil
+			}
+			f, err := strconv.ParseFloat(s, 32)
+			if err != nil {
+				return 0, fmt.Errorf("invalid %s %q", field, s)
+			}
+			if inCents[field] {
+				f /= 100
+			}
+			return float32(f), nil
+		}
+
+		if get("id") == "" {
+			continue
+		}
+		t := &Transaction{
+			ID:              get("id"),
+			Group:           get("group"),
+			Kind:            strings.ToUpper(get("kind")),
+			Type:            strings.ToUpper(get("type")),
+			Description:     get("description"),
+			IsRefund:        strings.EqualFold(get("isrefund"), "true"),
+			Currency:        strings.ToUpper(get("currency")),
+			ContributorName: get("name"),
+			ContributorSlug: get("slug"),
+			Frequency:       strings.ToUpper(get("frequency")),
+		}
+		// The older export has no kind, and only credits and their refunds are
+		// contributions
+		if t.Kind == "" && (t.Type == TypeCredit || t.IsRefund) {
+			t.Kind = KindContribution
+		}
+		t.Incognito = isIncognito(t.ContributorName, t.ContributorSlug)
+		// The older export only has the legacy ID, which is a number
+		legacyID := get("legacyid")
+		if legacyID == "" {
+			legacyID = t.ID
+		}
+		t.LegacyID, _ = strconv.Atoi(legacyID)
+
+		parsed := false
+		for _, format := range csvDateFormats {
+			if ts, err := time.Parse(format, get("date")); err == nil {
+				t.CreatedAt = ts.UTC()
+				parsed = true
+				break
+			}
+		}
+		if !parsed {
+			return nil, fmt.Errorf("line %d: invalid date %q", i+2, get("date"))
+		}
+
+		for field, value := range map[string]*float32{
+			"amount":      &t.Amount,
+			"platformfee": &t.PlatformFee,
+			"hostfee":     &t.HostFee,
+			"processor":   &t.PaymentProcessorFee,
+			"net":         &t.NetAmount,
+		} {
+			if *value, err = amount(field); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+2, err)
+			}
+		}
+		if _, found := columns["net"]; !found {
+			t.NetAmount = t.Amount + t.Fee()
+		}
+
+		result = append(result, t)
+	}
+	result.Sort()
+
+	return result, nil
+}
+
+// isIncognito is true for the accounts Open Collective uses for contributors
+// who give privately.
+func isIncognito(name, slug string) bool {
+	return strings.HasPrefix(strings.ToLower(slug), "incognito") || strings.EqualFold(name, "incognito")
+}
@@ -0,0 +1,97 @@
+package opencollective
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// ParseCSV
+//==============================================================================
+
+const exportCSV = `"datetime","id","legacyId","shortGroup","description","type","kind","isRefund","amount","paymentProcessorFee","platformFee","hostFee","netAmount","currency","oppositeAccountSlug","oppositeAccountName","orderFrequency"
+"2020-03-02T10:15:00.000Z","a1","2001","g1","Financial contribution to Haiku","CREDIT","CONTRIBUTION","false","25.00","-1.03","0.00","0.00","23.97","USD","bruce-wayne","Bruce Wayne","ONETIME"
+"2020-03-02T10:15:00.000Z","a2","2002","g1","Host Fee","DEBIT","HOST_FEE","false","-2.50","0.00","0.00","0.00","-2.50","USD","osc","Open Source Collective",""
+"2020-03-05T08:00:00.000Z","b1","2003","g2","Monthly financial contribution to Haiku","CREDIT","CONTRIBUTION","false","10.00","-0.59","0.00","0.00","9.41","USD","incognito-1234","Incognito","MONTHLY"
+"2020-04-01T09:00:00.000Z","c1","2004","g3","Refund of ""Financial contribution to Haiku""","DEBIT","CONTRIBUTION","true","-25.00","1.03","0.00","0.00","-23.97","USD","bruce-wayne","Bruce Wayne",""
+"2020-04-10T09:00:00.000Z","d1","2005","g4","Server hosting","DEBIT","EXPENSE","false","-100.00","0.00","0.00","0.00","-100.00","USD","hoster","Hoster Inc",""
+`
+
+const legacyExportCSV = `"id","createdAt","description","type","amountInCents","hostFeeInCents","platformFeeInCents","paymentProcessorFeeInCents","currency","fromCollectiveSlug","fromCollectiveName"
+"1001","2019-11-20 14:00:00","Monthly donation to Haiku","CREDIT","1000","-50","-50","-59","EUR","diana","Diana Prince"
+`
+
+func TestParseCSV(t *testing.T) {
+	txns, err := ParseCSV(strings.NewReader(exportCSV))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(txns))
+
+	contribution := txns[0]
+	assert.Equal(t, "a1", contribution.ID)
+	assert.Equal(t, 2001, contribution.LegacyID)
+	assert.Equal(t, "g1", contribution.Group)
+	assert.Equal(t, time.Date(2020, time.March, 2, 10, 15, 0, 0, time.UTC), contribution.CreatedAt)
+	assert.Equal(t, float32(25), contribution.Amount)
+	assert.Equal(t, float32(-1.03), contribution.Fee())
+	assert.Equal(t, "Bruce Wayne", contribution.ContributorName)
+	assert.True(t, contribution.IsDonation())
+
+	assert.True(t, txns[1].IsFee())
+
+	recurring := txns[2]
+	assert.True(t, recurring.IsSubscription())
+	assert.True(t, recurring.Incognito)
+
+	assert.True(t, txns[3].IsReturn())
+	assert.True(t, txns[3].IsRefund)
+
+	expense := txns[4]
+	assert.False(t, expense.IsDonation() || expense.IsSubscription() || expense.IsReturn() || expense.IsFee())
+}
+
+func TestParseLegacyCSV(t *testing.T) {
+	txns, err := ParseCSV(strings.NewReader(legacyExportCSV))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(txns))
+
+	txn := txns[0]
+	assert.Equal(t, 1001, txn.LegacyID)
+	assert.Equal(t, KindContribution, txn.Kind)
+	assert.Equal(t, float32(10), txn.Amount)
+	assert.InDelta(t, -1.59, txn.Fee(), 0.001)
+	assert.InDelta(t, 8.41, txn.NetAmount, 0.001)
+	// Only the description says it is recurring
+	assert.True(t, txn.IsSubscription())
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader("name,total\nBruce,10\n"))
+	assert.Contains(t, err.Error(), "does not look like an Open Collective transaction export")
+
+	// The short ID is not enough to tell it from what the API returns
+	_, err = ParseCSV(strings.NewReader("datetime,shortId,type,amount,currency\n2020-03-02,a1,CREDIT,25,USD\n"))
+	assert.Contains(t, err.Error(), "there is no id column")
+}
+
+//==============================================================================
+// Summarize
+//==============================================================================
+
+func TestSummarize(t *testing.T) {
+	txns, err := ParseCSV(strings.NewReader(exportCSV))
+	assert.Nil(t, err)
+
+	sums := txns.Summarize()
+	march := sums[time.March]
+	assert.Equal(t, 1, march.OneTimeCount)
+	assert.Equal(t, 1, march.SubscriptionCount)
+	// The separate host fee is included
+	assert.InDelta(t, -4.12, march.FeeAmt["USD"], 0.001)
+
+	april := sums[time.April]
+	assert.Equal(t, 1, april.ReturnedCount)
+	assert.Equal(t, float32(-25), april.GrossTotal()["USD"])
+}
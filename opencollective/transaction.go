@@ -0,0 +1,204 @@
+package opencollective
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// Transaction kinds which matter here
+const (
+	KindContribution        = "CONTRIBUTION"
+	KindAddedFunds          = "ADDED_FUNDS"
+	KindHostFee             = "HOST_FEE"
+	KindPlatformFee         = "PLATFORM_FEE"
+	KindPaymentProcessorFee = "PAYMENT_PROCESSOR_FEE"
+
+	TypeCredit = "CREDIT"
+	TypeDebit  = "DEBIT"
+)
+
+// feeKinds are the kinds of the separate transactions Open Collective uses for
+// fees taken from a contribution
+var feeKinds = map[string]bool{
+	KindHostFee:             true,
+	KindPlatformFee:         true,
+	KindPaymentProcessorFee: true,
+}
+
+// Transaction is an Open Collective transaction of the collective, from the
+// transaction export or the GraphQL API.
+type Transaction struct {
+	ID string `json:"id"`
+	// The numeric ID from before the GraphQL API, which the older export has
+	// in place of the ID
+	LegacyID int `json:"legacy_id,omitempty"`
+	// Transactions for the same contribution, such as its fees, share a group
+	Group       string    `json:"group,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Kind        string    `json:"kind"`
+	Type        string    `json:"type"`
+	Description string    `json:"description,omitempty"`
+	IsRefund    bool      `json:"is_refund,omitempty"`
+
+	// Fees are negative, like the amount of a debit
+	Amount              float32 `json:"amount"`
+	PlatformFee         float32 `json:"platform_fee,omitempty"`
+	HostFee             float32 `json:"host_fee,omitempty"`
+	PaymentProcessorFee float32 `json:"payment_processor_fee,omitempty"`
+	NetAmount           float32 `json:"net_amount"`
+	Currency            string  `json:"currency"`
+
+	ContributorName string `json:"contributor_name,omitempty"`
+	ContributorSlug string `json:"contributor_slug,omitempty"`
+	// Contributors can give privately, hiding who they are
+	Incognito bool `json:"incognito,omitempty"`
+	// ONETIME, MONTHLY or YEARLY, when known
+	Frequency string `json:"frequency,omitempty"`
+}
+
+// Fee is all of the fees of the transaction.
+func (t *Transaction) Fee() float32 {
+	return t.PlatformFee + t.HostFee + t.PaymentProcessorFee
+}
+
+func (t *Transaction) isContribution() bool {
+	return t.Kind == KindContribution || t.Kind == KindAddedFunds
+}
+
+// isRecurring is true for contributions made monthly or yearly. Older exports
+// only say so in the description.
+func (t *Transaction) isRecurring() bool {
+	switch t.Frequency {
+	case "MONTHLY", "YEARLY":
+		return true
+	case "":
+		desc := strings.ToLower(t.Description)
+		return strings.HasPrefix(desc, "monthly ") || strings.HasPrefix(desc, "yearly ")
+	}
+
+	return false
+}
+
+// IsDonation is true for one-time contributions.
+func (t *Transaction) IsDonation() bool {
+	return t.isContribution() && t.Type == TypeCredit && !t.IsRefund && !t.isRecurring()
+}
+
+// IsSubscription is true for monthly and yearly contributions.
+func (t *Transaction) IsSubscription() bool {
+	return t.isContribution() && t.Type == TypeCredit && !t.IsRefund && t.isRecurring()
+}
+
+// IsReturn is true for refunds of contributions.
+func (t *Transaction) IsReturn() bool {
+	return t.isContribution() && t.Type == TypeDebit && t.IsRefund
+}
+
+// IsFee is true for the separate transactions of fees taken from a
+// contribution.
+func (t *Transaction) IsFee() bool {
+	return feeKinds[t.Kind] && t.Type == TypeDebit
+}
+
+func (t *Transaction) String() string {
+	name := t.ContributorName
+	if t.Incognito {
+		name = "Incognito"
+	}
+
+	return fmt.Sprintf("%s: %s (%s) Open Collective %s, %s %0.02f (%0.02f fee) = %0.02f",
+		util.FormatDateTime(t.CreatedAt), name, t.ContributorSlug, strings.ToLower(t.Kind),
+		t.Currency, t.Amount, t.Fee(), t.NetAmount)
+}
+
+type Transactions []*Transaction
+
+// Sort sorts the transactions by date.
+func (p Transactions) Sort() {
+	sort.SliceStable(p, func(i, j int) bool {
+		return p[i].CreatedAt.Before(p[j].CreatedAt)
+	})
+}
+
+// Merge adds the other transactions which are not already in these, by
+// either their ID or legacy ID, so an export and the API can both be used.
+func (p Transactions) Merge(other Transactions) Transactions {
+	result := make(Transactions, 0, len(p)+len(other))
+	ids := map[string]bool{}
+	legacyIDs := map[int]bool{}
+
+	for _, t := range p {
+		ids[t.ID] = true
+		legacyIDs[t.LegacyID] = true
+		result = append(result, t)
+	}
+	for _, t := range other {
+		if !ids[t.ID] && (t.LegacyID == 0 || !legacyIDs[t.LegacyID]) {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
+// Summarize adds the contributions, refunds and fees to the summary of their
+// month.
+func (p Transactions) Summarize() util.MonthlySummaries {
+	result := make(util.MonthlySummaries)
+
+	for _, t := range p {
+		_, month := util.MonthOf(t.CreatedAt)
+
+		switch {
+		case t.IsDonation():
+			result.ForMonth(month).AddOneTime(t.Amount, t.Fee(), t.Currency)
+		case t.IsSubscription():
+			result.ForMonth(month).AddSubscription(t.Amount, t.Fee(), t.Currency)
+		case t.IsReturn():
+			result.ForMonth(month).AddReturn(t.Amount, t.Fee(), t.Currency)
+		case t.IsFee():
+			result.ForMonth(month).AddFee(t.Amount, t.Currency)
+		}
+	}
+
+	return result
+}
+
+func fileName(year int) string {
+	return filepath.Join(util.DataDir(), fmt.Sprintf("opencollective-%d.json", year))
+}
+
+// LoadYear loads the saved transactions of the year, which is empty if none
+// have been saved.
+func LoadYear(year int) (Transactions, error) {
+	f, err := os.Open(fileName(year))
+	if os.IsNotExist(err) {
+		return Transactions{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	txns := Transactions{}
+	if err := json.NewDecoder(f).Decode(&txns); err != nil {
+		return nil, fmt.Errorf("could not load %s: %w", fileName(year), err)
+	}
+
+	return txns, nil
+}
+
+// SaveYear saves the transactions of the year, replacing what was saved.
+func SaveYear(year int, txns Transactions) error {
+	if err := os.MkdirAll(util.DataDir(), 0755); err != nil {
+		return err
+	}
+	return util.WriteJSONFile(fileName(year), txns)
+}
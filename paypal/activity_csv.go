@@ -0,0 +1,496 @@
+package paypal
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date orders for ParseActivityCSV
+const (
+	DateOrderAuto = ""
+	DateOrderDMY  = "dmy"
+	DateOrderMDY  = "mdy"
+	DateOrderYMD  = "ymd"
+)
+
+// The columns of an Activity download CSV which are used
+const (
+	csvDate      = "date"
+	csvTime      = "time"
+	csvTimeZone  = "time zone"
+	csvName      = "name"
+	csvType      = "type"
+	csvStatus    = "status"
+	csvCurrency  = "currency"
+	csvGross     = "gross"
+	csvFee       = "fee"
+	csvNet       = "net"
+	csvFromEmail = "from email"
+	csvToEmail   = "to email"
+	csvID        = "transaction id"
+	csvItemTitle = "item title"
+	csvReference = "reference txn id"
+	csvInvoice   = "invoice number"
+	csvCustom    = "custom number"
+	csvNote      = "note"
+	csvCountry   = "country code"
+)
+
+// csvHeaders maps the headers PayPal uses in each language, in lower case, to
+// the columns above.
+var csvHeaders = map[string]string{
+	// English
+	"date":               csvDate,
+	"time":               csvTime,
+	"timezone":           csvTimeZone,
+	"time zone":          csvTimeZone,
+	"name":               csvName,
+	"type":               csvType,
+	"status":             csvStatus,
+	"currency":           csvCurrency,
+	"gross":              csvGross,
+	"fee":                csvFee,
+	"net":                csvNet,
+	"from email address": csvFromEmail,
+	"to email address":   csvToEmail,
+	"transaction id":     csvID,
+	"item title":         csvItemTitle,
+	"reference txn id":   csvReference,
+	"invoice number":     csvInvoice,
+	"custom number":      csvCustom,
+	"note":               csvNote,
+	"country code":       csvCountry,
+
+	// German
+	"datum":                        csvDate,
+	"uhrzeit":                      csvTime,
+	"zeitzone":                     csvTimeZone,
+	"typ":                          csvType,
+	"währung":                      csvCurrency,
+	"brutto":                       csvGross,
+	"gebühr":                       csvFee,
+	"netto":                        csvNet,
+	"absender e-mail-adresse":      csvFromEmail,
+	"empfänger e-mail-adresse":     csvToEmail,
+	"transaktionscode":             csvID,
+	"artikelbezeichnung":           csvItemTitle,
+	"zugehöriger transaktionscode": csvReference,
+	"rechnungsnummer":              csvInvoice,
+	"zollnummer":                   csvCustom,
+	"hinweis":                      csvNote,
+	"ländervorwahl":                csvCountry,
+
+	// French
+	"heure":                              csvTime,
+	"fuseau horaire":                     csvTimeZone,
+	"nom":                                csvName,
+	"état":                               csvStatus,
+	"devise":                             csvCurrency,
+	"brut":                               csvGross,
+	"frais":                              csvFee,
+	"de l'adresse email":                 csvFromEmail,
+	"à l'adresse email":                  csvToEmail,
+	"numéro de transaction":              csvID,
+	"titre de l'objet":                   csvItemTitle,
+	"numéro de transaction de référence": csvReference,
+	"numéro de facture":                  csvInvoice,
+	"numéro client":                      csvCustom,
+	"remarque":                           csvNote,
+	"code pays":                          csvCountry,
+
+	// Spanish
+	"fecha":                               csvDate,
+	"hora":                                csvTime,
+	"zona horaria":                        csvTimeZone,
+	"nombre":                              csvName,
+	"tipo":                                csvType,
+	"estado":                              csvStatus,
+	"divisa":                              csvCurrency,
+	"bruto":                               csvGross,
+	"tarifa":                              csvFee,
+	"neto":                                csvNet,
+	"correo electrónico del remitente":    csvFromEmail,
+	"correo electrónico del destinatario": csvToEmail,
+	"id. de transacción":                  csvID,
+	"título del artículo":                 csvItemTitle,
+	"id. de referencia de la transacción": csvReference,
+	"número de factura":                   csvInvoice,
+	"número personalizado":                csvCustom,
+	"nota":                                csvNote,
+	"código de país":                      csvCountry,
+}
+
+// csvRequired are the columns every file must have
+var csvRequired = []string{csvDate, csvTime, csvTimeZone, csvType, csvStatus, csvCurrency, csvGross, csvID}
+
+// csvTypes maps the transaction types of the CSV, in lower case, to the types
+// used by the NVP API. Types not listed are kept as they are.
+var csvTypes = map[string]string{
+	"donation payment":                      "Donation",
+	"donation":                              "Donation",
+	"spendenzahlung":                        "Donation",
+	"paiement de don":                       "Donation",
+	"pago de donación":                      "Donation",
+	"subscription payment":                  "Recurring Payment",
+	"recurring payment":                     "Recurring Payment",
+	"preapproved payment bill user payment": "Recurring Payment",
+	"abonnementzahlung":                     "Recurring Payment",
+	"paiement d'abonnement":                 "Recurring Payment",
+	"pago de suscripción":                   "Recurring Payment",
+	"payment refund":                        "Refund",
+	"refund":                                "Refund",
+	"rückzahlung":                           "Refund",
+	"remboursement":                         "Refund",
+	"reembolso":                             "Refund",
+	"payment reversal":                      "Reversal",
+	"reversal":                              "Reversal",
+	"chargeback":                            "Chargeback",
+	"general currency conversion":           "Currency Conversion",
+	"currency conversion":                   "Currency Conversion",
+	"allgemeine währungsumrechnung":         "Currency Conversion",
+	"conversion de devise standard":         "Currency Conversion",
+	"conversión de divisa general":          "Currency Conversion",
+	"general withdrawal":                    "Withdrawal",
+	"general payment":                       "Payment",
+	"website payment":                       "Payment",
+	"express checkout payment":              "Payment",
+	"mobile payment":                        "Payment",
+}
+
+// csvStatuses maps the statuses of the CSV, in lower case, to those used by
+// the NVP API. Statuses not listed are kept as they are.
+var csvStatuses = map[string]string{
+	"abgeschlossen": "Completed",
+	"terminé":       "Completed",
+	"completado":    "Completed",
+	"ausstehend":    "Pending",
+	"en attente":    "Pending",
+	"pendiente":     "Pending",
+	"abgelehnt":     "Denied",
+	"refusé":        "Denied",
+	"denegado":      "Denied",
+}
+
+// csvZones are the fixed offsets of the time zone abbreviations PayPal uses,
+// in hours.
+var csvZones = map[string]int{
+	"UTC":  0,
+	"GMT":  0,
+	"WET":  0,
+	"BST":  1,
+	"WEST": 1,
+	"CET":  1,
+	"MEZ":  1,
+	"CEST": 2,
+	"MESZ": 2,
+	"EET":  2,
+	"EEST": 3,
+	"MSK":  3,
+	"JST":  9,
+	"AEST": 10,
+	"AEDT": 11,
+	"NZST": 12,
+	"NZDT": 13,
+	"HST":  -10,
+	"AKST": -9,
+	"AKDT": -8,
+	"PST":  -8,
+	"PDT":  -7,
+	"MST":  -7,
+	"MDT":  -6,
+	"CST":  -6,
+	"CDT":  -5,
+	"EST":  -5,
+	"EDT":  -4,
+}
+
+var csvOffsetRegexp = regexp.MustCompile(`^(?:GMT|UTC)?([+-])(\d{1,2}):?(\d{2})?$`)
+
+// csvZone returns the location for a time zone from the CSV, which is either
+// an abbreviation, an offset like GMT+01:00 or a name like Europe/Berlin.
+func csvZone(zone string) (*time.Location, error) {
+	zone = strings.TrimSpace(zone)
+	if hours, found := csvZones[strings.ToUpper(zone)]; found {
+		return time.FixedZone(zone, hours*60*60), nil
+	}
+	if match := csvOffsetRegexp.FindStringSubmatch(strings.ToUpper(zone)); match != nil {
+		hours, _ := strconv.Atoi(match[2])
+		minutes, _ := strconv.Atoi(match[3])
+		offset := hours*60*60 + minutes*60
+		if match[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(zone, offset), nil
+	}
+	if loc, err := time.LoadLocation(zone); err == nil && zone != "" {
+		return loc, nil
+	}
+
+	return nil, fmt.Errorf("unknown time zone %q", zone)
+}
+
+// parseCSVAmount parses an amount in any of the formats PayPal uses, such as
+// 1,234.56, 1.234,56 or 1 234,56. The last separator is the decimal one if it
+// has at most two digits after it, as amounts never have more.
+func parseCSVAmount(s string) (float32, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	clean := strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "", "'", "").Replace(s)
+
+	decimal := strings.LastIndexAny(clean, ".,")
+	if decimal >= 0 && len(clean)-decimal-1 > 2 {
+		decimal = -1
+	}
+	var b strings.Builder
+	for i, r := range clean {
+		switch {
+		case i == decimal:
+			b.WriteByte('.')
+		case r == '.' || r == ',':
+			// A thousands separator
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	f, err := strconv.ParseFloat(b.String(), 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	return float32(f), nil
+}
+
+var csvDateRegexp = regexp.MustCompile(`^(\d{1,4})[./-](\d{1,2})[./-](\d{1,4})$`)
+
+// detectDateOrder works out the order of the dates of a file, which depends on
+// the language and country of the account. Day first is assumed when it is
+// ambiguous, unless the headers are in English.
+func detectDateOrder(dates []string, english bool) string {
+	dayFirst, monthFirst := false, false
+	for _, date := range dates {
+		match := csvDateRegexp.FindStringSubmatch(strings.TrimSpace(date))
+		if match == nil {
+			continue
+		}
+		if len(match[1]) == 4 {
+			return DateOrderYMD
+		}
+		first, _ := strconv.Atoi(match[1])
+		second, _ := strconv.Atoi(match[2])
+		if first > 12 {
+			dayFirst = true
+		}
+		if second > 12 {
+			monthFirst = true
+		}
+	}
+
+	switch {
+	case dayFirst && !monthFirst:
+		return DateOrderDMY
+	case monthFirst && !dayFirst:
+		return DateOrderMDY
+	case english:
+		return DateOrderMDY
+	}
+
+	return DateOrderDMY
+}
+
+// parseCSVTime parses the date and time of a row in the given order and zone.
+func parseCSVTime(date, clock, order string, loc *time.Location) (time.Time, error) {
+	match := csvDateRegexp.FindStringSubmatch(strings.TrimSpace(date))
+	if match == nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", date)
+	}
+	var y, m, d int
+	switch order {
+	case DateOrderYMD:
+		y, m, d = atoi(match[1]), atoi(match[2]), atoi(match[3])
+	case DateOrderMDY:
+		m, d, y = atoi(match[1]), atoi(match[2]), atoi(match[3])
+	default:
+		d, m, y = atoi(match[1]), atoi(match[2]), atoi(match[3])
+	}
+	if y < 100 {
+		y += 2000
+	}
+	if m < 1 || m > 12 || d < 1 || d > 31 {
+		return time.Time{}, fmt.Errorf("invalid date %q for the %s date order", date, order)
+	}
+
+	c, err := time.Parse("15:04:05", strings.TrimSpace(clock))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", clock)
+	}
+
+	return time.Date(y, time.Month(m), d, c.Hour(), c.Minute(), c.Second(), 0, loc).UTC(), nil
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
+
+// ParseActivityCSV parses the CSV from the Activity download of the PayPal
+// website into transactions like those from the API. The headers, types,
+// statuses, dates and amounts can be in any of the languages PayPal uses. The
+// date order is detected from the dates if it is DateOrderAuto.
+func ParseActivityCSV(r io.Reader, dateOrder string) (Transactions, error) {
+	// Excel and PayPal often start the file with a byte order mark
+	br := bufio.NewReader(r)
+	if bom, _ := br.Peek(3); string(bom) == "\ufeff" {
+		br.Discard(3)
+	}
+
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the CSV: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("the CSV is empty")
+	}
+
+	columns := map[string]int{}
+	english := false
+	for i, header := range rows[0] {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if header == "gross" {
+			english = true
+		}
+		if column := csvHeaders[header]; column != "" {
+			if _, found := columns[column]; !found {
+				columns[column] = i
+			}
+		}
+	}
+	missing := []string{}
+	for _, column := range csvRequired {
+		if _, found := columns[column]; !found {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("this does not look like a PayPal Activity download, there are no %s columns",
+			strings.Join(missing, ", "))
+	}
+
+	rows = rows[1:]
+	get := func(row []string, column string) string {
+		i, found := columns[column]
+		if !found || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	if dateOrder == DateOrderAuto {
+		dates := make([]string, 0, len(rows))
+		for _, row := range rows {
+			dates = append(dates, get(row, csvDate))
+		}
+		dateOrder = detectDateOrder(dates, english)
+	}
+
+	result := make(Transactions, 0, len(rows))
+	for i, row := range rows {
+		t, err := csvTransaction(row, get, dateOrder)
+		if err != nil {
+			// The header is line 1
+			return nil, fmt.Errorf("line %d: %w", i+2, err)
+		}
+		if t != nil {
+			result = append(result, t)
+		}
+	}
+	result.Sort()
+
+	return result, nil
+}
+
+// csvTransaction converts one row, returning nil for empty rows.
+func csvTransaction(row []string, get func([]string, string) string, dateOrder string) (*Transaction, error) {
+	if get(row, csvID) == "" {
+		return nil, nil
+	}
+
+	loc, err := csvZone(get(row, csvTimeZone))
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := parseCSVTime(get(row, csvDate), get(row, csvTime), dateOrder, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	amt, err := parseCSVAmount(get(row, csvGross))
+	if err != nil {
+		return nil, err
+	}
+	fee, err := parseCSVAmount(get(row, csvFee))
+	if err != nil {
+		return nil, err
+	}
+	net := amt + fee
+	if s := get(row, csvNet); s != "" {
+		if net, err = parseCSVAmount(s); err != nil {
+			return nil, err
+		}
+	}
+
+	txnType := get(row, csvType)
+	if t, found := csvTypes[strings.ToLower(txnType)]; found {
+		txnType = t
+	}
+	status := get(row, csvStatus)
+	if s, found := csvStatuses[strings.ToLower(status)]; found {
+		status = s
+	}
+
+	// The email is of the other party, who is the receiver when money is sent
+	email := get(row, csvFromEmail)
+	if amt < 0 {
+		email = get(row, csvToEmail)
+	}
+
+	t := &Transaction{
+		Timestamp:     timestamp,
+		Type:          txnType,
+		Email:         email,
+		Name:          get(row, csvName),
+		TransactionID: get(row, csvID),
+		Status:        status,
+		Amt:           amt,
+		FeeAmt:        fee,
+		NetAmt:        net,
+		CurrencyCode:  strings.ToUpper(get(row, csvCurrency)),
+		Note:          get(row, csvNote),
+		CountryCode:   get(row, csvCountry),
+		Custom:        get(row, csvCustom),
+		InvoiceID:     get(row, csvInvoice),
+		ItemName:      get(row, csvItemTitle),
+	}
+
+	// Subscription payments refer to their recurring payments profile, which
+	// have IDs starting with I-, while returns and conversions refer to the
+	// transaction they are for
+	if ref := get(row, csvReference); strings.HasPrefix(ref, "I-") {
+		t.ProfileID = ref
+	} else {
+		t.ParentTransactionID = ref
+	}
+
+	return t, nil
+}
@@ -0,0 +1,125 @@
+package paypal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// ParseActivityCSV
+//==============================================================================
+
+const englishActivityCSV = "\ufeff" + `"Date","Time","TimeZone","Name","Type","Status","Currency","Gross","Fee","Net","From Email Address","To Email Address","Transaction ID","Item Title","Reference Txn ID","Note","Country Code","Balance Impact"
+"01/02/2020","10:30:00","PST","Bruce Wayne","Donation Payment","Completed","USD","1,000.00","-29.30","970.70","bruce@wayneenterprises.com","donations@haiku-os.org","1A","Haiku donation","","Keep it up","US","Credit"
+"01/03/2020","23:15:00","PST","Clark Kent","Subscription Payment","Completed","USD","10.00","-0.59","9.41","clarkkent@gmail.com","donations@haiku-os.org","1B","","I-PROFILE","","US","Credit"
+"01/20/2020","08:00:00","PST","Bruce Wayne","Payment Refund","Completed","USD","-50.00","0.00","-50.00","donations@haiku-os.org","bruce@wayneenterprises.com","1C","","1A","","","Debit"
+
+`
+
+const germanActivityCSV = `"Datum","Uhrzeit","Zeitzone","Name","Typ","Status","Währung","Brutto","Gebühr","Netto","Absender E-Mail-Adresse","Empfänger E-Mail-Adresse","Transaktionscode","Zugehöriger Transaktionscode","Hinweis"
+"05.07.2020","14:00:00","MESZ","Diana Prince","Spendenzahlung","Abgeschlossen","EUR","1.234,56","-23,80","1.210,76","diana@themyscira.gr","spenden@haiku-os.org","2A","",""
+"05.07.2020","14:00:00","MESZ","","Allgemeine Währungsumrechnung","Abgeschlossen","EUR","-1.210,76","0,00","-1.210,76","","","2B","2A",""
+`
+
+func TestParseActivityCSVInEnglish(t *testing.T) {
+	txns, err := ParseActivityCSV(strings.NewReader(englishActivityCSV), DateOrderAuto)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(txns))
+
+	donation := txns[0]
+	assert.Equal(t, time.Date(2020, time.January, 2, 18, 30, 0, 0, time.UTC), donation.Timestamp)
+	assert.Equal(t, "Donation", donation.Type)
+	assert.Equal(t, "Completed", donation.Status)
+	assert.Equal(t, "bruce@wayneenterprises.com", donation.Email)
+	assert.Equal(t, float32(1000), donation.Amt)
+	assert.Equal(t, float32(-29.30), donation.FeeAmt)
+	assert.Equal(t, float32(970.70), donation.NetAmt)
+	assert.Equal(t, "Keep it up", donation.Note)
+	assert.Equal(t, "Haiku donation", donation.ItemName)
+	assert.True(t, donation.IsDonation())
+
+	// Late at night in California is the next day in UTC
+	subscription := txns[1]
+	assert.Equal(t, time.Date(2020, time.January, 4, 7, 15, 0, 0, time.UTC), subscription.Timestamp)
+	assert.Equal(t, "I-PROFILE", subscription.ProfileID)
+	assert.Equal(t, "", subscription.ParentTransactionID)
+	assert.True(t, subscription.IsSubscription())
+
+	refund := txns[2]
+	assert.Equal(t, "Refund", refund.Type)
+	assert.Equal(t, "bruce@wayneenterprises.com", refund.Email)
+	assert.Equal(t, "1A", refund.ParentTransactionID)
+	assert.True(t, refund.IsReturn())
+}
+
+func TestParseActivityCSVInGerman(t *testing.T) {
+	txns, err := ParseActivityCSV(strings.NewReader(germanActivityCSV), DateOrderAuto)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(txns))
+
+	assert.Equal(t, time.Date(2020, time.July, 5, 12, 0, 0, 0, time.UTC), txns[0].Timestamp)
+	assert.Equal(t, "Donation", txns[0].Type)
+	assert.Equal(t, "Completed", txns[0].Status)
+	assert.Equal(t, float32(1234.56), txns[0].Amt)
+	assert.Equal(t, float32(1210.76), txns[0].NetAmt)
+
+	assert.True(t, txns[1].IsConversion())
+	assert.Equal(t, "2A", txns[1].ParentTransactionID)
+}
+
+func TestParseActivityCSVWithDateOrder(t *testing.T) {
+	txns, err := ParseActivityCSV(strings.NewReader(germanActivityCSV), DateOrderMDY)
+	assert.Nil(t, err)
+	assert.Equal(t, time.May, txns[0].Timestamp.Month())
+}
+
+func TestParseActivityCSVErrors(t *testing.T) {
+	_, err := ParseActivityCSV(strings.NewReader("Date,Name\n01/02/2020,Bruce\n"), DateOrderAuto)
+	assert.Contains(t, err.Error(), "does not look like a PayPal Activity download")
+
+	badZone := strings.Replace(englishActivityCSV, `"PST"`, `"XYZ"`, 1)
+	_, err = ParseActivityCSV(strings.NewReader(badZone), DateOrderAuto)
+	assert.Equal(t, `line 2: unknown time zone "XYZ"`, err.Error())
+}
+
+//==============================================================================
+// parseCSVAmount
+//==============================================================================
+
+func TestParseCSVAmount(t *testing.T) {
+	for s, expected := range map[string]float32{
+		"":              0,
+		"10":            10,
+		"-0.59":         -0.59,
+		"1,234.56":      1234.56,
+		"1.234,56":      1234.56,
+		"1\u00a0234,56": 1234.56,
+		"1'234.56":      1234.56,
+		"1,234":         1234,
+		"1.234.567":     1234567,
+		"12,5":          12.5,
+	} {
+		amt, err := parseCSVAmount(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, expected, amt, s)
+	}
+
+	_, err := parseCSVAmount("ten")
+	assert.NotNil(t, err)
+}
+
+//==============================================================================
+// detectDateOrder
+//==============================================================================
+
+func TestDetectDateOrder(t *testing.T) {
+	assert.Equal(t, DateOrderDMY, detectDateOrder([]string{"01/02/2020", "25/02/2020"}, true))
+	assert.Equal(t, DateOrderMDY, detectDateOrder([]string{"01/02/2020", "02/25/2020"}, false))
+	assert.Equal(t, DateOrderYMD, detectDateOrder([]string{"2020/02/01"}, false))
+	// Ambiguous dates depend on the language
+	assert.Equal(t, DateOrderMDY, detectDateOrder([]string{"01/02/2020"}, true))
+	assert.Equal(t, DateOrderDMY, detectDateOrder([]string{"01.02.2020"}, false))
+}
@@ -0,0 +1,105 @@
+package paypal
+
+import (
+	"bytes"
+	"context"
+	"sync"
+)
+
+// DefaultBackfillWorkers is how many months are fetched at once by default
+const DefaultBackfillWorkers = 4
+
+// monthFetch is the progress and result of getting one month
+type monthFetch struct {
+	output bytes.Buffer
+	err    error
+	done   chan struct{}
+}
+
+// GetAndSaveMonths gets and saves each of the months like GetAndSaveMonth,
+// with up to workers months being fetched at once. The progress of each month
+// is printed in order of the months, as soon as the months before it are done.
+// The first error cancels the months not done yet and is returned, while the
+// months which were already fetched stay saved.
+func GetAndSaveMonths(ctx context.Context, src TransactionSource, year int, months []int, fm *FileManager, workers int) error {
+	if workers < 1 {
+		workers = DefaultBackfillWorkers
+	}
+	if workers > len(months) {
+		workers = len(months)
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	fetches := make([]*monthFetch, len(months))
+	for i := range fetches {
+		fetches[i] = &monthFetch{done: make(chan struct{})}
+	}
+
+	var firstErr error
+	var errOnce sync.Once
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				f := fetches[i]
+				f.err = GetAndSaveMonth(WithOutput(ctx, &f.output), src, year, months[i], fm)
+				if f.err != nil {
+					errOnce.Do(func() { firstErr = f.err })
+					cancel()
+				}
+				close(f.done)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range months {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	// Print the progress of each month in order, stopping at the first month
+	// which was never started
+	out := output(ctx)
+	for _, f := range fetches {
+		select {
+		case <-f.done:
+		case <-finished:
+			select {
+			case <-f.done:
+			default:
+				return firstErrOr(parent, firstErr)
+			}
+		}
+		f.output.WriteTo(out)
+	}
+	<-finished
+
+	return firstErrOr(parent, firstErr)
+}
+
+// firstErrOr returns the error, or that of the context when the batch was
+// stopped without one, such as by Ctrl-C.
+func firstErrOr(ctx context.Context, err error) error {
+	if err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
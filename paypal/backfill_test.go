@@ -0,0 +1,107 @@
+package paypal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// GetAndSaveMonths
+//==============================================================================
+
+// slowSource returns one transaction for each month, taking longer for the
+// earlier months so they finish last, and fails for the failing month.
+type slowSource struct {
+	failing int
+
+	mu      sync.Mutex
+	running int
+	most    int
+	calls   int
+}
+
+func (s *slowSource) GetTransactions(ctx context.Context, startDate, endDate string) (Transactions, error) {
+	start, _, err := parseDateRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	month := int(start.Month())
+
+	s.mu.Lock()
+	s.calls++
+	s.running++
+	if s.running > s.most {
+		s.most = s.running
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running--
+		s.mu.Unlock()
+	}()
+
+	fmt.Fprintf(output(ctx), "working on %d\n", month)
+	if month == s.failing {
+		return nil, errors.New("PayPal is down")
+	}
+	select {
+	case <-time.After(time.Duration(13-month) * 5 * time.Millisecond):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return Transactions{{Timestamp: start, TransactionID: fmt.Sprintf("M%02d", month)}}, nil
+}
+
+func TestGetAndSaveMonths(t *testing.T) {
+	chdirForTest(t)
+
+	src := &slowSource{}
+	fm := NewEmptyFileManager("", 2020)
+	out := &bytes.Buffer{}
+	months := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+
+	err := GetAndSaveMonths(WithOutput(context.Background(), out), src, 2020, months, fm, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 12, src.calls)
+	assert.Equal(t, 3, src.most)
+	assert.Equal(t, months, fm.GetExistingMonths())
+	assert.Equal(t, "M07", fm.Months[7][0].TransactionID)
+
+	// The progress is in order even though later months finished first
+	last := -1
+	for _, month := range months {
+		i := strings.Index(out.String(), fmt.Sprintf("...working on %d\n", month))
+		assert.Greater(t, i, last, "month %d", month)
+		last = i
+	}
+
+	// The months were saved
+	loaded, err := NewFileManager("", 2020)
+	assert.Nil(t, err)
+	assert.Equal(t, months, loaded.GetExistingMonths())
+}
+
+func TestGetAndSaveMonthsStopsOnError(t *testing.T) {
+	chdirForTest(t)
+
+	src := &slowSource{failing: 2}
+	fm := NewEmptyFileManager("", 2020)
+	out := &bytes.Buffer{}
+
+	err := GetAndSaveMonths(WithOutput(context.Background(), out), src, 2020,
+		[]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, fm, 2)
+	assert.Contains(t, err.Error(), "could not get transactions for February 2020: PayPal is down")
+	// The rest of the batch was cancelled
+	assert.Less(t, src.calls, 12)
+	assert.NotContains(t, fm.GetExistingMonths(), 2)
+	assert.Contains(t, out.String(), "failed.")
+}
@@ -0,0 +1,255 @@
+package paypal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/leavengood/donation_tracker/util"
+)
+
+const (
+	balanceHistoryFile = "paypal-balances.json"
+
+	restBalancesPath = "/v1/reporting/balances"
+)
+
+// BalanceSnapshot is the balance of the PayPal account in every currency at
+// one time.
+type BalanceSnapshot struct {
+	Time     time.Time            `json:"time"`
+	Balances util.CurrencyAmounts `json:"balances"`
+}
+
+// BalanceSource can get the current balance of the PayPal account.
+type BalanceSource interface {
+	GetBalance(ctx context.Context) (*BalanceSnapshot, error)
+}
+
+// NewBalanceSource returns the backend selected by the API in the config,
+// defaulting to the NVP API.
+func NewBalanceSource(config *Config) BalanceSource {
+	if config.API == APIRest {
+		return NewRestClient(config)
+	}
+
+	return NewClient(config)
+}
+
+// GetBalance calls the NVP GetBalance method for the balances in all
+// currencies.
+func (c *Client) GetBalance(ctx context.Context) (*BalanceSnapshot, error) {
+	nvp, err := c.callNvpApi(ctx, "GetBalance", "117.0", NameValues{"RETURNALLCURRENCIES": "1"})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &BalanceSnapshot{
+		Time:     now().UTC().Truncate(time.Second),
+		Balances: util.CurrencyAmounts{},
+	}
+	if ts, err := time.Parse(PayPalDateFormat, nvp.Fields["TIMESTAMP"]); err == nil {
+		snapshot.Time = ts
+	}
+	for _, num := range nvp.ListNumbers() {
+		item := nvp.List[num]
+		amt, err := strconv.ParseFloat(item["AMT"], 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid balance %q for %s: %w", item["AMT"], item["CURRENCYCODE"], err)
+		}
+		snapshot.Balances[item["CURRENCYCODE"]] += float32(amt)
+	}
+
+	return snapshot, nil
+}
+
+type restBalancesResponse struct {
+	Balances []struct {
+		Currency     string    `json:"currency"`
+		TotalBalance restMoney `json:"total_balance"`
+	} `json:"balances"`
+	AsOfTime string `json:"as_of_time"`
+}
+
+// GetBalance gets the balances in all currencies from the REST Reporting API.
+func (c *RestClient) GetBalance(ctx context.Context) (*BalanceSnapshot, error) {
+	v := url.Values{}
+	v.Set("currency_code", "ALL")
+
+	resp := restBalancesResponse{}
+	err := c.doAuthorized(ctx, &resp, func(ctx context.Context, token string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+			c.config.RestEndpoint+restBalancesPath+"?"+v.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get the PayPal balance: %w", err)
+	}
+
+	snapshot := &BalanceSnapshot{
+		Time:     now().UTC().Truncate(time.Second),
+		Balances: util.CurrencyAmounts{},
+	}
+	if ts, err := time.Parse(RestDateFormat, resp.AsOfTime); err == nil {
+		snapshot.Time = ts.UTC()
+	}
+	for _, b := range resp.Balances {
+		snapshot.Balances[b.Currency] += b.TotalBalance.amount()
+	}
+
+	return snapshot, nil
+}
+
+// BalanceHistory stores the balance snapshots in the data directory, oldest
+// first.
+type BalanceHistory struct {
+	filename  string
+	Snapshots []*BalanceSnapshot
+}
+
+// LoadBalanceHistory loads the balance snapshots from the data directory of
+// the account. It is empty if none have been saved yet.
+func LoadBalanceHistory(account string) (*BalanceHistory, error) {
+	history := &BalanceHistory{
+		filename:  filepath.Join(AccountDir(account), balanceHistoryFile),
+		Snapshots: []*BalanceSnapshot{},
+	}
+
+	f, err := os.Open(history.filename)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&history.Snapshots); err != nil {
+		return nil, fmt.Errorf("could not load %s: %w", history.filename, err)
+	}
+
+	return history, nil
+}
+
+// Add adds a snapshot, keeping the snapshots sorted.
+func (h *BalanceHistory) Add(snapshot *BalanceSnapshot) {
+	h.Snapshots = append(h.Snapshots, snapshot)
+	sort.SliceStable(h.Snapshots, func(i, j int) bool {
+		return h.Snapshots[i].Time.Before(h.Snapshots[j].Time)
+	})
+}
+
+// OnDate returns the latest snapshot taken on the given day, in the
+// organization's time zone, or nil.
+func (h *BalanceHistory) OnDate(date time.Time) *BalanceSnapshot {
+	var result *BalanceSnapshot
+
+	y, m, d := date.Date()
+	for _, s := range h.Snapshots {
+		sy, sm, sd := util.InLocation(s.Time).Date()
+		if sy == y && sm == m && sd == d {
+			result = s
+		}
+	}
+
+	return result
+}
+
+// Save writes the snapshots back to the data directory.
+func (h *BalanceHistory) Save() error {
+	if err := os.MkdirAll(filepath.Dir(h.filename), 0755); err != nil {
+		return err
+	}
+	return util.WriteJSONFile(h.filename, h.Snapshots)
+}
+
+// noBalanceChange are statuses of transactions which do not move any money
+var noBalanceChange = map[string]bool{
+	"Pending":   true,
+	"Denied":    true,
+	"Unclaimed": true,
+}
+
+// Reconciliation compares the change in balance between two snapshots with
+// the transactions made in between.
+type Reconciliation struct {
+	From, To *BalanceSnapshot
+	// The net amounts of the transactions after From up to To
+	Net   util.CurrencyAmounts
+	Count int
+}
+
+// Reconcile sums the net amounts, so including fees, withdrawals and
+// conversions, of the transactions after the first snapshot up to the second.
+func Reconcile(from, to *BalanceSnapshot, txns Transactions) *Reconciliation {
+	result := &Reconciliation{
+		From: from,
+		To:   to,
+		Net:  util.CurrencyAmounts{},
+	}
+
+	for _, t := range txns {
+		if !t.Timestamp.After(from.Time) || t.Timestamp.After(to.Time) || noBalanceChange[t.Status] {
+			continue
+		}
+		result.Net[t.CurrencyCode] += t.NetAmt
+		result.Count++
+	}
+
+	return result
+}
+
+// Currencies returns every currency in the snapshots or the transactions,
+// sorted.
+func (r *Reconciliation) Currencies() []string {
+	seen := map[string]bool{}
+	for _, ca := range []util.CurrencyAmounts{r.From.Balances, r.To.Balances, r.Net} {
+		for currency := range ca {
+			seen[currency] = true
+		}
+	}
+
+	result := make([]string, 0, len(seen))
+	for currency := range seen {
+		result = append(result, currency)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// Change is how much the balance changed in the currency.
+func (r *Reconciliation) Change(currency string) float32 {
+	return r.To.Balances[currency] - r.From.Balances[currency]
+}
+
+// Gap is how much of the change in the balance of the currency is not
+// accounted for by the transactions, rounded to cents.
+func (r *Reconciliation) Gap(currency string) float32 {
+	gap := float64(r.Change(currency) - r.Net[currency])
+	return float32(math.Round(gap*100) / 100)
+}
+
+// Balanced is true if there is no gap in any currency.
+func (r *Reconciliation) Balanced() bool {
+	for _, currency := range r.Currencies() {
+		if r.Gap(currency) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,106 @@
+package paypal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leavengood/donation_tracker/util"
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// GetBalance
+//==============================================================================
+
+func TestGetBalance(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "GetBalance", r.Form.Get(MethodKey))
+		assert.Equal(t, "1", r.Form.Get("RETURNALLCURRENCIES"))
+		fmt.Fprint(w, "ACK=Success&TIMESTAMP=2020-03-31T12%3A00%3A00Z&L_AMT0=1234.56&L_CURRENCYCODE0=USD"+
+			"&L_AMT1=78.90&L_CURRENCYCODE1=EUR")
+	}))
+	defer ts.Close()
+
+	snapshot, err := NewClient(testConfig(ts.URL)).GetBalance(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, time.Date(2020, time.March, 31, 12, 0, 0, 0, time.UTC), snapshot.Time)
+	assert.Equal(t, util.CurrencyAmounts{"USD": 1234.56, "EUR": 78.90}, snapshot.Balances)
+}
+
+func TestRestClientGetBalance(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(restTokenPath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token": "token-123", "token_type": "Bearer", "expires_in": 32400}`)
+	})
+	mux.HandleFunc(restBalancesPath, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token-123", r.Header.Get("Authorization"))
+		assert.Equal(t, "ALL", r.URL.Query().Get("currency_code"))
+		fmt.Fprint(w, `{"balances": [
+			{"currency": "USD", "primary": true, "total_balance": {"currency_code": "USD", "value": "1234.56"}},
+			{"currency": "EUR", "total_balance": {"currency_code": "EUR", "value": "78.90"}}
+		], "as_of_time": "2020-03-31T05:00:00-0700"}`)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	snapshot, err := NewRestClient(testRestConfig(ts.URL, "secret")).GetBalance(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, time.Date(2020, time.March, 31, 12, 0, 0, 0, time.UTC), snapshot.Time)
+	assert.Equal(t, util.CurrencyAmounts{"USD": 1234.56, "EUR": 78.90}, snapshot.Balances)
+}
+
+//==============================================================================
+// BalanceHistory
+//==============================================================================
+
+func TestBalanceHistory(t *testing.T) {
+	history := &BalanceHistory{filename: filepath.Join(t.TempDir(), balanceHistoryFile)}
+	later := &BalanceSnapshot{Time: day(time.March, 2), Balances: util.CurrencyAmounts{"USD": 20}}
+	earlier := &BalanceSnapshot{Time: day(time.March, 1), Balances: util.CurrencyAmounts{"USD": 10}}
+	history.Add(later)
+	history.Add(earlier)
+
+	assert.Equal(t, []*BalanceSnapshot{earlier, later}, history.Snapshots)
+	assert.Equal(t, later, history.OnDate(day(time.March, 2)))
+	assert.Nil(t, history.OnDate(day(time.March, 3)))
+	assert.NoError(t, history.Save())
+}
+
+//==============================================================================
+// Reconcile
+//==============================================================================
+
+func TestReconcile(t *testing.T) {
+	from := &BalanceSnapshot{Time: day(time.March, 1), Balances: util.CurrencyAmounts{"USD": 100, "EUR": 10}}
+	to := &BalanceSnapshot{Time: day(time.March, 31), Balances: util.CurrencyAmounts{"USD": 140.59}}
+	txns := Transactions{
+		// Before the first snapshot
+		{Timestamp: day(time.March, 1), Type: "Donation", NetAmt: 9.41, CurrencyCode: "USD", Status: "Completed"},
+		{Timestamp: day(time.March, 2), Type: "Donation", NetAmt: 48.25, CurrencyCode: "USD", Status: "Completed"},
+		{Timestamp: day(time.March, 3), Type: "Donation", NetAmt: 9.41, CurrencyCode: "EUR", Status: "Completed"},
+		{Timestamp: day(time.March, 3), Type: "Currency Conversion", NetAmt: -9.41, CurrencyCode: "EUR", Status: "Completed"},
+		{Timestamp: day(time.March, 3), Type: "Currency Conversion", NetAmt: 10.07, CurrencyCode: "USD", Status: "Completed"},
+		{Timestamp: day(time.March, 4), Type: "Donation", NetAmt: 500, CurrencyCode: "USD", Status: "Pending"},
+		{Timestamp: day(time.March, 10), Type: "Withdrawal", NetAmt: -27.73, CurrencyCode: "USD", Status: "Completed"},
+	}
+
+	r := Reconcile(from, to, txns)
+
+	assert.Equal(t, 5, r.Count)
+	assert.Equal(t, []string{"EUR", "USD"}, r.Currencies())
+	assert.InDelta(t, 40.59, r.Change("USD"), 0.001)
+	assert.Equal(t, float32(10.00), r.Gap("USD"))
+	assert.Equal(t, float32(-10), r.Gap("EUR"))
+	assert.False(t, r.Balanced())
+
+	to.Balances = util.CurrencyAmounts{"USD": 130.59, "EUR": 10}
+	assert.True(t, Reconcile(from, to, txns).Balanced())
+}
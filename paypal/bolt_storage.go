@@ -0,0 +1,359 @@
+package paypal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const boltFile = "paypal.db"
+
+func boltFileName(dir string) string {
+	return filepath.Join(dir, boltFile)
+}
+
+// The buckets inside the bucket of each account
+var (
+	// Each saved month, even an empty one, by YYYY-MM, with its coverage
+	monthsBucket = []byte("months")
+	// Each transaction as JSON, by its month and place in the month
+	txnsBucket = []byte("transactions")
+	// The indexes, which point to the key in the transactions bucket
+	idsBucket    = []byte("ids")
+	emailsBucket = []byte("emails")
+	datesBucket  = []byte("dates")
+)
+
+// The format of the dates in the dates index, which sort in order
+const boltDateFormat = "20060102T150405.000000000"
+
+// BoltStorage saves the transactions of every account in one bbolt database
+// file, indexed by date, email and transaction ID. Only one program can have
+// the file open to write at a time.
+type BoltStorage struct {
+	db *bolt.DB
+}
+
+// OpenBoltStorage opens the database file, creating it if needed.
+func OpenBoltStorage(filename string) (*BoltStorage, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+
+	return openBolt(filename, &bolt.Options{Timeout: time.Second})
+}
+
+// OpenBoltStorageReadOnly opens the database file only to read it, which any
+// number of programs can do at once, as long as none has it open to write.
+func OpenBoltStorageReadOnly(filename string) (*BoltStorage, error) {
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s does not exist yet, no PayPal transactions have been saved in it", filename)
+	}
+
+	return openBolt(filename, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+}
+
+func openBolt(filename string, options *bolt.Options) (*BoltStorage, error) {
+	db, err := bolt.Open(filename, 0644, options)
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("could not open %s, it is being used by another command", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", filename, err)
+	}
+
+	return &BoltStorage{db: db}, nil
+}
+
+func accountBucket(account string) []byte {
+	// Bucket names cannot be empty
+	return []byte("account/" + account)
+}
+
+func monthKey(year, month int) []byte {
+	return []byte(fmt.Sprintf("%04d-%02d", year, month))
+}
+
+// txnKey is the key of the transaction with the given place in the month,
+// so the transactions of a month are together and in order.
+func txnKey(month []byte, i int) []byte {
+	return []byte(fmt.Sprintf("%s/%06d", month, i))
+}
+
+func emailKey(email string, key []byte) []byte {
+	return append([]byte(strings.ToLower(email)+"\x00"), key...)
+}
+
+func dateKey(t time.Time, key []byte) []byte {
+	return append([]byte(t.UTC().Format(boltDateFormat)+"\x00"), key...)
+}
+
+// indexKey returns the transaction key at the end of an index key
+func indexKey(k []byte) []byte {
+	return k[bytes.IndexByte(k, 0)+1:]
+}
+
+func (s *BoltStorage) LoadYear(account string, year int) (map[int]*SavedMonth, error) {
+	result := map[int]*SavedMonth{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(accountBucket(account))
+		if b == nil {
+			return nil
+		}
+
+		prefix := []byte(fmt.Sprintf("%04d-", year))
+		c := b.Bucket(monthsBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			month, err := strconv.Atoi(string(k[len(prefix):]))
+			if err != nil {
+				return fmt.Errorf("invalid month %q: %w", k, err)
+			}
+			saved := &SavedMonth{}
+			if len(v) > 0 {
+				if err := json.Unmarshal(v, &saved.Coverage); err != nil {
+					return fmt.Errorf("invalid coverage of %s: %w", k, err)
+				}
+			}
+			if saved.Transactions, err = loadBoltMonth(b, k); err != nil {
+				return err
+			}
+			result[month] = saved
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// loadBoltMonth returns the transactions of the month in the account bucket.
+func loadBoltMonth(b *bolt.Bucket, month []byte) (Transactions, error) {
+	result := Transactions{}
+
+	// The month may be in the database, which cannot be changed
+	prefix := append(append([]byte{}, month...), '/')
+	c := b.Bucket(txnsBucket).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		t := new(Transaction)
+		if err := json.Unmarshal(v, t); err != nil {
+			return nil, fmt.Errorf("invalid transaction %s: %w", k, err)
+		}
+		result = append(result, t)
+	}
+
+	return result, nil
+}
+
+func (s *BoltStorage) SaveMonth(account string, year, month int, saved *SavedMonth) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(accountBucket(account))
+		if err != nil {
+			return err
+		}
+		for _, name := range [][]byte{monthsBucket, txnsBucket, idsBucket, emailsBucket, datesBucket} {
+			if _, err := b.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		mk := monthKey(year, month)
+		if err := deleteBoltMonth(b, mk); err != nil {
+			return err
+		}
+
+		for i, t := range saved.Transactions {
+			value, err := json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			key := txnKey(mk, i)
+			if err := b.Bucket(txnsBucket).Put(key, value); err != nil {
+				return err
+			}
+			if err := putBoltIndexes(b, t, key); err != nil {
+				return err
+			}
+		}
+
+		coverage := []byte{}
+		if saved.Coverage != nil {
+			if coverage, err = json.Marshal(saved.Coverage); err != nil {
+				return err
+			}
+		}
+		return b.Bucket(monthsBucket).Put(mk, coverage)
+	})
+}
+
+func putBoltIndexes(b *bolt.Bucket, t *Transaction, key []byte) error {
+	if t.TransactionID != "" {
+		if err := b.Bucket(idsBucket).Put([]byte(t.TransactionID), key); err != nil {
+			return err
+		}
+	}
+	if t.Email != "" {
+		if err := b.Bucket(emailsBucket).Put(emailKey(t.Email, key), nil); err != nil {
+			return err
+		}
+	}
+
+	return b.Bucket(datesBucket).Put(dateKey(t.Timestamp, key), nil)
+}
+
+// deleteBoltMonth removes the transactions of the month and their index
+// entries, before it is saved again.
+func deleteBoltMonth(b *bolt.Bucket, month []byte) error {
+	old, err := loadBoltMonth(b, month)
+	if err != nil {
+		return err
+	}
+
+	for i, t := range old {
+		key := txnKey(month, i)
+		// Another month may have the same transaction since then
+		if t.TransactionID != "" && bytes.Equal(b.Bucket(idsBucket).Get([]byte(t.TransactionID)), key) {
+			if err := b.Bucket(idsBucket).Delete([]byte(t.TransactionID)); err != nil {
+				return err
+			}
+		}
+		if t.Email != "" {
+			if err := b.Bucket(emailsBucket).Delete(emailKey(t.Email, key)); err != nil {
+				return err
+			}
+		}
+		if err := b.Bucket(datesBucket).Delete(dateKey(t.Timestamp, key)); err != nil {
+			return err
+		}
+		if err := b.Bucket(txnsBucket).Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *BoltStorage) Years(account string) ([]int, error) {
+	result := []int{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(accountBucket(account))
+		if b == nil {
+			return nil
+		}
+
+		return b.Bucket(monthsBucket).ForEach(func(k, _ []byte) error {
+			year, err := strconv.Atoi(string(k[:4]))
+			if err != nil {
+				return fmt.Errorf("invalid month %q: %w", k, err)
+			}
+			// The months are in order
+			if len(result) == 0 || result[len(result)-1] != year {
+				result = append(result, year)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// getBoltTransaction returns the transaction with the key in the transactions
+// bucket.
+func getBoltTransaction(b *bolt.Bucket, key []byte) (*Transaction, error) {
+	v := b.Bucket(txnsBucket).Get(key)
+	if v == nil {
+		return nil, fmt.Errorf("the index has transaction %s, which is missing", key)
+	}
+	t := new(Transaction)
+	if err := json.Unmarshal(v, t); err != nil {
+		return nil, fmt.Errorf("invalid transaction %s: %w", key, err)
+	}
+
+	return t, nil
+}
+
+func (s *BoltStorage) FindTransaction(account, id string) (*Transaction, error) {
+	var result *Transaction
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(accountBucket(account))
+		if b == nil {
+			return nil
+		}
+		key := b.Bucket(idsBucket).Get([]byte(id))
+		if key == nil {
+			return nil
+		}
+
+		var err error
+		result, err = getBoltTransaction(b, key)
+		return err
+	})
+
+	return result, err
+}
+
+// findBolt returns the transactions in the index of the account from the
+// first key, while the keys are before the end, sorted by date.
+func (s *BoltStorage) findBolt(account string, index, first []byte, before func(k []byte) bool) (Transactions, error) {
+	result := Transactions{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(accountBucket(account))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Bucket(index).Cursor()
+		for k, _ := c.Seek(first); k != nil && before(k); k, _ = c.Next() {
+			t, err := getBoltTransaction(b, indexKey(k))
+			if err != nil {
+				return err
+			}
+			result = append(result, t)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Sort()
+
+	return result, nil
+}
+
+func (s *BoltStorage) FindByEmail(account, email string) (Transactions, error) {
+	prefix := []byte(strings.ToLower(email) + "\x00")
+
+	return s.findBolt(account, emailsBucket, prefix, func(k []byte) bool {
+		return bytes.HasPrefix(k, prefix)
+	})
+}
+
+func (s *BoltStorage) FindBetween(account string, start, end time.Time) (Transactions, error) {
+	last := []byte(end.UTC().Format(boltDateFormat))
+
+	return s.findBolt(account, datesBucket, []byte(start.UTC().Format(boltDateFormat)), func(k []byte) bool {
+		return bytes.Compare(k, last) < 0
+	})
+}
+
+func (s *BoltStorage) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,180 @@
+package paypal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Categories a transaction can be classified into
+const (
+	CategoryDonation     = "donation"
+	CategorySubscription = "subscription"
+	CategorySale         = "sale"
+	CategoryExpense      = "expense"
+	CategoryTransfer     = "transfer"
+	// What no rule matched
+	CategoryOther = "other"
+)
+
+var categories = map[string]bool{
+	CategoryDonation:     true,
+	CategorySubscription: true,
+	CategorySale:         true,
+	CategoryExpense:      true,
+	CategoryTransfer:     true,
+	CategoryOther:        true,
+}
+
+// Rule classifies the transactions which match everything it has into its
+// category. Anything left out of a rule matches every transaction.
+type Rule struct {
+	// Shown by the classify command, defaulting to the number of the rule
+	Name     string `json:"name,omitempty"`
+	Category string `json:"category"`
+
+	// Any of these, ignoring case
+	Types    []string `json:"types,omitempty"`
+	Statuses []string `json:"statuses,omitempty"`
+	// The email of the other party, or a domain like "@example.com"
+	Emails []string `json:"emails,omitempty"`
+	// The gross amount, which is negative for money sent
+	MinAmount *float32 `json:"min_amount,omitempty"`
+	MaxAmount *float32 `json:"max_amount,omitempty"`
+	// Text in the note or item name, ignoring case
+	MemoContains string `json:"memo_contains,omitempty"`
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches is true when the transaction matches everything in the rule.
+func (r *Rule) Matches(t *Transaction) bool {
+	if len(r.Types) > 0 && !containsFold(r.Types, t.Type) {
+		return false
+	}
+	if len(r.Statuses) > 0 && !containsFold(r.Statuses, t.Status) {
+		return false
+	}
+	if len(r.Emails) > 0 {
+		email := strings.ToLower(t.Email)
+		found := false
+		for _, e := range r.Emails {
+			e = strings.ToLower(e)
+			if email == e || strings.HasPrefix(e, "@") && strings.HasSuffix(email, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.MinAmount != nil && t.Amt < *r.MinAmount {
+		return false
+	}
+	if r.MaxAmount != nil && t.Amt > *r.MaxAmount {
+		return false
+	}
+	if r.MemoContains != "" {
+		memo := strings.ToLower(r.MemoContains)
+		if !strings.Contains(strings.ToLower(t.Note), memo) && !strings.Contains(strings.ToLower(t.ItemName), memo) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Rules are checked in order, and the first one a transaction matches decides
+// its category.
+type Rules []*Rule
+
+// Validate returns the problems with the rules.
+func (rs Rules) Validate() []string {
+	errorList := []string{}
+
+	for i, r := range rs {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("%d", i+1)
+		}
+		if !categories[r.Category] {
+			errorList = append(errorList, fmt.Sprintf("classification rule %s has unknown category %q", name, r.Category))
+		}
+		if r.MinAmount != nil && r.MaxAmount != nil && *r.MinAmount > *r.MaxAmount {
+			errorList = append(errorList, fmt.Sprintf("classification rule %s has a min_amount above its max_amount", name))
+		}
+		if len(r.Types) == 0 && len(r.Statuses) == 0 && len(r.Emails) == 0 && r.MinAmount == nil &&
+			r.MaxAmount == nil && r.MemoContains == "" {
+			errorList = append(errorList, fmt.Sprintf("classification rule %s matches every transaction", name))
+		}
+	}
+
+	return errorList
+}
+
+// oneCent is the smallest amount of money received
+var oneCent = float32(0.01)
+
+// DefaultRules are checked after the configured rules. They classify what
+// PayPal calls donations as donations and other payments received as
+// subscriptions.
+var DefaultRules = Rules{
+	{Name: "default donations", Category: CategoryDonation, Types: []string{"Donation"}, MinAmount: &oneCent},
+	{Name: "default payments", Category: CategorySubscription, Types: []string{"Payment", "Recurring Payment"},
+		MinAmount: &oneCent},
+}
+
+// rules are the configured classification rules
+var rules = Rules{}
+
+// SetRules sets the classification rules from the config, which are checked
+// before the default rules.
+func SetRules(r Rules) {
+	rules = r
+}
+
+// Classify returns the category of the transaction and the rule which decided
+// it, which is nil for CategoryOther.
+func Classify(t *Transaction) (string, *Rule) {
+	for _, list := range []Rules{rules, DefaultRules} {
+		for _, r := range list {
+			if r.Matches(t) {
+				return r.Category, r
+			}
+		}
+	}
+
+	return CategoryOther, nil
+}
+
+// RuleName is the name of the rule shown by the classify command.
+func RuleName(r *Rule) string {
+	if r == nil {
+		return "no rule"
+	}
+	if r.Name != "" {
+		return r.Name
+	}
+	for i, configured := range rules {
+		if configured == r {
+			return fmt.Sprintf("rule %d", i+1)
+		}
+	}
+
+	return "unnamed rule"
+}
+
+// Category returns what the classification rules make the transaction.
+func (p *Transaction) Category() string {
+	category, _ := Classify(p)
+
+	return category
+}
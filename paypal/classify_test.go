@@ -0,0 +1,94 @@
+package paypal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// Classify
+//==============================================================================
+
+const testRules = `[
+	{"name": "shirts", "category": "sale", "types": ["Payment"], "memo_contains": "t-shirt"},
+	{"name": "board", "category": "expense", "emails": ["@haiku-inc.org"], "max_amount": 0},
+	{"category": "transfer", "types": ["General Withdrawal", "Withdrawal"]},
+	{"name": "small", "category": "other", "types": ["donation"], "max_amount": 1}
+]`
+
+func TestClassify(t *testing.T) {
+	rules := Rules{}
+	assert.Nil(t, json.Unmarshal([]byte(testRules), &rules))
+	assert.Empty(t, rules.Validate())
+	SetRules(rules)
+	defer SetRules(Rules{})
+
+	sale := &Transaction{Type: "Payment", Amt: 20, ItemName: "Haiku T-Shirt (L)"}
+	category, rule := Classify(sale)
+	assert.Equal(t, CategorySale, category)
+	assert.Equal(t, "shirts", RuleName(rule))
+	assert.False(t, sale.IsSubscription())
+
+	expense := &Transaction{Type: "Payment", Amt: -150, Email: "treasurer@haiku-inc.org"}
+	assert.Equal(t, CategoryExpense, expense.Category())
+
+	withdrawal := &Transaction{Type: "Withdrawal", Amt: -1000}
+	category, rule = Classify(withdrawal)
+	assert.Equal(t, CategoryTransfer, category)
+	assert.Equal(t, "rule 3", RuleName(rule))
+
+	// Case does not matter for types
+	tiny := &Transaction{Type: "Donation", Amt: 0.5}
+	assert.Equal(t, CategoryOther, tiny.Category())
+	assert.False(t, tiny.IsDonation())
+
+	// The default rules still apply
+	donation := &Transaction{Type: "Donation", Amt: 10}
+	category, rule = Classify(donation)
+	assert.Equal(t, CategoryDonation, category)
+	assert.Equal(t, "default donations", RuleName(rule))
+	assert.True(t, donation.IsDonation())
+
+	subscription := &Transaction{Type: "Recurring Payment", Amt: 5}
+	assert.True(t, subscription.IsSubscription())
+
+	category, rule = Classify(&Transaction{Type: "Fee Reversal", Amt: 1})
+	assert.Equal(t, CategoryOther, category)
+	assert.Equal(t, "no rule", RuleName(rule))
+}
+
+func TestClassifyLeavesOutRefundsOfSales(t *testing.T) {
+	SetRules(Rules{{Category: CategorySale, MemoContains: "sticker"}})
+	defer SetRules(Rules{})
+
+	txns := Transactions{
+		{Timestamp: day(time.January, 2), Type: "Payment", TransactionID: "1A", Email: "bruce@wayneenterprises.com",
+			Amt: 5, CurrencyCode: "USD", Note: "Sticker pack"},
+		{Timestamp: day(time.January, 3), Type: "Refund", TransactionID: "R1", Email: "bruce@wayneenterprises.com",
+			Amt: -5, CurrencyCode: "USD"},
+	}
+	txns.LinkReturns()
+	assert.Equal(t, "1A", txns[1].ParentTransactionID)
+
+	kept, voided := txns.WithoutVoided(txns)
+	assert.Equal(t, Transactions{txns[1]}, voided)
+	donations, _ := kept.FilterDonations()
+	assert.Empty(t, donations)
+}
+
+func TestValidateRules(t *testing.T) {
+	min, max := float32(10), float32(5)
+	errs := Rules{
+		{Name: "bad", Category: "gift", Types: []string{"Donation"}},
+		{Category: CategorySale, MinAmount: &min, MaxAmount: &max},
+		{Category: CategorySale},
+	}.Validate()
+	assert.Equal(t, []string{
+		`classification rule bad has unknown category "gift"`,
+		"classification rule 2 has a min_amount above its max_amount",
+		"classification rule 3 matches every transaction",
+	}, errs)
+}
@@ -1,14 +1,11 @@
 package paypal
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
-	"net/url"
+	"strings"
 	"time"
-
-	"github.com/leavengood/donation_tracker/util"
 )
 
 const (
@@ -27,94 +24,130 @@ const (
 
 type Client struct {
 	config *Config
+	http   *httpCaller
 	Debug  bool
 }
 
 func NewClient(config *Config) *Client {
 	return &Client{
 		config: config,
+		http:   newHTTPCaller(config),
 	}
 }
 
-func (c *Client) GetTransactions(startDate, endDate string) Transactions {
-	fmt.Printf("Getting PayPal data from %s to %s\n", startDate, endDate)
+// API returns which PayPal API the client uses.
+func (c *Client) API() string {
+	return APINvp
+}
 
-	nv := NameValues{"STARTDATE": startDate}
-	if endDate != "" {
-		nv["ENDDATE"] = endDate
-	}
-	data, err := callPayPalNvpApi(c.config, "TransactionSearch", "117.0", nv)
-	if err != nil {
-		log.Fatal(err)
-	}
-	// TODO: Make this easier to use for debugging
-	// ioutil.WriteFile("paypal.nvp", []byte(data), 0700)
+// MaxSearchResults is the most transactions PayPal will return from a single
+// TransactionSearch call. When a date range has more than this the results are
+// truncated and the ACK is SuccessWithWarning.
+const MaxSearchResults = 100
 
-	// fileData, _ := ioutil.ReadFile("paypal.nvp")
-	// data := string(fileData)
+// minSearchWindow is the smallest date range which will be split further when
+// a search is truncated.
+const minSearchWindow = time.Minute
 
-	nvp := ParseNvpData(data)
+// GetTransactions gets all transactions between the two dates, which should be
+// in PayPalDateFormat. An empty end date means up to now. If PayPal truncates
+// the results the date range is split in half and each half is fetched,
+// recursively, until every part comes back complete.
+func (c *Client) GetTransactions(ctx context.Context, startDate, endDate string) (Transactions, error) {
+	fmt.Fprintf(output(ctx), "Getting PayPal data from %s to %s\n", startDate, endDate)
 
-	if !nvp.Successful() {
-		// TODO: Return error
-		log.Fatalf("API call was not successful: %v\n", data)
+	start, end, err := parseDateRange(startDate, endDate)
+	if err != nil {
+		return nil, err
 	}
 
-	ppts := TransactionsFromNvp(nvp)
+	ppts, err := c.searchWindow(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
 	ppts.Sort()
 
-	return ppts
-}
-
-// TODO: Maybe move...
-func GetEndDate(year, month int) string {
-	// if month == 12 {
-	// 	year++
-	// 	month = 0
-	// }
-	firstOfMonth := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-	lastOfMonth := firstOfMonth.AddDate(0, 1, -1)
-	_, _, day := lastOfMonth.Date()
-	return fmt.Sprintf("%d-%02d-%02dT23:59:59Z", year, month, day)
+	return ppts, nil
 }
 
-func (c *Client) GetTransactionsForMonth(year, month int) Transactions {
-	startDate := fmt.Sprintf("%d-%02d-01T00:00:00Z", year, month)
+func (c *Client) searchWindow(ctx context.Context, start, end time.Time) (Transactions, error) {
+	nv := NameValues{
+		"STARTDATE": start.Format(PayPalDateFormat),
+		"ENDDATE":   end.Format(PayPalDateFormat),
+	}
+	nvp, err := c.callNvpApi(ctx, "TransactionSearch", "117.0", nv)
+	if err != nil {
+		return nil, err
+	}
 
-	// TODO: better error handling
-	return c.GetTransactions(startDate, GetEndDate(year, month))
-}
+	txns := TransactionsFromNvp(nvp)
+	if !nvp.Truncated() {
+		return txns, nil
+	}
 
-func (c *Client) GetAndSaveMonth(year, month int, fm *FileManager) error {
-	monthStr := util.Colorize(util.Green, fmt.Sprintf("%s %d", time.Month(month), year))
-	fmt.Printf("Fetching PayPal transactions for %s...", monthStr)
-	txns := c.GetTransactionsForMonth(year, month)
-	fmt.Printf("there are %d transactions, saving to JSON.\n", len(txns))
-	return fm.SaveMonth(month, txns)
-}
+	if end.Sub(start) < minSearchWindow {
+		fmt.Fprintf(output(ctx), "WARNING: results from %s to %s were truncated and cannot be split further\n",
+			start.Format(PayPalDateFormat), end.Format(PayPalDateFormat))
+		return txns, nil
+	}
 
-func callPayPalNvpApi(config *Config, method string, version string, params NameValues) (string, error) {
-	v := url.Values{}
-	v.Set(MethodKey, method)
-	v.Set(VersionKey, version)
-	v.Set(UserKey, config.User)
-	v.Set(PasswordKey, config.Password)
-	v.Set(SignatureKey, config.Signature)
+	// PayPal dates only have a precision of seconds
+	mid := start.Add(end.Sub(start) / 2).Truncate(time.Second)
+	fmt.Fprintf(output(ctx), "    More than %d transactions from %s to %s, splitting the search at %s\n",
+		MaxSearchResults, start.Format(PayPalDateFormat), end.Format(PayPalDateFormat),
+		mid.Format(PayPalDateFormat))
 
-	for name, value := range params {
-		v.Set(name, value)
+	before, err := c.searchWindow(ctx, start, mid)
+	if err != nil {
+		return nil, err
 	}
-
-	resp, err := http.PostForm(config.Endpoint, v)
+	after, err := c.searchWindow(ctx, mid.Add(time.Second), end)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	// Merge will remove any duplicates
+	return txns.Merge(before).Merge(after), nil
+}
+
+// callNvpApi calls the given NVP API method, retrying transient failures.
+func (c *Client) callNvpApi(ctx context.Context, method string, version string, params NameValues) (*NvpResult, error) {
+	nv := NameValues{
+		MethodKey:    method,
+		VersionKey:   version,
+		UserKey:      c.config.User,
+		PasswordKey:  c.config.Password,
+		SignatureKey: c.config.Signature,
+	}
+	for name, value := range params {
+		nv[name] = value
+	}
+	requestBody := EncodeNvp(nv)
+
+	var nvp *NvpResult
+	err := c.http.retry(ctx, func() error {
+		body, err := c.http.do(ctx, func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.Endpoint,
+				strings.NewReader(requestBody))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return req, nil
+		})
+		if err != nil {
+			return fmt.Errorf("could not call PayPal %s: %w", method, err)
+		}
+
+		nvp, err = DecodeNvp(string(body))
+		if err != nil {
+			return fmt.Errorf("could not decode the PayPal %s response: %w", method, err)
+		}
+		return nvp.Error(method)
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return string(body), nil
+	return nvp, nil
 }
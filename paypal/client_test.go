@@ -0,0 +1,267 @@
+package paypal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// GetTransactions
+//==============================================================================
+
+// newSearchServer returns a server which answers TransactionSearch calls from
+// the given transactions the way PayPal does, truncating to the most recent
+// max when there are too many. The number of calls is counted.
+func newSearchServer(t *testing.T, txns Transactions, max int, calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		assert.NoError(t, r.ParseForm())
+		start, err := time.Parse(PayPalDateFormat, r.Form.Get("STARTDATE"))
+		assert.NoError(t, err)
+		end, err := time.Parse(PayPalDateFormat, r.Form.Get("ENDDATE"))
+		assert.NoError(t, err)
+
+		found := Transactions{}
+		// PayPal returns the newest transactions first
+		for i := len(txns) - 1; i >= 0; i-- {
+			ts := txns[i].Timestamp
+			if !ts.Before(start) && !ts.After(end) {
+				found = append(found, txns[i])
+			}
+		}
+
+		ack := "Success"
+		if len(found) > max {
+			ack = "SuccessWithWarning"
+			found = found[:max]
+		}
+
+		fields := []string{"ACK=" + ack}
+		for i, txn := range found {
+			fields = append(fields,
+				fmt.Sprintf("L_TIMESTAMP%d=%s", i, url.QueryEscape(txn.Timestamp.Format(PayPalDateFormat))),
+				fmt.Sprintf("L_TRANSACTIONID%d=%s", i, txn.TransactionID),
+				fmt.Sprintf("L_TYPE%d=Donation", i),
+				fmt.Sprintf("L_AMT%d=5.00", i),
+			)
+		}
+		if ack == "SuccessWithWarning" {
+			fields = append(fields, "L_ERRORCODE0=11002")
+		}
+		fmt.Fprint(w, strings.Join(fields, "&"))
+	}))
+}
+
+// testConfig returns a config which does not slow down the tests
+func testConfig(endpoint string) *Config {
+	return &Config{Endpoint: endpoint, RequestsPerSecond: 1000}
+}
+
+func makeSearchTransactions(count int, start time.Time, step time.Duration) Transactions {
+	txns := make(Transactions, count)
+	for i := range txns {
+		txns[i] = &Transaction{
+			Timestamp:     start.Add(time.Duration(i) * step),
+			TransactionID: fmt.Sprintf("TXN%04d", i),
+		}
+	}
+	return txns
+}
+
+func TestGetTransactionsWithoutTruncation(t *testing.T) {
+	start := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	txns := makeSearchTransactions(20, start, time.Hour)
+	calls := 0
+	ts := newSearchServer(t, txns, MaxSearchResults, &calls)
+	defer ts.Close()
+
+	client := NewClient(testConfig(ts.URL))
+	result, err := client.GetTransactions(context.Background(), "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 20, len(result))
+}
+
+func TestGetTransactionsSplitsTruncatedWindows(t *testing.T) {
+	start := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	// Bunch most of them up at the start of the month so some windows need
+	// to be split more than once.
+	txns := append(
+		makeSearchTransactions(300, start, time.Minute),
+		makeSearchTransactions(50, start.AddDate(0, 0, 10), time.Hour)...,
+	)
+	for i, txn := range txns {
+		txn.TransactionID = fmt.Sprintf("TXN%04d", i)
+	}
+	calls := 0
+	ts := newSearchServer(t, txns, MaxSearchResults, &calls)
+	defer ts.Close()
+
+	client := NewClient(testConfig(ts.URL))
+	result, err := client.GetTransactions(context.Background(), "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+	assert.NoError(t, err)
+
+	assert.True(t, calls > 3)
+	assert.Equal(t, 350, len(result))
+
+	ids := map[string]bool{}
+	for i, txn := range result {
+		ids[txn.TransactionID] = true
+		if i > 0 {
+			assert.False(t, txn.Timestamp.Before(result[i-1].Timestamp))
+		}
+	}
+	assert.Equal(t, 350, len(ids))
+}
+
+func TestGetTransactionsSplitsWindowsTruncatedEarly(t *testing.T) {
+	start := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	txns := makeSearchTransactions(138, start, time.Hour)
+	calls := 0
+	// PayPal can give the search limit warning with fewer results
+	ts := newSearchServer(t, txns, 10, &calls)
+	defer ts.Close()
+
+	client := NewClient(testConfig(ts.URL))
+	result, err := client.GetTransactions(context.Background(), "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+	assert.NoError(t, err)
+
+	assert.True(t, calls > 1)
+	assert.Equal(t, 138, len(result))
+}
+
+func TestGetTransactionsReturnsAPIErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ACK=Failure&L_ERRORCODE0=10002&L_SHORTMESSAGE0=Security%20error"+
+			"&L_LONGMESSAGE0=Security%20header%20is%20not%20valid&L_SEVERITYCODE0=Error")
+	}))
+	defer ts.Close()
+
+	client := NewClient(testConfig(ts.URL))
+	result, err := client.GetTransactions(context.Background(), "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+
+	assert.Nil(t, result)
+	assert.True(t, IsAuthError(err))
+	assert.False(t, IsRateLimited(err))
+
+	var apiErr *APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, "Failure", apiErr.Ack)
+		assert.Equal(t, []ErrorDetail{{
+			Code:         "10002",
+			ShortMessage: "Security error",
+			LongMessage:  "Security header is not valid",
+			SeverityCode: "Error",
+		}}, apiErr.Errors)
+	}
+}
+
+func TestGetTransactionsReturnsTransportErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+
+	config := testConfig(ts.URL)
+	config.MaxRetries = -1
+	client := NewClient(config)
+	_, err := client.GetTransactions(context.Background(), "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+
+	assert.Error(t, err)
+	assert.False(t, IsAuthError(err))
+}
+
+func TestGetTransactionsRetriesTransientFailures(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			fmt.Fprint(w, "ACK=Failure&L_ERRORCODE0=10101&L_SHORTMESSAGE0=Temporarily%20unavailable")
+		default:
+			fmt.Fprint(w, "ACK=Success&L_TIMESTAMP0=2020-03-02T10%3A15%3A00Z&L_TRANSACTIONID0=TXN1")
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(testConfig(ts.URL))
+	client.http.baseDelay = time.Millisecond
+	result, err := client.GetTransactions(context.Background(), "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 1, len(result))
+}
+
+func TestGetTransactionsGivesUpAfterMaxRetries(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	config := testConfig(ts.URL)
+	config.MaxRetries = 2
+	client := NewClient(config)
+	client.http.baseDelay = time.Millisecond
+	_, err := client.GetTransactions(context.Background(), "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, 3, calls)
+}
+
+func TestGetTransactionsDoesNotRetryAuthErrors(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, "ACK=Failure&L_ERRORCODE0=10002")
+	}))
+	defer ts.Close()
+
+	client := NewClient(testConfig(ts.URL))
+	client.http.baseDelay = time.Millisecond
+	_, err := client.GetTransactions(context.Background(), "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+
+	assert.True(t, IsAuthError(err))
+	assert.Equal(t, 1, calls)
+}
+
+func TestGetTransactionsStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client := NewClient(testConfig(ts.URL))
+	_, err := client.GetTransactions(ctx, "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+//==============================================================================
+// rateLimiter
+//==============================================================================
+
+func TestRateLimiterSpacesOutCalls(t *testing.T) {
+	limiter := newRateLimiter(50)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, limiter.Wait(context.Background()))
+	}
+
+	assert.True(t, time.Since(start) >= 80*time.Millisecond)
+}
@@ -1,9 +1,36 @@
 package paypal
 
-// Config has various config values for getting PayPal transations with their NVP API
+// Config has various config values for getting PayPal transations with either
+// their NVP API or their REST API
 type Config struct {
+	// The name of the account, when there are several. Each named account
+	// has its own subdirectory of the data directory.
+	Name string `json:"name,omitempty"`
+
+	// Which API to use, either "nvp" or "rest". The default is "nvp".
+	API string `json:"api,omitempty"`
+
+	// For the NVP API
 	Endpoint  string `json:"endpoint"`
 	User      string `json:"user"`
 	Password  string `json:"password"`
 	Signature string `json:"signature"`
+
+	// For the REST API
+	RestEndpoint string `json:"rest_endpoint,omitempty"`
+	ClientID     string `json:"client_id,omitempty"`
+	Secret       string `json:"secret,omitempty"`
+
+	// Whether to call GetTransactionDetails for each new donation with the
+	// NVP API, to get things like the donor's note and country. The REST API
+	// always includes these.
+	FetchDetails bool `json:"fetch_details,omitempty"`
+
+	// Settings for calling either API, which all have defaults. A negative
+	// max_retries disables retries.
+	TimeoutSeconds    int     `json:"timeout_seconds,omitempty"`
+	MaxRetries        int     `json:"max_retries,omitempty"`
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+	// How many missing months are fetched at once when backfilling a year
+	BackfillWorkers int `json:"backfill_workers,omitempty"`
 }
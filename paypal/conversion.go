@@ -0,0 +1,129 @@
+package paypal
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// PayPal lists the two sides of a conversion within this time of each other
+const conversionPairWindow = time.Minute
+
+// An automatic conversion happens soon after the payment being converted
+const conversionWindow = 24 * time.Hour
+
+// Conversion is a donation which PayPal converted into another currency.
+type Conversion struct {
+	Source *Transaction
+	// The debit in the currency of the donation and the credit in the
+	// currency it was converted to
+	From *Transaction
+	To   *Transaction
+}
+
+// Rate is the exchange rate PayPal used.
+func (c *Conversion) Rate() float32 {
+	if c.From.Amt == 0 {
+		return 0
+	}
+
+	return c.To.Amt / -c.From.Amt
+}
+
+// IsConversion is true for either side of a currency conversion.
+func (p *Transaction) IsConversion() bool {
+	return strings.HasPrefix(p.Type, "Currency Conversion")
+}
+
+func sameAmount(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 0.005
+}
+
+// LinkConversions finds the currency conversions of donations and sets the
+// ParentTransactionID of both sides of each conversion to the donation. If
+// the parent is already known, as it is with the REST API, it is used,
+// otherwise the source is the latest donation to the same account in the same
+// currency before the conversion with the net amount converted. Conversions
+// which are not of a single donation, such as of a whole balance, are not
+// linked, nor are conversions of anything else, like a sale or withdrawal,
+// even when the REST API gives their parent.
+func (p Transactions) LinkConversions() []*Conversion {
+	result := []*Conversion{}
+
+	// Pair up the two sides of each conversion
+	debits := Transactions{}
+	credits := Transactions{}
+	for _, t := range p {
+		if t.IsConversion() {
+			if t.Amt < 0 {
+				debits = append(debits, t)
+			} else {
+				credits = append(credits, t)
+			}
+		}
+	}
+
+	paired := map[*Transaction]bool{}
+	converted := map[string]bool{}
+	for _, debit := range debits {
+		var credit *Transaction
+		for _, c := range credits {
+			if paired[c] || c.CurrencyCode == debit.CurrencyCode || c.Account != debit.Account {
+				continue
+			}
+			if debit.ParentTransactionID != "" && c.ParentTransactionID != "" &&
+				debit.ParentTransactionID != c.ParentTransactionID {
+				continue
+			}
+			diff := c.Timestamp.Sub(debit.Timestamp)
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff <= conversionPairWindow {
+				credit = c
+				break
+			}
+		}
+		if credit == nil {
+			continue
+		}
+
+		var source *Transaction
+		parentID := debit.ParentTransactionID
+		if parentID == "" {
+			parentID = credit.ParentTransactionID
+		}
+		for _, t := range p {
+			if !(t.IsDonation() || t.IsSubscription()) || converted[t.TransactionID] || t.Account != debit.Account {
+				continue
+			}
+			if parentID != "" {
+				if t.TransactionID == parentID {
+					source = t
+					break
+				}
+				continue
+			}
+			if t.CurrencyCode != debit.CurrencyCode || !sameAmount(t.NetAmt, -debit.Amt) ||
+				t.Timestamp.After(debit.Timestamp) || debit.Timestamp.Sub(t.Timestamp) > conversionWindow {
+				continue
+			}
+			if source == nil || t.Timestamp.After(source.Timestamp) {
+				source = t
+			}
+		}
+		if source == nil {
+			continue
+		}
+
+		paired[credit] = true
+		converted[source.TransactionID] = true
+		debit.ParentTransactionID = source.TransactionID
+		credit.ParentTransactionID = source.TransactionID
+		debit.convertsDonation = true
+		credit.convertsDonation = true
+		result = append(result, &Conversion{Source: source, From: debit, To: credit})
+	}
+
+	return result
+}
@@ -0,0 +1,67 @@
+package paypal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// LinkConversions
+//==============================================================================
+
+func TestLinkConversions(t *testing.T) {
+	txns := Transactions{
+		{Timestamp: day(time.January, 2), Type: "Donation", TransactionID: "1A", Amt: 50, FeeAmt: -1.75,
+			NetAmt: 48.25, CurrencyCode: "EUR"},
+		{Timestamp: day(time.January, 2), Type: "Currency Conversion (debit)", TransactionID: "C1",
+			Amt: -48.25, CurrencyCode: "EUR"},
+		{Timestamp: day(time.January, 2), Type: "Currency Conversion (credit)", TransactionID: "C2",
+			Amt: 51.63, CurrencyCode: "USD"},
+		// Not converted
+		{Timestamp: day(time.January, 3), Type: "Donation", TransactionID: "1B", Amt: 10, FeeAmt: -0.59,
+			NetAmt: 9.41, CurrencyCode: "EUR"},
+		// A conversion of the balance, which is not for any donation
+		{Timestamp: day(time.January, 10), Type: "Currency Conversion (debit)", TransactionID: "C3",
+			Amt: -100, CurrencyCode: "EUR"},
+		{Timestamp: day(time.January, 10), Type: "Currency Conversion (credit)", TransactionID: "C4",
+			Amt: 107, CurrencyCode: "USD"},
+		// The REST API gives the parent
+		{Timestamp: day(time.January, 12), Type: "Donation", TransactionID: "1C", Amt: 20, FeeAmt: -0.88,
+			NetAmt: 19.12, CurrencyCode: "EUR"},
+		{Timestamp: day(time.January, 12), Type: "Currency Conversion", TransactionID: "C5",
+			Amt: -19.12, CurrencyCode: "EUR", ParentTransactionID: "1C"},
+		{Timestamp: day(time.January, 12), Type: "Currency Conversion", TransactionID: "C6",
+			Amt: 20.46, CurrencyCode: "USD", ParentTransactionID: "1C"},
+		// Or the withdrawal it was converted for, which is not a donation
+		{Timestamp: day(time.January, 20), Type: "General Withdrawal", TransactionID: "W1",
+			Amt: -30, CurrencyCode: "USD"},
+		{Timestamp: day(time.January, 20), Type: "Currency Conversion", TransactionID: "C7",
+			Amt: -28, CurrencyCode: "EUR", ParentTransactionID: "W1"},
+		{Timestamp: day(time.January, 20), Type: "Currency Conversion", TransactionID: "C8",
+			Amt: 30, CurrencyCode: "USD", ParentTransactionID: "W1"},
+	}
+
+	conversions := txns.LinkConversions()
+
+	assert.Equal(t, 2, len(conversions))
+	assert.Equal(t, "1A", conversions[0].Source.TransactionID)
+	assert.Equal(t, "C1", conversions[0].From.TransactionID)
+	assert.Equal(t, "C2", conversions[0].To.TransactionID)
+	assert.InDelta(t, 1.07, conversions[0].Rate(), 0.001)
+	assert.Equal(t, "1C", conversions[1].Source.TransactionID)
+	assert.Equal(t, "1A", txns[2].ParentTransactionID)
+	assert.Equal(t, "", txns[4].ParentTransactionID)
+	assert.Equal(t, "W1", txns[10].ParentTransactionID)
+
+	donations, other := txns.FilterDonations()
+	assert.Equal(t, 7, len(donations))
+	assert.Equal(t, 5, len(other))
+
+	total := donations.Summarize().Total()
+	assert.Equal(t, float32(-67.37), total.ConvertedFrom["EUR"])
+	assert.Equal(t, float32(72.09), total.ConvertedTo["USD"])
+	assert.InDelta(t, 9.41, total.Unconverted()["EUR"], 0.001)
+	assert.Equal(t, float32(80), total.GrossTotal()["EUR"])
+}
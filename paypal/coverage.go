@@ -0,0 +1,119 @@
+package paypal
+
+import (
+	"time"
+
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// SourceCSV is the source of transactions imported from the Activity download
+const SourceCSV = "csv"
+
+// Coverage is the window of time which has been fetched for a saved month,
+// when it was fetched and from where.
+type Coverage struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// When the latest part of the window was fetched
+	FetchedAt time.Time `json:"fetched_at"`
+	// The API, or csv for the Activity download
+	Source string `json:"source,omitempty"`
+	// Whether the window is the whole month
+	Complete bool `json:"complete"`
+}
+
+// now is the time, which is not the real time when replaying
+var now = time.Now
+
+// SetNow sets how the current time is found.
+func SetNow(f func() time.Time) {
+	now = f
+}
+
+// NewCoverage returns the coverage of the month after fetching from start
+// until end from the source. PayPal only takes whole seconds, so the times are
+// cut to those.
+func NewCoverage(year, month int, start, end time.Time, source string) *Coverage {
+	return &Coverage{
+		Start:     start.UTC().Truncate(time.Second),
+		End:       end.UTC().Truncate(time.Second),
+		FetchedAt: now().UTC().Truncate(time.Second),
+		Source:    source,
+		Complete: !start.After(util.MonthStart(year, time.Month(month))) &&
+			!end.Before(util.MonthEnd(year, time.Month(month))),
+	}
+}
+
+// Extend returns the coverage of the month after also fetching from start
+// until end. When that does not touch what was covered before, the one which
+// starts first is kept, since the rest of the month is fetched from its end.
+func (c *Coverage) Extend(year, month int, start, end time.Time, source string) *Coverage {
+	if c != nil {
+		if end.Before(c.Start) || start.After(c.End) {
+			if c.Start.Before(start) {
+				return c
+			}
+			return NewCoverage(year, month, start, end, source)
+		}
+		if c.Start.Before(start) {
+			start = c.Start
+		}
+		if c.End.After(end) {
+			end = c.End
+		}
+	}
+
+	return NewCoverage(year, month, start, end, source)
+}
+
+// monthEndOrNow is the end of the month, or now if the month has not ended
+func monthEndOrNow(year, month int) time.Time {
+	end := util.MonthEnd(year, time.Month(month))
+	if n := now(); n.Before(end) {
+		return n
+	}
+
+	return end
+}
+
+// StopsEarly returns whether the recorded coverage of the latest saved month
+// stops before the end of the year. It is false when that was not recorded.
+func (p *FileManager) StopsEarly() bool {
+	latest := p.GetLatestMonth()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	c := p.Coverage[latest]
+	return c != nil && (latest < 12 || !c.Complete)
+}
+
+// CoverageOf returns what has been fetched of the saved month, or nil if it
+// is not saved. Months saved before this was recorded are taken to be
+// complete, except for the latest one, which is taken to be covered up to the
+// day of its latest transaction.
+func (p *FileManager) CoverageOf(month int) *Coverage {
+	latest := p.GetLatestMonth()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	txns, found := p.Months[month]
+	if !found {
+		return nil
+	}
+	if c := p.Coverage[month]; c != nil {
+		return c
+	}
+
+	start := util.MonthStart(p.Year, time.Month(month))
+	if month != latest {
+		return &Coverage{Start: start.UTC(), End: util.MonthEnd(p.Year, time.Month(month)).UTC(), Complete: true}
+	}
+	end := start
+	if len(txns) > 0 {
+		end = util.DayStart(txns[len(txns)-1].Timestamp)
+	}
+
+	return &Coverage{Start: start.UTC(), End: end.UTC()}
+}
@@ -0,0 +1,209 @@
+package paypal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/leavengood/donation_tracker/util"
+)
+
+const detailsCacheFile = "paypal-details.json"
+
+// detailFields are the fields of a GetTransactionDetails response which
+// ApplyDetails uses. Nothing else is kept, since the rest, like the payer's
+// address, is not needed.
+var detailFields = []string{
+	"NOTE", "COUNTRYCODE", "CUSTOM", "INVNUM", "L_NAME0", "RECEIVEREMAIL", "SUBSCRIPTIONID", "PARENTTRANSACTIONID",
+}
+
+// usedDetails returns only the details in detailFields which have a value.
+func usedDetails(details NameValues) NameValues {
+	result := NameValues{}
+	for _, field := range detailFields {
+		if value := details[field]; value != "" {
+			result[field] = value
+		}
+	}
+
+	return result
+}
+
+// GetTransactionDetails calls the NVP GetTransactionDetails method for one
+// transaction and returns the fields from the response which are used. The
+// name of the first item is included as L_NAME0.
+func (c *Client) GetTransactionDetails(ctx context.Context, transactionID string) (NameValues, error) {
+	nvp, err := c.callNvpApi(ctx, "GetTransactionDetails", "117.0",
+		NameValues{"TRANSACTIONID": transactionID})
+	if err != nil {
+		return nil, err
+	}
+
+	details := usedDetails(nvp.Fields)
+	if item, found := nvp.List[0]; found && item["NAME"] != "" {
+		details["L_NAME0"] = item["NAME"]
+	}
+
+	return details, nil
+}
+
+// DetailsSource can get the details of a single transaction.
+type DetailsSource interface {
+	GetTransactionDetails(ctx context.Context, transactionID string) (NameValues, error)
+}
+
+// DetailsCache stores the used fields of the GetTransactionDetails responses
+// for transactions in the data directory so each one only needs to be fetched
+// once. It can be used while several months are fetched at once.
+type DetailsCache struct {
+	filename string
+	Details  map[string]NameValues
+	// Whether details which are not used were dropped when it was loaded
+	trimmed bool
+
+	mu sync.Mutex
+}
+
+func (d *DetailsCache) get(transactionID string) (NameValues, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	details, found := d.Details[transactionID]
+	return details, found
+}
+
+func (d *DetailsCache) set(transactionID string, details NameValues) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Details[transactionID] = usedDetails(details)
+}
+
+// LoadDetailsCache loads the details cache from the data directory of the
+// account. It is empty if it has not been saved yet. Anything cached before
+// only the used fields were kept is dropped.
+func LoadDetailsCache(account string) (*DetailsCache, error) {
+	cache := &DetailsCache{
+		filename: filepath.Join(AccountDir(account), detailsCacheFile),
+		Details:  map[string]NameValues{},
+	}
+
+	f, err := os.Open(cache.filename)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&cache.Details); err != nil {
+		return nil, fmt.Errorf("could not load %s: %w", cache.filename, err)
+	}
+	for id, details := range cache.Details {
+		if used := usedDetails(details); len(used) != len(details) {
+			cache.Details[id] = used
+			cache.trimmed = true
+		}
+	}
+
+	return cache, nil
+}
+
+// Save writes the cache back to the data directory.
+func (d *DetailsCache) Save() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(d.filename), 0755); err != nil {
+		return err
+	}
+	if err := util.WriteJSONFile(d.filename, d.Details); err != nil {
+		return err
+	}
+	d.trimmed = false
+
+	return nil
+}
+
+// EnrichTransactions sets the details on all donations, subscriptions and
+// returns in the transactions, using the cache when possible and fetching the
+// rest. The cache is saved if anything new was fetched, or it was trimmed when
+// it was loaded, even if there is an error.
+func EnrichTransactions(ctx context.Context, src DetailsSource, cache *DetailsCache, txns Transactions) (err error) {
+	fetched := 0
+	defer func() {
+		if fetched > 0 || cache.trimmed {
+			if saveErr := cache.Save(); saveErr != nil && err == nil {
+				err = saveErr
+			}
+		}
+	}()
+
+	for _, t := range txns {
+		if !t.IsDonation() && !t.IsSubscription() && !t.IsReturn() {
+			continue
+		}
+
+		details, found := cache.get(t.TransactionID)
+		if !found {
+			details, err = src.GetTransactionDetails(ctx, t.TransactionID)
+			if err != nil {
+				return fmt.Errorf("could not get details for transaction %s: %w", t.TransactionID, err)
+			}
+			cache.set(t.TransactionID, details)
+			fetched++
+		}
+		t.ApplyDetails(details)
+	}
+
+	if fetched > 0 {
+		fmt.Fprintf(output(ctx), "    Fetched details for %d transactions\n", fetched)
+	}
+
+	return nil
+}
+
+// detailsSource is a TransactionSource which enriches the transactions from
+// another source with their details.
+type detailsSource struct {
+	TransactionSource
+	details DetailsSource
+	cache   *DetailsCache
+}
+
+// WithDetails returns a TransactionSource which enriches all the donations,
+// subscriptions and returns it gets with their details, if the source is able
+// to get them. Otherwise the source is returned as is.
+func WithDetails(src TransactionSource, cache *DetailsCache) TransactionSource {
+	details, ok := src.(DetailsSource)
+	if !ok {
+		return src
+	}
+
+	return &detailsSource{
+		TransactionSource: src,
+		details:           details,
+		cache:             cache,
+	}
+}
+
+func (d *detailsSource) API() string {
+	return SourceAPI(d.TransactionSource)
+}
+
+func (d *detailsSource) GetTransactions(ctx context.Context, startDate, endDate string) (Transactions, error) {
+	txns, err := d.TransactionSource.GetTransactions(ctx, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := EnrichTransactions(ctx, d.details, d.cache, txns); err != nil {
+		return nil, err
+	}
+
+	return txns, nil
+}
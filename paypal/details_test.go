@@ -0,0 +1,107 @@
+package paypal
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// GetTransactionDetails
+//==============================================================================
+
+func TestGetTransactionDetails(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "GetTransactionDetails", r.Form.Get(MethodKey))
+		assert.Equal(t, "TXN1", r.Form.Get("TRANSACTIONID"))
+		fmt.Fprint(w, "ACK=Success&TRANSACTIONID=TXN1&NOTE=Keep%20up%20the%20good%20work%21"+
+			"&COUNTRYCODE=DE&CUSTOM=haiku-website&INVNUM=INV-42&RECEIVEREMAIL=donations%40haiku-inc.org"+
+			"&L_NAME0=Donation%20to%20Haiku%2C%20Inc.&L_QTY0=1&SHIPTOSTREET=1007%20Mountain%20Drive&EMAIL=bruce%40wayne.com")
+	}))
+	defer ts.Close()
+
+	client := NewClient(testConfig(ts.URL))
+	details, err := client.GetTransactionDetails(context.Background(), "TXN1")
+	assert.NoError(t, err)
+
+	txn := &Transaction{TransactionID: "TXN1"}
+	txn.ApplyDetails(details)
+
+	assert.Equal(t, "Keep up the good work!", txn.Note)
+	assert.Equal(t, "DE", txn.CountryCode)
+	assert.Equal(t, "haiku-website", txn.Custom)
+	assert.Equal(t, "INV-42", txn.InvoiceID)
+	assert.Equal(t, "Donation to Haiku, Inc.", txn.ItemName)
+	assert.Equal(t, "donations@haiku-inc.org", txn.Receiver)
+
+	// Only what is used is kept
+	assert.Equal(t, 6, len(details))
+	assert.NotContains(t, details, "SHIPTOSTREET")
+	assert.NotContains(t, details, "EMAIL")
+}
+
+//==============================================================================
+// EnrichTransactions
+//==============================================================================
+
+type fakeDetailsSource struct {
+	calls []string
+}
+
+func (f *fakeDetailsSource) GetTransactionDetails(ctx context.Context, transactionID string) (NameValues, error) {
+	f.calls = append(f.calls, transactionID)
+	return NameValues{"NOTE": "Note for " + transactionID}, nil
+}
+
+func TestEnrichTransactionsUsesTheCache(t *testing.T) {
+	cache := &DetailsCache{
+		filename: filepath.Join(t.TempDir(), detailsCacheFile),
+		Details: map[string]NameValues{
+			"CACHED": {"NOTE": "From the cache"},
+		},
+	}
+	txns := Transactions{
+		{TransactionID: "CACHED", Type: "Donation", Amt: 5},
+		{TransactionID: "NEW", Type: "Donation", Amt: 10},
+		{TransactionID: "WITHDRAWAL", Type: "Withdrawal", Amt: -100},
+	}
+	src := &fakeDetailsSource{}
+
+	assert.NoError(t, EnrichTransactions(context.Background(), src, cache, txns))
+
+	assert.Equal(t, []string{"NEW"}, src.calls)
+	assert.Equal(t, "From the cache", txns[0].Note)
+	assert.Equal(t, "Note for NEW", txns[1].Note)
+	assert.Equal(t, "", txns[2].Note)
+
+	// The new details were saved, so a second pass fetches nothing
+	assert.FileExists(t, cache.filename)
+	assert.NoError(t, EnrichTransactions(context.Background(), src, cache, txns))
+	assert.Equal(t, []string{"NEW"}, src.calls)
+}
+
+func TestLoadDetailsCacheDropsUnusedDetails(t *testing.T) {
+	chdirForTest(t)
+	assert.NoError(t, os.MkdirAll(AccountDir(""), 0755))
+	filename := filepath.Join(AccountDir(""), detailsCacheFile)
+	assert.NoError(t, ioutil.WriteFile(filename,
+		[]byte(`{"TXN1": {"NOTE": "Thanks", "SHIPTOSTREET": "1007 Mountain Drive"}}`), 0644))
+
+	cache, err := LoadDetailsCache("")
+	assert.NoError(t, err)
+	assert.Equal(t, NameValues{"NOTE": "Thanks"}, cache.Details["TXN1"])
+
+	// It is saved without them even when nothing is fetched
+	assert.NoError(t, EnrichTransactions(context.Background(), &fakeDetailsSource{}, cache, Transactions{}))
+	content, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "Mountain")
+}
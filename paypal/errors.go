@@ -0,0 +1,119 @@
+package paypal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Some of the error codes which can be returned by the NVP API
+const (
+	// ErrorCodeInternal is a generic internal error at PayPal
+	ErrorCodeInternal = "10001"
+	// ErrorCodeAuthentication means the API credentials were not accepted
+	ErrorCodeAuthentication = "10002"
+	// ErrorCodeUnavailable means the API is temporarily unavailable, which is
+	// also what PayPal returns when calls are being throttled
+	ErrorCodeUnavailable = "10101"
+	// ErrorCodeSearchLimit is the warning given when a TransactionSearch has
+	// more results than MaxSearchResults
+	ErrorCodeSearchLimit = "11002"
+)
+
+// ErrorDetail is one of the errors or warnings from the L_ERRORCODEn,
+// L_SHORTMESSAGEn, L_LONGMESSAGEn and L_SEVERITYCODEn fields of an NVP
+// response.
+type ErrorDetail struct {
+	Code         string
+	ShortMessage string
+	LongMessage  string
+	SeverityCode string
+}
+
+func (d ErrorDetail) String() string {
+	return fmt.Sprintf("%s %s: %s (%s)", d.SeverityCode, d.Code, d.ShortMessage, d.LongMessage)
+}
+
+// APIError is returned when a call to the NVP API did not succeed.
+type APIError struct {
+	Method string
+	Ack    string
+	Errors []ErrorDetail
+}
+
+func (e *APIError) Error() string {
+	details := make([]string, len(e.Errors))
+	for i, d := range e.Errors {
+		details[i] = d.String()
+	}
+
+	return fmt.Sprintf("PayPal %s call was not successful (%s): %s",
+		e.Method, e.Ack, strings.Join(details, "; "))
+}
+
+// HasCode returns true if any of the errors have one of the given codes.
+func (e *APIError) HasCode(codes ...string) bool {
+	for _, d := range e.Errors {
+		for _, code := range codes {
+			if d.Code == code {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// RestError is returned when a call to the REST API did not succeed.
+type RestError struct {
+	StatusCode int
+	Name       string `json:"name"`
+	Message    string `json:"message"`
+	DebugID    string `json:"debug_id"`
+
+	// The OAuth2 token endpoint uses these instead
+	OAuthError       string `json:"error"`
+	OAuthDescription string `json:"error_description"`
+}
+
+func (e *RestError) Error() string {
+	name, message := e.Name, e.Message
+	if name == "" {
+		name, message = e.OAuthError, e.OAuthDescription
+	}
+
+	return fmt.Sprintf("PayPal REST call failed with status %d: %s: %s", e.StatusCode, name, message)
+}
+
+// IsAuthError returns true if the error is because PayPal did not accept the
+// API credentials.
+func IsAuthError(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HasCode(ErrorCodeAuthentication)
+	}
+
+	var restErr *RestError
+	if errors.As(err, &restErr) {
+		return restErr.StatusCode == http.StatusUnauthorized
+	}
+
+	return false
+}
+
+// IsRateLimited returns true if the error is because PayPal is limiting how
+// often the API can be called.
+func IsRateLimited(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HasCode(ErrorCodeUnavailable)
+	}
+
+	var restErr *RestError
+	if errors.As(err, &restErr) {
+		return restErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	return false
+}
@@ -1,41 +1,89 @@
 package paypal
 
 import (
-	"encoding/json"
-	"fmt"
-	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
+	"sync"
+
+	"github.com/leavengood/donation_tracker/util"
 )
 
-const dataDir = "data"
+// AccountDir returns the data directory for the named account. The account
+// without a name uses the data directory itself.
+func AccountDir(account string) string {
+	if account == "" {
+		return util.DataDir()
+	}
+
+	return filepath.Join(util.DataDir(), account)
+}
 
-// FileManager manages files containing PayPal transactions fetched from
-// the PayPal API.
+// FileManager manages the months of PayPal transactions fetched from the
+// PayPal API for one account and year, which are kept in the storage set with
+// SetStorage. Several months can be saved at once, but Months should only be
+// used directly when nothing is being saved.
 type FileManager struct {
-	Year   int
-	Months map[int]Transactions
+	Year    int
+	Account string
+	Months  map[int]Transactions
+	// What has been fetched of each saved month, when that is known
+	Coverage map[int]*Coverage
+
+	mu    sync.RWMutex
+	store Storage
 }
 
-// NewFileManager will load any PayPal files for the given year from the
-// data directory, and can be used to save new transactions there.
-func NewFileManager(year int) (*FileManager, error) {
-	months, err := loadPayPalFiles(dataDir, year)
+// NewFileManager will load any saved PayPal transactions for the given account
+// and year from the storage, and can be used to save new transactions there.
+func NewFileManager(account string, year int) (*FileManager, error) {
+	months, err := storage.LoadYear(account, year)
 	if err != nil {
 		return nil, err
 	}
 
-	return &FileManager{
-		Year:   year,
-		Months: months,
-	}, nil
+	fm := NewEmptyFileManager(account, year)
+	for month, saved := range months {
+		fm.label(saved.Transactions)
+		fm.Months[month] = saved.Transactions
+		if saved.Coverage != nil {
+			fm.Coverage[month] = saved.Coverage
+		}
+	}
+
+	return fm, nil
 }
 
-func NewEmptyFileManager(year int) *FileManager {
+func NewEmptyFileManager(account string, year int) *FileManager {
 	return &FileManager{
-		Year:   year,
-		Months: map[int]Transactions{},
+		Year:     year,
+		Account:  account,
+		Months:   map[int]Transactions{},
+		Coverage: map[int]*Coverage{},
+		store:    storage,
+	}
+}
+
+// CombineFileManagers returns a file manager with the transactions of all the
+// given file managers for the same year, for summarizing several accounts
+// together. It cannot be used to save.
+func CombineFileManagers(year int, fms ...*FileManager) *FileManager {
+	result := NewEmptyFileManager("", year)
+
+	for _, fm := range fms {
+		for month, txns := range fm.Months {
+			result.Months[month] = append(result.Months[month], txns...)
+		}
+	}
+	for _, txns := range result.Months {
+		txns.Sort()
+	}
+
+	return result
+}
+
+// label sets the account of the transactions
+func (p *FileManager) label(txns Transactions) {
+	for _, t := range txns {
+		t.Account = p.Account
 	}
 }
 
@@ -43,6 +91,9 @@ func NewEmptyFileManager(year int) *FileManager {
 // file manager. When managing the current year, this helps determine what new
 // data needs to be fetched from the PayPal API.
 func (p *FileManager) GetLatestMonth() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	max := 0
 
 	for month := range p.Months {
@@ -57,7 +108,10 @@ func (p *FileManager) GetLatestMonth() int {
 // GetLatestTransaction will get the most recent transaction from the most
 // recent month stored in this file manager.
 func (p *FileManager) GetLatestTransaction() *Transaction {
-	txns := p.Months[p.GetLatestMonth()]
+	latest := p.GetLatestMonth()
+	p.mu.RLock()
+	txns := p.Months[latest]
+	p.mu.RUnlock()
 
 	// The transactions should be sorted with the latest last
 	if len(txns) > 0 {
@@ -70,6 +124,9 @@ func (p *FileManager) GetLatestTransaction() *Transaction {
 // GetExistingMonths will return all months which are currently stored in this
 // file manager.
 func (p *FileManager) GetExistingMonths() []int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	result := []int{}
 
 	for i := 1; i <= 12; i++ {
@@ -84,6 +141,9 @@ func (p *FileManager) GetExistingMonths() []int {
 // GetMissingMonths will return all months which are not currently stored in
 // this file manager.
 func (p *FileManager) GetMissingMonths() []int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	result := []int{}
 
 	for i := 1; i <= 12; i++ {
@@ -95,86 +155,54 @@ func (p *FileManager) GetMissingMonths() []int {
 	return result
 }
 
-// SaveMonth will save the given transactions to a file for that month, and add
-// these transactions to the Months stored in this manager.
-func (p *FileManager) SaveMonth(month int, txns Transactions) error {
-	filename := payPalTxnsFileName(p.Year, month)
-	if err := savePayPalTxnsToFile(filename, txns); err != nil {
+// SaveMonth will save the given transactions for that month to the storage,
+// along with what has been fetched of the month, and add these to what is
+// stored in this manager.
+func (p *FileManager) SaveMonth(month int, txns Transactions, coverage *Coverage) error {
+	p.label(txns)
+	saved := &SavedMonth{Transactions: txns, Coverage: coverage}
+	if err := p.store.SaveMonth(p.Account, p.Year, month, saved); err != nil {
 		return err
 	}
 
+	p.mu.Lock()
 	p.Months[month] = txns
+	if coverage != nil {
+		p.Coverage[month] = coverage
+	}
+	p.mu.Unlock()
 
 	return nil
 }
 
-func loadPayPalFiles(baseDir string, year int) (map[int]Transactions, error) {
-	result := map[int]Transactions{}
-
-	re := regexp.MustCompile(fmt.Sprintf(`paypal-%d-([0-9]{2}).json`, year))
-
-	err := filepath.Walk(baseDir,
-		func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if !info.IsDir() {
-				if match := re.FindStringSubmatch(path); match != nil {
-					// Get the month from the match
-					month, err := strconv.Atoi(match[1])
-					if err != nil {
-						return err
-					}
-					// fmt.Printf("Found file for year %d: %s. It is for month %d.\n", year, path, month)
-					txns, err := loadPayPalTxnsFromFile(path)
-					if err != nil {
-						return err
-					}
-					result[month] = txns
-				}
-			}
-			return nil
-		},
-	)
-	if err != nil {
-		return nil, err
-	}
+// The start of the name of each month file, as in paypal-2020-01.json
+const payPalFilePrefix = "paypal"
 
-	return result, nil
-}
+// loadPayPalFiles loads the files for the year in the directory, but not its
+// subdirectories, which belong to other accounts.
+func loadPayPalFiles(dir string, year int) (map[int]*SavedMonth, error) {
+	result := map[int]*SavedMonth{}
 
-// TransactionsJSON is the structure that will be saved to and loaded from files.
-type TransactionsJSON struct {
-	Transactions Transactions `json:"transactions"`
-}
-
-func loadPayPalTxnsFromFile(filename string) (Transactions, error) {
-	f, err := os.Open(filename)
+	err := util.LoadMonthFiles(dir, payPalFilePrefix, year, func(month int) interface{} {
+		result[month] = &SavedMonth{}
+		return result[month]
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	dec := json.NewDecoder(f)
-	txnsJSON := TransactionsJSON{}
-	if err := dec.Decode(&txnsJSON); err != nil {
-		return nil, err
-	}
-
-	return txnsJSON.Transactions, nil
-}
-
-func savePayPalTxnsToFile(filename string, txns Transactions) error {
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
+	for _, saved := range result {
+		if saved.Transactions == nil {
+			saved.Transactions = Transactions{}
+		}
 	}
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "  ")
-	txnsJSON := TransactionsJSON{Transactions: txns}
 
-	return enc.Encode(&txnsJSON)
+	return result, nil
 }
 
-func payPalTxnsFileName(year, month int) string {
-	return fmt.Sprintf("%s/paypal-%d-%02d.json", dataDir, year, month)
+// SavedMonth is what is saved for each month, which is also the structure of
+// the month files.
+type SavedMonth struct {
+	Transactions Transactions `json:"transactions"`
+	// What has been fetched of the month, which older files do not have
+	Coverage *Coverage `json:"coverage,omitempty"`
 }
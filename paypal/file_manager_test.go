@@ -0,0 +1,61 @@
+package paypal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leavengood/donation_tracker/util"
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// loadPayPalFiles
+//==============================================================================
+
+func TestLoadPayPalFilesSkipsOtherAccounts(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "europe"), 0755))
+
+	assert.Nil(t, util.SaveMonthFile(dir, payPalFilePrefix, 2020, 1,
+		&SavedMonth{Transactions: Transactions{{Timestamp: day(time.January, 2), TransactionID: "1A"}}}))
+	assert.Nil(t, util.SaveMonthFile(filepath.Join(dir, "europe"), payPalFilePrefix, 2020, 2,
+		&SavedMonth{Transactions: Transactions{{Timestamp: day(time.February, 2), TransactionID: "2A"}}}))
+	assert.Nil(t, util.SaveMonthFile(dir, payPalFilePrefix, 2019, 3,
+		&SavedMonth{Transactions: Transactions{{Timestamp: day(time.March, 2), TransactionID: "3A"}}}))
+
+	months, err := loadPayPalFiles(dir, 2020)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(months))
+	assert.Equal(t, "1A", months[1].Transactions[0].TransactionID)
+
+	months, err = loadPayPalFiles(filepath.Join(dir, "missing"), 2020)
+	assert.Nil(t, err)
+	assert.Empty(t, months)
+}
+
+//==============================================================================
+// CombineFileManagers
+//==============================================================================
+
+func TestCombineFileManagers(t *testing.T) {
+	us := NewEmptyFileManager("", 2020)
+	us.Months[1] = Transactions{{Timestamp: day(time.January, 5), TransactionID: "1B"}}
+	us.label(us.Months[1])
+	europe := NewEmptyFileManager("europe", 2020)
+	europe.Months[1] = Transactions{{Timestamp: day(time.January, 2), TransactionID: "1A"}}
+	europe.Months[2] = Transactions{{Timestamp: day(time.February, 2), TransactionID: "2A"}}
+	europe.label(europe.Months[1])
+
+	combined := CombineFileManagers(2020, us, europe)
+
+	assert.Equal(t, []int{1, 2}, combined.GetExistingMonths())
+	assert.Equal(t, 2, len(combined.Months[1]))
+	// Sorted by date, keeping their accounts
+	assert.Equal(t, "1A", combined.Months[1][0].TransactionID)
+	assert.Equal(t, "europe", combined.Months[1][0].Account)
+	assert.Equal(t, "", combined.Months[1][1].Account)
+	// The accounts themselves are not changed
+	assert.Equal(t, 1, len(us.Months[1]))
+}
@@ -0,0 +1,217 @@
+package paypal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Defaults for the HTTP settings in the Config
+const (
+	DefaultTimeoutSeconds    = 60
+	DefaultMaxRetries        = 4
+	DefaultRequestsPerSecond = 2
+)
+
+const (
+	// The first retry waits about this long, doubling for each retry after
+	baseRetryDelay = time.Second
+	maxRetryDelay  = 30 * time.Second
+)
+
+// StatusError is returned when PayPal responds with an unexpected HTTP status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("PayPal returned HTTP status %d: %s", e.StatusCode, e.Body)
+}
+
+// httpCaller makes HTTP calls to PayPal with a timeout, limiting how often
+// calls are made and retrying transient failures with exponential backoff.
+type httpCaller struct {
+	client     *http.Client
+	maxRetries int
+	baseDelay  time.Duration
+	limiter    *rateLimiter
+}
+
+func newHTTPCaller(config *Config) *httpCaller {
+	timeout := config.TimeoutSeconds
+	if timeout == 0 {
+		timeout = DefaultTimeoutSeconds
+	}
+	maxRetries := config.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	} else if maxRetries < 0 {
+		maxRetries = 0
+	}
+	perSecond := config.RequestsPerSecond
+	if perSecond == 0 {
+		perSecond = DefaultRequestsPerSecond
+	}
+
+	return &httpCaller{
+		client:     &http.Client{Timeout: time.Duration(timeout) * time.Second},
+		maxRetries: maxRetries,
+		baseDelay:  baseRetryDelay,
+		limiter:    newRateLimiter(perSecond),
+	}
+}
+
+// do sends a request made by newRequest and returns the response body. Any
+// status besides 200 OK is returned as a *StatusError along with the body.
+func (h *httpCaller) do(ctx context.Context, newRequest func(context.Context) (*http.Request, error)) ([]byte, error) {
+	req, err := newRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return body, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	return body, nil
+}
+
+// retry calls fn until it succeeds, returns an error which is not transient or
+// the retries run out. The rate limiter is waited on before each call.
+func (h *httpCaller) retry(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := h.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		err := fn()
+		if err == nil || attempt >= h.maxRetries || !isTransient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := h.backoff(attempt)
+		fmt.Fprintf(output(ctx), "    PayPal call failed (%v), retrying in %s\n", err, delay.Round(time.Millisecond))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns how long to wait before the given retry, which doubles each
+// time up to a maximum, with random jitter so retries do not line up.
+func (h *httpCaller) backoff(attempt int) time.Duration {
+	delay := h.baseDelay << uint(attempt)
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// isTransient returns true for errors which might go away if the call is
+// tried again.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HasCode(ErrorCodeInternal, ErrorCodeUnavailable)
+	}
+
+	var restErr *RestError
+	if errors.As(err, &restErr) {
+		return isTransientStatus(restErr.StatusCode)
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return isTransientStatus(statusErr.StatusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+func isTransientStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// rateLimiter spaces out calls so there are no more than a certain number per
+// second.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return &rateLimiter{}
+	}
+
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Wait blocks until another call can be made or the context is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -1,62 +1,227 @@
 package paypal
 
 import (
+	"fmt"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
-	"unicode"
 )
 
-func ParseNvpName(name string) (string, int) {
-	if strings.HasPrefix(name, "L_") {
-		var i int
-		for i = 2; i < len(name); i++ {
-			if unicode.IsNumber(rune(name[i])) {
-				break
-			}
+// ParseNvpName splits the name of a list field like L_AMT3 into its field name
+// and number. It is only a list field if the name is L_ followed by uppercase
+// letters and then a number without leading zeros, otherwise ok is false.
+func ParseNvpName(name string) (field string, number int, ok bool) {
+	if !strings.HasPrefix(name, "L_") {
+		return "", 0, false
+	}
+
+	i := 2
+	for i < len(name) && name[i] >= 'A' && name[i] <= 'Z' {
+		i++
+	}
+	field, digits := name[2:i], name[i:]
+	if field == "" || digits == "" || (len(digits) > 1 && digits[0] == '0') {
+		return "", 0, false
+	}
+	for j := 0; j < len(digits); j++ {
+		if digits[j] < '0' || digits[j] > '9' {
+			return "", 0, false
 		}
-		field := name[2:i]
-		number, _ := strconv.Atoi(name[i:])
+	}
 
-		return field, number
-	} else {
-		return "", 0
+	number, err := strconv.Atoi(digits)
+	if err != nil {
+		return "", 0, false
 	}
+
+	return field, number, true
 }
 
 type NameValues map[string]string
+
+// NvpResult is a decoded NVP response. List fields like L_AMT0 are grouped by
+// their number in List, minus the L_ prefix and the number, except for errors
+// and warnings which are in Errors. Everything else, including ACK, is kept in
+// Fields.
 type NvpResult struct {
-	Ack  string
-	List map[int]NameValues
+	Ack    string
+	Fields NameValues
+	List   map[int]NameValues
+	Errors []ErrorDetail
+}
+
+// These list fields hold errors and warnings instead of results
+var nvpErrorFields = map[string]bool{
+	"ERRORCODE":    true,
+	"SHORTMESSAGE": true,
+	"LONGMESSAGE":  true,
+	"SEVERITYCODE": true,
 }
 
 func (n *NvpResult) Successful() bool {
 	return strings.Contains(n.Ack, "Success")
 }
 
-func ParseNvpData(data string) *NvpResult {
-	result := new(NvpResult)
-	result.List = make(map[int]NameValues)
+// Truncated is true when a TransactionSearch returned more results than PayPal
+// allows, so only some of them were returned. PayPal says so with the search
+// limit warning, which may come with fewer than MaxSearchResults results.
+func (n *NvpResult) Truncated() bool {
+	if n.Ack != "SuccessWithWarning" {
+		return false
+	}
+	for _, e := range n.Errors {
+		if e.Code == ErrorCodeSearchLimit {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Error returns an *APIError for the given method if this result was not
+// successful, otherwise nil.
+func (n *NvpResult) Error(method string) error {
+	if n.Successful() {
+		return nil
+	}
+
+	return &APIError{Method: method, Ack: n.Ack, Errors: n.Errors}
+}
+
+// ListNumbers returns the numbers of the List entries in order.
+func (n *NvpResult) ListNumbers() []int {
+	return sortedKeys(n.List)
+}
+
+func sortedKeys(m map[int]NameValues) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	return keys
+}
+
+// DecodeNvp decodes an NVP response. Every field must have a name and an
+// = sign, the names and values must be properly URL encoded, and no name can
+// appear twice.
+func DecodeNvp(data string) (*NvpResult, error) {
+	if data == "" {
+		return nil, fmt.Errorf("empty NVP data")
+	}
+
+	result := &NvpResult{
+		Fields: NameValues{},
+		List:   map[int]NameValues{},
+	}
+	errors := map[int]NameValues{}
+	seen := map[string]bool{}
+
+	for i, field := range strings.Split(data, "&") {
+		nv := strings.SplitN(field, "=", 2)
+		if len(nv) != 2 {
+			return nil, fmt.Errorf("NVP field %d has no value: %q", i, field)
+		}
+
+		name, err := url.QueryUnescape(nv[0])
+		if err != nil {
+			return nil, fmt.Errorf("NVP field %d has an invalid name: %w", i, err)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("NVP field %d has no name", i)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("NVP field %s appears more than once", name)
+		}
+		seen[name] = true
+
+		value, err := url.QueryUnescape(nv[1])
+		if err != nil {
+			return nil, fmt.Errorf("NVP field %s has an invalid value: %w", name, err)
+		}
+
+		listField, num, ok := ParseNvpName(name)
+		if !ok {
+			result.Fields[name] = value
+			continue
+		}
+
+		list := result.List
+		if nvpErrorFields[listField] {
+			list = errors
+		}
+		if _, found := list[num]; !found {
+			list[num] = NameValues{}
+		}
+		list[num][listField] = value
+	}
+
+	result.Ack = result.Fields["ACK"]
+	for _, num := range sortedKeys(errors) {
+		e := errors[num]
+		result.Errors = append(result.Errors, ErrorDetail{
+			Code:         e["ERRORCODE"],
+			ShortMessage: e["SHORTMESSAGE"],
+			LongMessage:  e["LONGMESSAGE"],
+			SeverityCode: e["SEVERITYCODE"],
+		})
+	}
+
+	return result, nil
+}
+
+// EncodeNvp encodes the names and values in the NVP format, sorted by name.
+func EncodeNvp(nv NameValues) string {
+	names := make([]string, 0, len(nv))
+	for name := range nv {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	fields := strings.Split(string(data), "&")
+	fields := make([]string, len(names))
+	for i, name := range names {
+		fields[i] = url.QueryEscape(name) + "=" + url.QueryEscape(nv[name])
+	}
 
-	for _, field := range fields {
-		nv := strings.Split(field, "=")
-		name := nv[0]
-		value, _ := url.QueryUnescape(nv[1])
+	return strings.Join(fields, "&")
+}
 
-		if name == "ACK" {
-			result.Ack = value
-		} else {
-			field, num := ParseNvpName(name)
-			if field != "" {
-				if _, found := result.List[num]; !found {
-					result.List[num] = make(NameValues)
-				}
-				result.List[num][field] = value
-			}
+// NameValues returns all the fields of the result as they would appear in an
+// NVP response.
+func (n *NvpResult) NameValues() NameValues {
+	nv := NameValues{}
+	for name, value := range n.Fields {
+		nv[name] = value
+	}
+	if n.Ack != "" {
+		nv["ACK"] = n.Ack
+	}
+	for num, item := range n.List {
+		for field, value := range item {
+			nv[fmt.Sprintf("L_%s%d", field, num)] = value
 		}
 	}
+	for i, e := range n.Errors {
+		// The code is always included so there is an entry for every error
+		nv[fmt.Sprintf("L_ERRORCODE%d", i)] = e.Code
+		if e.ShortMessage != "" {
+			nv[fmt.Sprintf("L_SHORTMESSAGE%d", i)] = e.ShortMessage
+		}
+		if e.LongMessage != "" {
+			nv[fmt.Sprintf("L_LONGMESSAGE%d", i)] = e.LongMessage
+		}
+		if e.SeverityCode != "" {
+			nv[fmt.Sprintf("L_SEVERITYCODE%d", i)] = e.SeverityCode
+		}
+	}
+
+	return nv
+}
 
-	return result
+// Encode encodes the result as an NVP response, which DecodeNvp turns back
+// into the same result.
+func (n *NvpResult) Encode() string {
+	return EncodeNvp(n.NameValues())
 }
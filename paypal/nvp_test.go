@@ -0,0 +1,170 @@
+package paypal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Shapes of real NVP responses, with made up data
+var nvpSeeds = []string{
+	// TransactionSearch
+	"L_TIMESTAMP0=2020%2d03%2d02T10%3a15%3a00Z&L_TIMESTAMP1=2020%2d03%2d01T08%3a00%3a00Z" +
+		"&L_TIMEZONE0=GMT&L_TIMEZONE1=GMT&L_TYPE0=Donation&L_TYPE1=Recurring%20Payment" +
+		"&L_EMAIL0=bruce%40wayneenterprises%2ecom&L_EMAIL1=cat%40woman%2ecom" +
+		"&L_NAME0=Bruce%20Wayne&L_NAME1=Selina%20Kyle&L_TRANSACTIONID0=5TY05013RG002845M" +
+		"&L_TRANSACTIONID1=I%2dBRHMN0A1B2C3&L_STATUS0=Completed&L_STATUS1=Created" +
+		"&L_AMT0=25%2e00&L_AMT1=0%2e00&L_CURRENCYCODE0=USD&L_CURRENCYCODE1=EUR" +
+		"&L_FEEAMT0=%2d1%2e03&L_FEEAMT1=0%2e00&L_NETAMT0=23%2e97&L_NETAMT1=0%2e00" +
+		"&TIMESTAMP=2020%2d03%2d03T01%3a02%3a03Z&CORRELATIONID=f0a1b2c3d4e5&ACK=Success" +
+		"&VERSION=117%2e0&BUILD=39206242",
+	// TransactionSearch with too many results
+	"L_TIMESTAMP0=2020%2d03%2d02T10%3a15%3a00Z&L_TRANSACTIONID0=5TY05013RG002845M" +
+		"&TIMESTAMP=2020%2d03%2d03T01%3a02%3a03Z&CORRELATIONID=f0a1b2c3d4e5&ACK=SuccessWithWarning" +
+		"&VERSION=117%2e0&BUILD=39206242&L_ERRORCODE0=11002&L_SHORTMESSAGE0=Search%20warning" +
+		"&L_LONGMESSAGE0=Search%20results%20were%20truncated%2e&L_SEVERITYCODE0=Warning",
+	// Failure
+	"TIMESTAMP=2020%2d03%2d03T01%3a02%3a03Z&CORRELATIONID=f0a1b2c3d4e5&ACK=Failure&VERSION=117%2e0" +
+		"&BUILD=39206242&L_ERRORCODE0=10002&L_SHORTMESSAGE0=Security%20error" +
+		"&L_LONGMESSAGE0=Security%20header%20is%20not%20valid&L_SEVERITYCODE0=Error",
+	// GetTransactionDetails
+	"RECEIVEREMAIL=donations%40haiku%2dinc%2eorg&EMAIL=bruce%40wayneenterprises%2ecom" +
+		"&COUNTRYCODE=US&NOTE=Keep%20up%20the%20good%20work%21&CUSTOM=a%3db%26c%3dd" +
+		"&INVNUM=INV%2d42&L_NAME0=Donation%20to%20Haiku%2c%20Inc%2e&L_QTY0=1" +
+		"&TIMESTAMP=2020%2d03%2d03T01%3a02%3a03Z&ACK=Success",
+}
+
+//==============================================================================
+// ParseNvpName
+//==============================================================================
+
+func TestParseNvpName(t *testing.T) {
+	field, num, ok := ParseNvpName("L_AMT12")
+	assert.True(t, ok)
+	assert.Equal(t, "AMT", field)
+	assert.Equal(t, 12, num)
+
+	for _, name := range []string{"AMT", "L_", "L_AMT", "L_12", "L_AMT01", "L_AMT1X", "L_amt1", "TIMESTAMP"} {
+		_, _, ok := ParseNvpName(name)
+		assert.False(t, ok, name)
+	}
+}
+
+//==============================================================================
+// DecodeNvp
+//==============================================================================
+
+func TestDecodeNvpKeepsTopLevelFields(t *testing.T) {
+	result, err := DecodeNvp(nvpSeeds[0])
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Success", result.Ack)
+	assert.Equal(t, "2020-03-03T01:02:03Z", result.Fields["TIMESTAMP"])
+	assert.Equal(t, "f0a1b2c3d4e5", result.Fields["CORRELATIONID"])
+	assert.Equal(t, "117.0", result.Fields["VERSION"])
+	assert.Equal(t, 2, len(result.List))
+	assert.Equal(t, "Recurring Payment", result.List[1]["TYPE"])
+	assert.Equal(t, 0, len(result.Errors))
+}
+
+func TestDecodeNvpSeparatesErrors(t *testing.T) {
+	result, err := DecodeNvp(nvpSeeds[1])
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, len(result.List))
+	assert.Equal(t, "", result.List[0]["ERRORCODE"])
+	assert.Equal(t, []ErrorDetail{{
+		Code:         ErrorCodeSearchLimit,
+		ShortMessage: "Search warning",
+		LongMessage:  "Search results were truncated.",
+		SeverityCode: "Warning",
+	}}, result.Errors)
+}
+
+func TestDecodeNvpKeepsEncodedEquals(t *testing.T) {
+	result, err := DecodeNvp(nvpSeeds[3])
+	assert.NoError(t, err)
+
+	assert.Equal(t, "a=b&c=d", result.Fields["CUSTOM"])
+}
+
+func TestDecodeNvpErrors(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"ACK",
+		"ACK=Success&TIMESTAMP",
+		"=Success",
+		"ACK=Success&ACK=Failure",
+		"ACK=%zz",
+		"%zz=Success",
+	} {
+		_, err := DecodeNvp(data)
+		assert.Error(t, err, data)
+	}
+}
+
+//==============================================================================
+// EncodeNvp
+//==============================================================================
+
+func TestEncodeNvp(t *testing.T) {
+	assert.Equal(t, "ACK=Success&L_NAME0=Bruce+Wayne&NOTE=a%3Db%26c",
+		EncodeNvp(NameValues{"NOTE": "a=b&c", "ACK": "Success", "L_NAME0": "Bruce Wayne"}))
+}
+
+//==============================================================================
+// Fuzzing
+//==============================================================================
+
+func FuzzDecodeNvp(f *testing.F) {
+	for _, seed := range nvpSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data string) {
+		result, err := DecodeNvp(data)
+		if err != nil {
+			return
+		}
+
+		// Anything which decodes should encode to something which decodes
+		// back to the same result.
+		again, err := DecodeNvp(result.Encode())
+		if err != nil {
+			t.Fatalf("could not decode the encoded result of %q: %v", data, err)
+		}
+		assert.Equal(t, result, again)
+	})
+}
+
+func FuzzEncodeNvp(f *testing.F) {
+	f.Add("ACK", "Success", "L_AMT0", "5.00")
+	f.Add("NOTE", "a=b&c=d", "L_ERRORCODE0", "10002")
+	f.Add("L_AMT01", "%zz", "L_", "")
+
+	f.Fuzz(func(t *testing.T, name1, value1, name2, value2 string) {
+		if name1 == "" || name2 == "" || name1 == name2 {
+			return
+		}
+
+		nv := NameValues{name1: value1, name2: value2}
+		result, err := DecodeNvp(EncodeNvp(nv))
+		if err != nil {
+			t.Fatalf("could not decode encoded names %q and %q: %v", name1, name2, err)
+		}
+
+		// Top-level fields come back as they were
+		for name, value := range nv {
+			if _, _, ok := ParseNvpName(name); !ok {
+				assert.Equal(t, value, result.Fields[name])
+			}
+		}
+
+		// Encoding again is stable
+		again, err := DecodeNvp(result.Encode())
+		if err != nil {
+			t.Fatalf("could not decode the encoded result of %v: %v", nv, err)
+		}
+		assert.Equal(t, result, again)
+	})
+}
@@ -0,0 +1,24 @@
+package paypal
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+type outputKey struct{}
+
+// WithOutput returns a context which makes the progress messages printed
+// while getting transactions go to w instead of standard output.
+func WithOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+// output returns where progress messages should go for the context.
+func output(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(outputKey{}).(io.Writer); ok {
+		return w
+	}
+
+	return os.Stdout
+}
@@ -0,0 +1,87 @@
+package paypal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// PlannedFetch is a window of a saved month which still needs to be fetched.
+type PlannedFetch struct {
+	Month      int
+	Start, End time.Time
+}
+
+// PlanFetches returns the months of the year, up to now, which have nothing
+// saved, and what still needs fetching of the saved months. For a month which
+// was not complete when it was fetched that is from the start of the day it
+// was fetched up to, so nothing PayPal was slow to list is missed, until the
+// end of the month or now. It starts earlier for any pending transaction, so
+// its final status is saved.
+func (p *FileManager) PlanFetches() ([]int, []PlannedFetch) {
+	missing := []int{}
+	partial := []PlannedFetch{}
+
+	last := 12
+	if year, month, _ := util.InLocation(now()).Date(); p.Year == year {
+		last = int(month)
+	} else if p.Year > year {
+		last = 0
+	}
+
+	for month := 1; month <= last; month++ {
+		c := p.CoverageOf(month)
+		if c == nil {
+			missing = append(missing, month)
+			continue
+		}
+
+		var start time.Time
+		if monthStart := util.MonthStart(p.Year, time.Month(month)); c.Start.After(monthStart) {
+			start = monthStart
+		} else if !c.Complete {
+			start = util.DayStart(c.End)
+		}
+		p.mu.RLock()
+		pending := p.Months[month].EarliestPending()
+		p.mu.RUnlock()
+		if pending != nil {
+			if pendingStart := util.DayStart(pending.Timestamp); start.IsZero() || pendingStart.Before(start) {
+				start = pendingStart
+			}
+		}
+
+		if !start.IsZero() {
+			partial = append(partial, PlannedFetch{Month: month, Start: start, End: monthEndOrNow(p.Year, month)})
+		}
+	}
+
+	return missing, partial
+}
+
+// FetchAndMerge gets the planned window of the month from the source and
+// merges it into the saved month, replacing transactions whose status changed,
+// and saves it along with its coverage.
+func FetchAndMerge(ctx context.Context, src TransactionSource, fm *FileManager, f PlannedFetch) error {
+	monthStr := fmt.Sprintf("%s %d", time.Month(f.Month), fm.Year)
+	fmt.Fprintf(output(ctx), "Fetching PayPal transactions for %s from %s to %s...",
+		util.Colorize(util.Green, monthStr), util.FormatDateTime(f.Start), util.FormatDateTime(f.End))
+	newTxns, err := src.GetTransactions(ctx, f.Start.UTC().Format(PayPalDateFormat), f.End.UTC().Format(PayPalDateFormat))
+	if err != nil {
+		fmt.Fprintln(output(ctx), "failed.")
+		return fmt.Errorf("could not get transactions for %s: %w", monthStr, err)
+	}
+
+	fm.mu.RLock()
+	previous := fm.Months[f.Month]
+	fm.mu.RUnlock()
+	// Merge will remove any duplicates and update any whose status changed
+	txns, updated := previous.MergeUpdated(newTxns)
+	txns.Sort()
+	fmt.Fprintf(output(ctx), "%d new and %d updated transactions.\n", len(txns)-len(previous), updated)
+
+	coverage := fm.CoverageOf(f.Month).Extend(fm.Year, f.Month, f.Start, f.End, SourceAPI(src))
+	return fm.SaveMonth(f.Month, txns, coverage)
+}
@@ -0,0 +1,230 @@
+package paypal
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/leavengood/donation_tracker/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// setNowForTest makes it be the time until the test is done
+func setNowForTest(t *testing.T, n time.Time) {
+	SetNow(func() time.Time { return n })
+	t.Cleanup(func() { SetNow(time.Now) })
+}
+
+// chdirForTest runs the rest of the test in an empty temporary directory, so
+// the data directory starts out empty.
+func chdirForTest(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func monthEnd(month time.Month) time.Time {
+	return util.MonthEnd(2020, month)
+}
+
+//==============================================================================
+// Coverage
+//==============================================================================
+
+func TestCoverageExtend(t *testing.T) {
+	setNowForTest(t, day(time.March, 10))
+
+	c := NewCoverage(2020, 1, day(time.January, 1).Add(-12*time.Hour), day(time.January, 20), APINvp)
+	assert.False(t, c.Complete)
+	assert.Equal(t, day(time.March, 10), c.FetchedAt)
+
+	// The rest of the month
+	rest := c.Extend(2020, 1, day(time.January, 20).Add(-12*time.Hour), monthEnd(time.January), APIRest)
+	assert.Equal(t, c.Start, rest.Start)
+	assert.Equal(t, monthEnd(time.January), rest.End)
+	assert.Equal(t, APIRest, rest.Source)
+	assert.True(t, rest.Complete)
+
+	// Something later which does not touch it
+	assert.Equal(t, c, c.Extend(2020, 1, day(time.January, 25), monthEnd(time.January), SourceCSV))
+
+	var none *Coverage
+	assert.Equal(t, day(time.January, 25), none.Extend(2020, 1, day(time.January, 25), day(time.January, 26), SourceCSV).Start)
+}
+
+//==============================================================================
+// PlanFetches
+//==============================================================================
+
+func TestPlanFetches(t *testing.T) {
+	setNowForTest(t, day(time.March, 10))
+
+	fm := NewEmptyFileManager("", 2020)
+	// January is complete but has a pending payment
+	fm.Months[1] = Transactions{
+		{Timestamp: day(time.January, 15), TransactionID: "1A", Status: StatusPending},
+		{Timestamp: day(time.January, 20), TransactionID: "1B", Status: StatusCompleted},
+	}
+	fm.Coverage[1] = NewCoverage(2020, 1, util.MonthStart(2020, time.January), monthEnd(time.January), APINvp)
+	// February was fetched on the 20th
+	fm.Months[2] = Transactions{}
+	fm.Coverage[2] = NewCoverage(2020, 2, util.MonthStart(2020, time.February), day(time.February, 20), APINvp)
+
+	missing, partial := fm.PlanFetches()
+	// Later months are not there yet
+	assert.Equal(t, []int{3}, missing)
+	assert.Equal(t, []PlannedFetch{
+		{Month: 1, Start: util.DayStart(day(time.January, 15)), End: monthEnd(time.January)},
+		{Month: 2, Start: util.DayStart(day(time.February, 20)), End: monthEnd(time.February)},
+	}, partial)
+
+	// Once it is all fetched there is nothing to do
+	fm.Months[1][0].Status = StatusCompleted
+	fm.Coverage[2].End = monthEnd(time.February)
+	fm.Coverage[2].Complete = true
+	fm.Months[3] = Transactions{}
+	fm.Coverage[3] = NewCoverage(2020, 3, util.MonthStart(2020, time.March), day(time.March, 10), APINvp)
+	missing, partial = fm.PlanFetches()
+	assert.Empty(t, missing)
+	assert.Equal(t, []PlannedFetch{
+		{Month: 3, Start: util.DayStart(day(time.March, 10)), End: day(time.March, 10)},
+	}, partial)
+
+	// A later year has nothing to fetch
+	missing, partial = NewEmptyFileManager("", 2021).PlanFetches()
+	assert.Empty(t, missing)
+	assert.Empty(t, partial)
+}
+
+func TestPlanFetchesWithoutCoverage(t *testing.T) {
+	setNowForTest(t, time.Date(2021, time.January, 5, 0, 0, 0, 0, time.UTC))
+
+	// Months saved before the coverage was recorded
+	fm := NewEmptyFileManager("", 2020)
+	fm.Months[1] = Transactions{{Timestamp: day(time.January, 2), TransactionID: "1A"}}
+	fm.Months[11] = Transactions{{Timestamp: day(time.November, 9), TransactionID: "11A"}}
+
+	assert.True(t, fm.CoverageOf(1).Complete)
+	assert.Nil(t, fm.CoverageOf(2))
+
+	missing, partial := fm.PlanFetches()
+	assert.Equal(t, []int{2, 3, 4, 5, 6, 7, 8, 9, 10, 12}, missing)
+	// The latest is taken to be fetched up to its latest transaction
+	assert.Equal(t, []PlannedFetch{
+		{Month: 11, Start: util.DayStart(day(time.November, 9)), End: monthEnd(time.November)},
+	}, partial)
+}
+
+func TestStopsEarly(t *testing.T) {
+	setNowForTest(t, time.Date(2021, time.January, 5, 0, 0, 0, 0, time.UTC))
+
+	fm := NewEmptyFileManager("", 2020)
+	assert.False(t, fm.StopsEarly())
+
+	// Saved before the coverage was recorded, so it is not known
+	fm.Months[12] = Transactions{}
+	assert.False(t, fm.StopsEarly())
+
+	fm.Coverage[12] = NewCoverage(2020, 12, util.MonthStart(2020, time.December), day(time.December, 28), APINvp)
+	assert.True(t, fm.StopsEarly())
+	fm.Coverage[12] = NewCoverage(2020, 12, util.MonthStart(2020, time.December), monthEnd(time.December), APINvp)
+	assert.False(t, fm.StopsEarly())
+
+	// December was never fetched
+	fm = NewEmptyFileManager("", 2020)
+	fm.Months[11] = Transactions{}
+	fm.Coverage[11] = NewCoverage(2020, 11, util.MonthStart(2020, time.November), monthEnd(time.November), APINvp)
+	assert.True(t, fm.StopsEarly())
+}
+
+//==============================================================================
+// FetchAndMerge
+//==============================================================================
+
+// rangeSource returns its transactions and remembers the dates asked for
+type rangeSource struct {
+	txns       Transactions
+	start, end string
+}
+
+func (s *rangeSource) GetTransactions(ctx context.Context, startDate, endDate string) (Transactions, error) {
+	s.start, s.end = startDate, endDate
+	return s.txns, nil
+}
+
+func (s *rangeSource) API() string {
+	return APIRest
+}
+
+func TestFetchAndMerge(t *testing.T) {
+	chdirForTest(t)
+	setNowForTest(t, day(time.March, 10))
+
+	fm := NewEmptyFileManager("", 2020)
+	fm.Months[2] = Transactions{
+		{Timestamp: day(time.February, 2), TransactionID: "2A"},
+		{Timestamp: day(time.February, 20), TransactionID: "2B", Status: StatusPending},
+	}
+	fm.Coverage[2] = NewCoverage(2020, 2, util.MonthStart(2020, time.February), day(time.February, 20), APINvp)
+
+	src := &rangeSource{txns: Transactions{
+		{Timestamp: day(time.February, 20), TransactionID: "2B", Status: StatusCompleted},
+		{Timestamp: day(time.February, 25), TransactionID: "2C", Status: StatusCompleted},
+	}}
+	_, partial := fm.PlanFetches()
+	out := &bytes.Buffer{}
+	assert.Nil(t, FetchAndMerge(WithOutput(context.Background(), out), src, fm, partial[0]))
+
+	assert.Equal(t, "2020-02-20T00:00:00Z", src.start)
+	assert.Equal(t, "2020-02-29T23:59:59Z", src.end)
+	assert.Contains(t, out.String(), "1 new and 1 updated transactions.")
+	assert.Equal(t, 3, len(fm.Months[2]))
+	assert.Equal(t, StatusCompleted, fm.Months[2][1].Status)
+	assert.True(t, fm.Coverage[2].Complete)
+	assert.Equal(t, APIRest, fm.Coverage[2].Source)
+
+	// It was saved with the coverage
+	loaded, err := NewFileManager("", 2020)
+	assert.Nil(t, err)
+	assert.Equal(t, fm.Coverage[2], loaded.Coverage[2])
+	missing, partial := loaded.PlanFetches()
+	assert.Equal(t, []int{1, 3}, missing)
+	assert.Empty(t, partial)
+}
+
+func TestFetchAndMergeSorts(t *testing.T) {
+	chdirForTest(t)
+	setNowForTest(t, day(time.March, 10))
+
+	fm := NewEmptyFileManager("", 2020)
+	fm.Months[2] = Transactions{
+		{Timestamp: day(time.February, 2), TransactionID: "2A", Status: StatusCompleted},
+		{Timestamp: day(time.February, 27), TransactionID: "2D", Status: StatusCompleted},
+	}
+
+	// The new transactions are older than the last saved one, and out of order
+	src := &rangeSource{txns: Transactions{
+		{Timestamp: day(time.February, 25), TransactionID: "2C", Status: StatusCompleted},
+		{Timestamp: day(time.February, 10), TransactionID: "2B", Status: StatusCompleted},
+	}}
+	f := PlannedFetch{Month: 2, Start: util.MonthStart(2020, time.February), End: monthEnd(time.February)}
+	assert.Nil(t, FetchAndMerge(WithOutput(context.Background(), &bytes.Buffer{}), src, fm, f))
+
+	ids := []string{}
+	for _, txn := range fm.Months[2] {
+		ids = append(ids, txn.TransactionID)
+	}
+	assert.Equal(t, []string{"2A", "2B", "2C", "2D"}, ids)
+	assert.Equal(t, "2D", fm.GetLatestTransaction().TransactionID)
+
+	loaded, err := NewFileManager("", 2020)
+	assert.Nil(t, err)
+	assert.Equal(t, "2D", loaded.GetLatestTransaction().TransactionID)
+}
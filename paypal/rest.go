@@ -0,0 +1,364 @@
+package paypal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	restTokenPath        = "/v1/oauth2/token"
+	restTransactionsPath = "/v1/reporting/transactions"
+
+	// RestDateFormat is the date format used by the REST Reporting API
+	RestDateFormat = "2006-01-02T15:04:05-0700"
+
+	// The Reporting API will not search more than 31 days at a time
+	maxRestWindow = 31 * 24 * time.Hour
+
+	restPageSize = 500
+
+	// Get a new token a bit before the old one expires
+	tokenExpiryMargin = time.Minute
+)
+
+// RestClient gets transactions from the PayPal REST Reporting API, using the
+// OAuth2 client credentials flow to get an access token, which is cached until
+// it expires.
+type RestClient struct {
+	config *Config
+	http   *httpCaller
+
+	mu           sync.Mutex
+	token        string
+	tokenExpires time.Time
+}
+
+func NewRestClient(config *Config) *RestClient {
+	return &RestClient{
+		config: config,
+		http:   newHTTPCaller(config),
+	}
+}
+
+// API returns which PayPal API the client uses.
+func (c *RestClient) API() string {
+	return APIRest
+}
+
+type restToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func (c *RestClient) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.tokenExpires) {
+		return c.token, nil
+	}
+
+	v := url.Values{}
+	v.Set("grant_type", "client_credentials")
+
+	token := restToken{}
+	err := c.doRequest(ctx, &token, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.RestEndpoint+restTokenPath,
+			strings.NewReader(v.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(c.config.ClientID, c.config.Secret)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not get a PayPal access token: %w", err)
+	}
+
+	c.token = token.AccessToken
+	c.tokenExpires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - tokenExpiryMargin)
+
+	return c.token, nil
+}
+
+// dropToken forgets the access token when PayPal no longer accepts it, unless
+// it was already replaced.
+func (c *RestClient) dropToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+// doAuthorized is doRequest with an access token for newRequest to use. A
+// token which PayPal turns down, because it was revoked or expired early, is
+// dropped and the request is tried once more with a new one.
+func (c *RestClient) doAuthorized(ctx context.Context, result interface{},
+	newRequest func(ctx context.Context, token string) (*http.Request, error)) error {
+	for retried := false; ; retried = true {
+		token, err := c.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		err = c.doRequest(ctx, result, func(ctx context.Context) (*http.Request, error) {
+			return newRequest(ctx, token)
+		})
+		if retried || !IsAuthError(err) {
+			return err
+		}
+		c.dropToken(token)
+	}
+}
+
+// doRequest sends the request made by newRequest and decodes the JSON response
+// into result, retrying transient failures.
+func (c *RestClient) doRequest(ctx context.Context, result interface{},
+	newRequest func(context.Context) (*http.Request, error)) error {
+	return c.http.retry(ctx, func() error {
+		body, err := c.http.do(ctx, newRequest)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			restErr := &RestError{StatusCode: statusErr.StatusCode}
+			if err := json.Unmarshal(body, restErr); err != nil {
+				restErr.Message = string(body)
+			}
+			return restErr
+		}
+		if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(body, result)
+	})
+}
+
+// GetTransactions gets all transactions between the two dates, which should be
+// in PayPalDateFormat. An empty end date means up to now. Ranges longer than the
+// Reporting API allows are fetched in several parts.
+func (c *RestClient) GetTransactions(ctx context.Context, startDate, endDate string) (Transactions, error) {
+	fmt.Fprintf(output(ctx), "Getting PayPal data from %s to %s\n", startDate, endDate)
+
+	start, end, err := parseDateRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	result := Transactions{}
+	for windowStart := start; windowStart.Before(end); windowStart = windowStart.Add(maxRestWindow) {
+		windowEnd := windowStart.Add(maxRestWindow - time.Second)
+		if windowEnd.After(end) {
+			windowEnd = end
+		}
+
+		txns, err := c.searchWindow(ctx, windowStart, windowEnd)
+		if err != nil {
+			return nil, err
+		}
+		result = result.Merge(txns)
+	}
+	result.Sort()
+
+	return result, nil
+}
+
+type restMoney struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+func (m *restMoney) amount() float32 {
+	if m == nil {
+		return 0
+	}
+	f, _ := strconv.ParseFloat(m.Value, 32)
+	return float32(f)
+}
+
+type restTransactionDetail struct {
+	TransactionInfo struct {
+		TransactionID  string     `json:"transaction_id"`
+		EventCode      string     `json:"transaction_event_code"`
+		InitiationDate string     `json:"transaction_initiation_date"`
+		Amount         restMoney  `json:"transaction_amount"`
+		Fee            *restMoney `json:"fee_amount"`
+		Status         string     `json:"transaction_status"`
+		Note           string     `json:"transaction_note"`
+		Custom         string     `json:"custom_field"`
+		InvoiceID      string     `json:"invoice_id"`
+		ReferenceID    string     `json:"paypal_reference_id"`
+		ReferenceType  string     `json:"paypal_reference_id_type"`
+	} `json:"transaction_info"`
+	PayerInfo struct {
+		Email       string `json:"email_address"`
+		CountryCode string `json:"country_code"`
+		PayerName   struct {
+			GivenName         string `json:"given_name"`
+			Surname           string `json:"surname"`
+			AlternateFullName string `json:"alternate_full_name"`
+		} `json:"payer_name"`
+	} `json:"payer_info"`
+	CartInfo struct {
+		ItemDetails []struct {
+			ItemName string `json:"item_name"`
+		} `json:"item_details"`
+	} `json:"cart_info"`
+}
+
+type restTransactionsResponse struct {
+	TransactionDetails []*restTransactionDetail `json:"transaction_details"`
+	Page               int                      `json:"page"`
+	TotalPages         int                      `json:"total_pages"`
+}
+
+func (c *RestClient) searchWindow(ctx context.Context, start, end time.Time) (Transactions, error) {
+	result := Transactions{}
+
+	for page := 1; ; page++ {
+		v := url.Values{}
+		v.Set("start_date", start.Format(RestDateFormat))
+		v.Set("end_date", end.Format(RestDateFormat))
+		v.Set("fields", "all")
+		v.Set("page_size", strconv.Itoa(restPageSize))
+		v.Set("page", strconv.Itoa(page))
+
+		resp := restTransactionsResponse{}
+		err := c.doAuthorized(ctx, &resp, func(ctx context.Context, token string) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+				c.config.RestEndpoint+restTransactionsPath+"?"+v.Encode(), nil)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Accept", "application/json")
+			return req, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not get PayPal transactions: %w", err)
+		}
+
+		for _, detail := range resp.TransactionDetails {
+			result = append(result, detail.Transaction())
+		}
+
+		if page >= resp.TotalPages {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// restEventTypes maps REST event codes to the transaction types used by the
+// NVP API, so both backends can be summarized the same way.
+var restEventTypes = map[string]string{
+	"T0002": "Recurring Payment",
+	"T0013": "Donation",
+	"T0200": "Currency Conversion",
+	"T0201": "Currency Conversion",
+	"T0202": "Currency Conversion",
+	"T1106": "Reversal",
+	"T1107": "Refund",
+	"T1201": "Chargeback",
+}
+
+// restEventGroupTypes is used for event codes not in restEventTypes, based on
+// the event code group.
+var restEventGroupTypes = map[string]string{
+	"T00": "Payment",
+	"T01": "Fee",
+	"T02": "Currency Conversion",
+	"T03": "Deposit",
+	"T04": "Withdrawal",
+	"T11": "Reversal",
+	"T12": "Adjustment",
+}
+
+// EventCodeType returns the NVP transaction type for a REST event code. Unknown
+// codes are returned as is.
+func EventCodeType(code string) string {
+	if t, found := restEventTypes[code]; found {
+		return t
+	}
+	if len(code) >= 3 {
+		if t, found := restEventGroupTypes[code[:3]]; found {
+			return t
+		}
+	}
+
+	return code
+}
+
+// restStatuses maps REST status codes to the statuses used by the NVP API.
+var restStatuses = map[string]string{
+	"D": "Denied",
+	"P": "Pending",
+	"S": "Completed",
+	"V": "Reversed",
+}
+
+// Transaction converts the REST transaction details into a Transaction like
+// those from the NVP API.
+func (d *restTransactionDetail) Transaction() *Transaction {
+	info := d.TransactionInfo
+	timestamp, _ := time.Parse(RestDateFormat, info.InitiationDate)
+
+	name := d.PayerInfo.PayerName.AlternateFullName
+	if name == "" {
+		name = strings.TrimSpace(d.PayerInfo.PayerName.GivenName + " " + d.PayerInfo.PayerName.Surname)
+	}
+
+	status, found := restStatuses[info.Status]
+	if !found {
+		status = info.Status
+	}
+
+	amt := info.Amount.amount()
+	fee := info.Fee.amount()
+
+	itemName := ""
+	if len(d.CartInfo.ItemDetails) > 0 {
+		itemName = d.CartInfo.ItemDetails[0].ItemName
+	}
+
+	profileID, parentID := "", ""
+	switch info.ReferenceType {
+	case "SUB":
+		profileID = info.ReferenceID
+	case "TXN":
+		parentID = info.ReferenceID
+	}
+
+	return &Transaction{
+		Timestamp:     timestamp.UTC(),
+		Type:          EventCodeType(info.EventCode),
+		Email:         d.PayerInfo.Email,
+		Name:          name,
+		TransactionID: info.TransactionID,
+		Status:        status,
+		Amt:           amt,
+		FeeAmt:        fee,
+		NetAmt:        amt + fee,
+		CurrencyCode:  info.Amount.CurrencyCode,
+		Note:          info.Note,
+		CountryCode:   d.PayerInfo.CountryCode,
+		Custom:        info.Custom,
+		InvoiceID:     info.InvoiceID,
+		ItemName:      itemName,
+		ProfileID:     profileID,
+
+		ParentTransactionID: parentID,
+	}
+}
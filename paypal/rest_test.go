@@ -0,0 +1,221 @@
+package paypal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// RestClient
+//==============================================================================
+
+const restPage1 = `{
+  "transaction_details": [
+    {
+      "transaction_info": {
+        "transaction_id": "5TY05013RG002845M",
+        "transaction_event_code": "T0013",
+        "transaction_initiation_date": "2020-03-02T10:15:00+0000",
+        "transaction_amount": {"currency_code": "USD", "value": "25.00"},
+        "fee_amount": {"currency_code": "USD", "value": "-1.03"},
+        "transaction_status": "S"
+      },
+      "payer_info": {
+        "email_address": "bruce@wayneenterprises.com",
+        "payer_name": {"given_name": "Bruce", "surname": "Wayne"}
+      }
+    }
+  ],
+  "page": 1,
+  "total_pages": 2
+}`
+
+const restPage2 = `{
+  "transaction_details": [
+    {
+      "transaction_info": {
+        "transaction_id": "8HX15236KJ4071234",
+        "transaction_event_code": "T0002",
+        "transaction_initiation_date": "2020-03-05T08:00:00-0700",
+        "transaction_amount": {"currency_code": "EUR", "value": "10.00"},
+        "fee_amount": {"currency_code": "EUR", "value": "-0.64"},
+        "transaction_status": "P"
+      },
+      "payer_info": {
+        "email_address": "cat@woman.com",
+        "payer_name": {"alternate_full_name": "Selina Kyle"}
+      }
+    }
+  ],
+  "page": 2,
+  "total_pages": 2
+}`
+
+func newRestServer(t *testing.T, tokenCalls, searchCalls *int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(restTokenPath, func(w http.ResponseWriter, r *http.Request) {
+		*tokenCalls++
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client-id", user)
+		assert.Equal(t, "secret", pass)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		fmt.Fprint(w, `{"access_token": "token-123", "token_type": "Bearer", "expires_in": 32400}`)
+	})
+	mux.HandleFunc(restTransactionsPath, func(w http.ResponseWriter, r *http.Request) {
+		*searchCalls++
+		assert.Equal(t, "Bearer token-123", r.Header.Get("Authorization"))
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, restPage2)
+		} else {
+			fmt.Fprint(w, restPage1)
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func testRestConfig(endpoint, secret string) *Config {
+	return &Config{
+		API:               APIRest,
+		RestEndpoint:      endpoint,
+		ClientID:          "client-id",
+		Secret:            secret,
+		RequestsPerSecond: 1000,
+	}
+}
+
+func TestRestClientGetTransactions(t *testing.T) {
+	tokenCalls, searchCalls := 0, 0
+	ts := newRestServer(t, &tokenCalls, &searchCalls)
+	defer ts.Close()
+
+	client := NewRestClient(testRestConfig(ts.URL, "secret"))
+	txns, err := client.GetTransactions(context.Background(), "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, searchCalls)
+	assert.Equal(t, 2, len(txns))
+
+	donation := txns[0]
+	assert.Equal(t, "5TY05013RG002845M", donation.TransactionID)
+	assert.Equal(t, "Donation", donation.Type)
+	assert.Equal(t, "Completed", donation.Status)
+	assert.Equal(t, "Bruce Wayne", donation.Name)
+	assert.Equal(t, "bruce@wayneenterprises.com", donation.Email)
+	assert.Equal(t, float32(25), donation.Amt)
+	assert.Equal(t, float32(-1.03), donation.FeeAmt)
+	assert.Equal(t, float32(23.97), donation.NetAmt)
+	assert.Equal(t, "USD", donation.CurrencyCode)
+	assert.Equal(t, time.Date(2020, time.March, 2, 10, 15, 0, 0, time.UTC), donation.Timestamp)
+	assert.True(t, donation.IsDonation())
+
+	subscription := txns[1]
+	assert.Equal(t, "Recurring Payment", subscription.Type)
+	assert.Equal(t, "Pending", subscription.Status)
+	assert.Equal(t, "Selina Kyle", subscription.Name)
+	assert.Equal(t, time.Date(2020, time.March, 5, 15, 0, 0, 0, time.UTC), subscription.Timestamp)
+	// Pending payments do not count until they complete
+	assert.False(t, subscription.IsSubscription())
+	assert.True(t, subscription.IsPending())
+}
+
+func TestRestClientCachesAccessToken(t *testing.T) {
+	tokenCalls, searchCalls := 0, 0
+	ts := newRestServer(t, &tokenCalls, &searchCalls)
+	defer ts.Close()
+
+	client := NewRestClient(testRestConfig(ts.URL, "secret"))
+	_, err := client.GetTransactions(context.Background(), "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+	assert.NoError(t, err)
+	_, err = client.GetTransactions(context.Background(), "2020-04-01T00:00:00Z", GetEndDate(2020, 4))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, tokenCalls)
+	assert.Equal(t, 4, searchCalls)
+}
+
+func TestRestClientSplitsLongRanges(t *testing.T) {
+	tokenCalls, searchCalls := 0, 0
+	ts := newRestServer(t, &tokenCalls, &searchCalls)
+	defer ts.Close()
+
+	client := NewRestClient(testRestConfig(ts.URL, "secret"))
+	txns, err := client.GetTransactions(context.Background(), "2020-01-01T00:00:00Z", GetEndDate(2020, 3))
+	assert.NoError(t, err)
+
+	// Three windows of two pages each, with the duplicates merged
+	assert.Equal(t, 6, searchCalls)
+	assert.Equal(t, 2, len(txns))
+}
+
+func TestEventCodeType(t *testing.T) {
+	assert.Equal(t, "Donation", EventCodeType("T0013"))
+	assert.Equal(t, "Recurring Payment", EventCodeType("T0002"))
+	assert.Equal(t, "Payment", EventCodeType("T0006"))
+	assert.Equal(t, "Refund", EventCodeType("T1107"))
+	assert.Equal(t, "Withdrawal", EventCodeType("T0403"))
+	assert.Equal(t, "T9900", EventCodeType("T9900"))
+}
+
+func TestRestClientReturnsAuthErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": "invalid_client", "error_description": "Client Authentication failed"}`)
+	}))
+	defer ts.Close()
+
+	client := NewRestClient(testRestConfig(ts.URL, "wrong"))
+	_, err := client.GetTransactions(context.Background(), "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+
+	assert.True(t, IsAuthError(err))
+	assert.Contains(t, err.Error(), "Client Authentication failed")
+}
+
+func TestRestClientGetsNewTokenWhenRevoked(t *testing.T) {
+	tokenCalls, searchCalls := 0, 0
+	revoked := map[string]bool{}
+	mux := http.NewServeMux()
+	mux.HandleFunc(restTokenPath, func(w http.ResponseWriter, r *http.Request) {
+		tokenCalls++
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 32400}`, tokenCalls)
+	})
+	mux.HandleFunc(restTransactionsPath, func(w http.ResponseWriter, r *http.Request) {
+		searchCalls++
+		if revoked[r.Header.Get("Authorization")] {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": "invalid_token", "error_description": "Token signature verification failed"}`)
+			return
+		}
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, restPage2)
+		} else {
+			// The token stops working part way through
+			revoked["Bearer token-1"] = true
+			fmt.Fprint(w, restPage1)
+		}
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := NewRestClient(testRestConfig(ts.URL, "secret"))
+	txns, err := client.GetTransactions(context.Background(), "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(txns))
+	assert.Equal(t, 2, tokenCalls)
+	assert.Equal(t, 3, searchCalls)
+
+	// A new token which is turned down too is only tried once
+	revoked["Bearer token-2"] = true
+	revoked["Bearer token-3"] = true
+	_, err = client.GetTransactions(context.Background(), "2020-03-01T00:00:00Z", GetEndDate(2020, 3))
+	assert.True(t, IsAuthError(err))
+	assert.Equal(t, 3, tokenCalls)
+	assert.Equal(t, 5, searchCalls)
+}
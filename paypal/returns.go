@@ -0,0 +1,107 @@
+package paypal
+
+import "strings"
+
+// LinkReturns sets the ParentTransactionID of any refunds, reversals and
+// chargebacks in the transactions which do not have one yet. The parent is
+// only known from the transaction details, so otherwise the latest earlier
+// donation from the same payer to the same account in the same currency which
+// still has enough left to return is used, preferring one with the exact
+// amount. Payments which do not count, like those which were reversed, can be
+// the parent too. The returns which could not be linked are returned.
+func (p Transactions) LinkReturns() Transactions {
+	// How much of each donation is left after the returns already linked
+	left := map[string]float32{}
+	for _, t := range p {
+		if t.isPayment() {
+			left[t.TransactionID] += t.Amt
+		}
+	}
+	for _, t := range p {
+		if t.IsReturn() && t.ParentTransactionID != "" {
+			left[t.ParentTransactionID] += t.Amt
+		}
+	}
+
+	unlinked := Transactions{}
+	for _, t := range p {
+		if !t.IsReturn() || t.ParentTransactionID != "" {
+			continue
+		}
+
+		var parent *Transaction
+		for _, candidate := range p {
+			if !candidate.isPayment() ||
+				!strings.EqualFold(candidate.Email, t.Email) ||
+				candidate.CurrencyCode != t.CurrencyCode ||
+				candidate.Account != t.Account ||
+				candidate.Timestamp.After(t.Timestamp) ||
+				left[candidate.TransactionID] < -t.Amt {
+				continue
+			}
+			exact := candidate.Amt == -t.Amt
+			if parent == nil || exact && parent.Amt != -t.Amt ||
+				exact == (parent.Amt == -t.Amt) && candidate.Timestamp.After(parent.Timestamp) {
+				parent = candidate
+			}
+		}
+
+		if parent == nil {
+			unlinked = append(unlinked, t)
+			continue
+		}
+		t.ParentTransactionID = parent.TransactionID
+		left[parent.TransactionID] += t.Amt
+	}
+
+	return unlinked
+}
+
+// Parent returns the transaction a return was linked to by LinkReturns, if it
+// is in the transactions.
+func (p Transactions) Parent(t *Transaction) *Transaction {
+	if t.ParentTransactionID == "" {
+		return nil
+	}
+
+	for _, candidate := range p {
+		if candidate.TransactionID == t.ParentTransactionID {
+			return candidate
+		}
+	}
+
+	return nil
+}
+
+// isPayment is true for donations, subscription payments and sales whatever
+// their status.
+func (p *Transaction) isPayment() bool {
+	if p.Amt <= 0 {
+		return false
+	}
+	switch p.Category() {
+	case CategoryDonation, CategorySubscription, CategorySale:
+		return true
+	}
+
+	return false
+}
+
+// WithoutVoided splits off the returns of payments which never counted, like
+// the reversal of a payment whose status is Reversed or the refund of a sale,
+// since the payment is not in the totals either. Their parents are looked up
+// in all, which LinkReturns should have been called on.
+func (p Transactions) WithoutVoided(all Transactions) (Transactions, Transactions) {
+	kept := make(Transactions, 0, len(p))
+	voided := Transactions{}
+
+	for _, t := range p {
+		if parent := all.Parent(t); t.IsReturn() && parent != nil && !(parent.IsDonation() || parent.IsSubscription()) {
+			voided = append(voided, t)
+		} else {
+			kept = append(kept, t)
+		}
+	}
+
+	return kept, voided
+}
@@ -0,0 +1,103 @@
+package paypal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// LinkReturns
+//==============================================================================
+
+func TestLinkReturns(t *testing.T) {
+	txns := Transactions{
+		{Timestamp: day(time.January, 2), Type: "Donation", TransactionID: "1A", Email: "bruce@wayneenterprises.com",
+			Amt: 50, CurrencyCode: "USD"},
+		{Timestamp: day(time.January, 3), Type: "Donation", TransactionID: "1B", Email: "bruce@wayneenterprises.com",
+			Amt: 20, CurrencyCode: "USD"},
+		{Timestamp: day(time.January, 4), Type: "Donation", TransactionID: "1C", Email: "bruce@wayneenterprises.com",
+			Amt: 20, CurrencyCode: "EUR"},
+		{Timestamp: day(time.January, 5), Type: "Refund", TransactionID: "R1", Email: "bruce@wayneenterprises.com",
+			Amt: -50, CurrencyCode: "USD"},
+		{Timestamp: day(time.January, 6), Type: "Chargeback", TransactionID: "R2", Email: "bruce@wayneenterprises.com",
+			Amt: -10, CurrencyCode: "USD"},
+		{Timestamp: day(time.January, 7), Type: "Reversal", TransactionID: "R3", Email: "bruce@wayneenterprises.com",
+			Amt: -15, CurrencyCode: "USD"},
+		{Timestamp: day(time.January, 8), Type: "Refund", TransactionID: "R4", Email: "cat@woman.com",
+			Amt: -5, CurrencyCode: "USD", ParentTransactionID: "OLD"},
+		{Timestamp: day(time.January, 9), Type: "Refund", TransactionID: "R5", Email: "clarkkent@gmail.com",
+			Amt: -5, CurrencyCode: "USD"},
+	}
+
+	unlinked := txns.LinkReturns()
+
+	// The exact amount is preferred
+	assert.Equal(t, "1A", txns[3].ParentTransactionID)
+	// Then the latest with enough left
+	assert.Equal(t, "1B", txns[4].ParentTransactionID)
+	// Nothing has 15 USD left
+	assert.Equal(t, "", txns[5].ParentTransactionID)
+	// Known parents are kept
+	assert.Equal(t, "OLD", txns[6].ParentTransactionID)
+	assert.Equal(t, Transactions{txns[5], txns[7]}, unlinked)
+
+	assert.Equal(t, txns[0], txns.Parent(txns[3]))
+	assert.Nil(t, txns.Parent(txns[6]))
+}
+
+//==============================================================================
+// Summarize
+//==============================================================================
+
+func TestSummarizeWithReturns(t *testing.T) {
+	txns := Transactions{
+		{Timestamp: day(time.January, 2), Type: "Donation", Amt: 50, FeeAmt: -1.75, CurrencyCode: "USD"},
+		{Timestamp: day(time.January, 3), Type: "Recurring Payment", Amt: 10, FeeAmt: -0.59, CurrencyCode: "EUR"},
+		{Timestamp: day(time.February, 5), Type: "Refund", Amt: -50, FeeAmt: 1.45, CurrencyCode: "USD"},
+	}
+
+	donations, other := txns.FilterDonations()
+	assert.Equal(t, 3, len(donations))
+	assert.Equal(t, 0, len(other))
+
+	sums := donations.Summarize()
+	assert.Equal(t, 0, sums[time.January].ReturnedCount)
+	assert.Equal(t, 1, sums[time.February].ReturnedCount)
+	assert.Equal(t, float32(-50), sums[time.February].ReturnedAmt["USD"])
+
+	total := sums.Total()
+	assert.Equal(t, 1, total.OneTimeCount)
+	assert.Equal(t, float32(50), total.OneTimeAmt["USD"])
+	assert.Equal(t, float32(0), total.GrossTotal()["USD"])
+	assert.Equal(t, float32(10), total.GrossTotal()["EUR"])
+	assert.InDelta(t, -0.30, total.NetTotal()["USD"], 0.001)
+}
+
+//==============================================================================
+// WithoutVoided
+//==============================================================================
+
+func TestWithoutVoided(t *testing.T) {
+	txns := Transactions{
+		{Timestamp: day(time.January, 2), Type: "Donation", TransactionID: "1A", Email: "bruce@wayneenterprises.com",
+			Amt: 50, CurrencyCode: "USD", Status: "Reversed"},
+		{Timestamp: day(time.January, 3), Type: "Donation", TransactionID: "1B", Email: "bruce@wayneenterprises.com",
+			Amt: 20, CurrencyCode: "USD", Status: "Refunded"},
+		{Timestamp: day(time.January, 5), Type: "Reversal", TransactionID: "R1", Email: "bruce@wayneenterprises.com",
+			Amt: -50, CurrencyCode: "USD", Status: "Completed"},
+		{Timestamp: day(time.January, 6), Type: "Refund", TransactionID: "R2", Email: "bruce@wayneenterprises.com",
+			Amt: -20, CurrencyCode: "USD", Status: "Completed"},
+	}
+	txns.LinkReturns()
+	assert.Equal(t, "1A", txns[2].ParentTransactionID)
+
+	kept, voided := txns.WithoutVoided(txns)
+	assert.Equal(t, 3, len(kept))
+	assert.Equal(t, Transactions{txns[2]}, voided)
+
+	// Only the refunded donation and its refund count
+	donations, _ := kept.FilterDonations()
+	assert.Equal(t, float32(0), donations.Summarize().Total().GrossTotal()["USD"])
+}
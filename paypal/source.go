@@ -0,0 +1,96 @@
+package paypal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leavengood/donation_tracker/util"
+)
+
+const (
+	// APINvp selects the legacy NVP TransactionSearch backend
+	APINvp = "nvp"
+	// APIRest selects the REST Reporting API backend
+	APIRest = "rest"
+)
+
+// TransactionSource is a backend which can get PayPal transactions for a range
+// of dates.
+type TransactionSource interface {
+	// GetTransactions gets all transactions between the two dates, which
+	// should be in PayPalDateFormat. An empty end date means up to now. The
+	// transactions are sorted by date.
+	GetTransactions(ctx context.Context, startDate, endDate string) (Transactions, error)
+}
+
+// NewTransactionSource returns the backend selected by the API in the config,
+// defaulting to the NVP API.
+func NewTransactionSource(config *Config) TransactionSource {
+	if config.API == APIRest {
+		return NewRestClient(config)
+	}
+
+	return NewClient(config)
+}
+
+// SourceAPI returns which API the source uses, or an empty string if it
+// cannot tell.
+func SourceAPI(src TransactionSource) string {
+	if s, ok := src.(interface{ API() string }); ok {
+		return s.API()
+	}
+
+	return ""
+}
+
+// GetStartDate returns the first second of the given month, in the
+// organization's time zone, in PayPalDateFormat.
+func GetStartDate(year, month int) string {
+	return util.MonthStart(year, time.Month(month)).UTC().Format(PayPalDateFormat)
+}
+
+// GetEndDate returns the last second of the given month, in the
+// organization's time zone, in PayPalDateFormat.
+func GetEndDate(year, month int) string {
+	return util.MonthEnd(year, time.Month(month)).UTC().Format(PayPalDateFormat)
+}
+
+// parseDateRange parses the dates given to GetTransactions.
+func parseDateRange(startDate, endDate string) (time.Time, time.Time, error) {
+	start, err := time.Parse(PayPalDateFormat, startDate)
+	if err != nil {
+		return start, start, fmt.Errorf("invalid start date: %w", err)
+	}
+
+	end := now().UTC()
+	if endDate != "" {
+		end, err = time.Parse(PayPalDateFormat, endDate)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid end date: %w", err)
+		}
+	}
+
+	return start, end, nil
+}
+
+// GetTransactionsForMonth gets all the transactions for the given month.
+func GetTransactionsForMonth(ctx context.Context, src TransactionSource, year, month int) (Transactions, error) {
+	return src.GetTransactions(ctx, GetStartDate(year, month), GetEndDate(year, month))
+}
+
+// GetAndSaveMonth gets all the transactions for the given month and saves them
+// with the file manager, along with the window they cover. Nothing is saved if
+// there is an error getting them.
+func GetAndSaveMonth(ctx context.Context, src TransactionSource, year, month int, fm *FileManager) error {
+	monthStr := util.Colorize(util.Green, fmt.Sprintf("%s %d", time.Month(month), year))
+	fmt.Fprintf(output(ctx), "Fetching PayPal transactions for %s...", monthStr)
+	txns, err := GetTransactionsForMonth(ctx, src, year, month)
+	if err != nil {
+		fmt.Fprintln(output(ctx), "failed.")
+		return fmt.Errorf("could not get transactions for %s %d: %w", time.Month(month), year, err)
+	}
+	fmt.Fprintf(output(ctx), "there are %d transactions, saving them.\n", len(txns))
+	start := util.MonthStart(year, time.Month(month))
+	return fm.SaveMonth(month, txns, NewCoverage(year, month, start, monthEndOrNow(year, month), SourceAPI(src)))
+}
@@ -0,0 +1,170 @@
+package paypal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/leavengood/donation_tracker/util"
+)
+
+const (
+	// StorageJSON keeps each month in its own JSON file in the data directory
+	StorageJSON = "json"
+	// StorageBolt keeps everything in one embedded database file
+	StorageBolt = "bolt"
+)
+
+// Storage is where the PayPal transactions of each account are saved, one
+// month at a time. The account without a name is the default account.
+type Storage interface {
+	// LoadYear returns the saved months of the year, which may be empty
+	// months.
+	LoadYear(account string, year int) (map[int]*SavedMonth, error)
+	// SaveMonth replaces what is saved for the month.
+	SaveMonth(account string, year, month int, saved *SavedMonth) error
+	// Years returns the years with any saved months, in order.
+	Years(account string) ([]int, error)
+
+	// FindTransaction returns the transaction with the ID, or nil if there
+	// is none.
+	FindTransaction(account, id string) (*Transaction, error)
+	// FindByEmail returns the transactions with the email, ignoring case,
+	// sorted by date.
+	FindByEmail(account, email string) (Transactions, error)
+	// FindBetween returns the transactions from start until before end,
+	// sorted by date.
+	FindBetween(account string, start, end time.Time) (Transactions, error)
+
+	Close() error
+}
+
+// storage is where file managers load and save transactions
+var storage Storage = NewJSONStorage(util.DataDir())
+
+// SetStorage sets where transactions are loaded from and saved to.
+func SetStorage(s Storage) {
+	storage = s
+}
+
+// SavedYears returns the years with any saved months for the account, in
+// order.
+func SavedYears(account string) ([]int, error) {
+	return storage.Years(account)
+}
+
+// OpenStorage opens the kind of storage in the data directory, defaulting to
+// JSON files.
+func OpenStorage(kind string) (Storage, error) {
+	switch kind {
+	case "", StorageJSON:
+		return NewJSONStorage(util.DataDir()), nil
+	case StorageBolt:
+		return OpenBoltStorage(boltFileName(util.DataDir()))
+	}
+
+	return nil, unknownStorage(kind)
+}
+
+// OpenStorageReadOnly opens the kind of storage in the data directory like
+// OpenStorage, for a command which does not save anything.
+func OpenStorageReadOnly(kind string) (Storage, error) {
+	switch kind {
+	case "", StorageJSON:
+		return NewJSONStorage(util.DataDir()), nil
+	case StorageBolt:
+		return OpenBoltStorageReadOnly(boltFileName(util.DataDir()))
+	}
+
+	return nil, unknownStorage(kind)
+}
+
+func unknownStorage(kind string) error {
+	return fmt.Errorf("unknown storage %q, it should be %q or %q", kind, StorageJSON, StorageBolt)
+}
+
+// JSONStorage saves each month of each account in its own JSON file, in the
+// directory of the account.
+type JSONStorage struct {
+	Dir string
+}
+
+// NewJSONStorage returns the JSON storage in the directory.
+func NewJSONStorage(dir string) *JSONStorage {
+	return &JSONStorage{Dir: dir}
+}
+
+func (s *JSONStorage) accountDir(account string) string {
+	if account == "" {
+		return s.Dir
+	}
+
+	return filepath.Join(s.Dir, account)
+}
+
+func (s *JSONStorage) LoadYear(account string, year int) (map[int]*SavedMonth, error) {
+	return loadPayPalFiles(s.accountDir(account), year)
+}
+
+func (s *JSONStorage) SaveMonth(account string, year, month int, saved *SavedMonth) error {
+	return util.SaveMonthFile(s.accountDir(account), payPalFilePrefix, year, month, saved)
+}
+
+func (s *JSONStorage) Years(account string) ([]int, error) {
+	return util.MonthFileYears(s.accountDir(account), payPalFilePrefix)
+}
+
+// all returns every saved transaction of the account which matches, sorted by
+// date. The JSON files have no indexes, so every year is read.
+func (s *JSONStorage) all(account string, matches func(t *Transaction) bool) (Transactions, error) {
+	years, err := s.Years(account)
+	if err != nil {
+		return nil, err
+	}
+
+	result := Transactions{}
+	for _, year := range years {
+		months, err := s.LoadYear(account, year)
+		if err != nil {
+			return nil, err
+		}
+		for _, saved := range months {
+			for _, t := range saved.Transactions {
+				if matches(t) {
+					result = append(result, t)
+				}
+			}
+		}
+	}
+	result.Sort()
+
+	return result, nil
+}
+
+func (s *JSONStorage) FindTransaction(account, id string) (*Transaction, error) {
+	txns, err := s.all(account, func(t *Transaction) bool {
+		return t.TransactionID == id
+	})
+	if err != nil || len(txns) == 0 {
+		return nil, err
+	}
+
+	return txns[len(txns)-1], nil
+}
+
+func (s *JSONStorage) FindByEmail(account, email string) (Transactions, error) {
+	return s.all(account, func(t *Transaction) bool {
+		return strings.EqualFold(t.Email, email)
+	})
+}
+
+func (s *JSONStorage) FindBetween(account string, start, end time.Time) (Transactions, error) {
+	return s.all(account, func(t *Transaction) bool {
+		return !t.Timestamp.Before(start) && t.Timestamp.Before(end)
+	})
+}
+
+func (s *JSONStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,164 @@
+package paypal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// Storage
+//==============================================================================
+
+func saved(txns Transactions) *SavedMonth {
+	return &SavedMonth{Transactions: txns}
+}
+
+// eachStorage runs the test with a new storage of each kind.
+func eachStorage(t *testing.T, test func(t *testing.T, s Storage)) {
+	t.Run("json", func(t *testing.T) {
+		test(t, NewJSONStorage(t.TempDir()))
+	})
+	t.Run("bolt", func(t *testing.T) {
+		s, err := OpenBoltStorage(filepath.Join(t.TempDir(), "paypal.db"))
+		assert.Nil(t, err)
+		defer s.Close()
+		test(t, s)
+	})
+}
+
+func saveStorageTxns(t *testing.T, s Storage) {
+	assert.Nil(t, s.SaveMonth("", 2020, 1, saved(Transactions{
+		{Timestamp: day(time.January, 2), TransactionID: "1A", Email: "ann@example.com", Amt: 5},
+		{Timestamp: day(time.January, 9), TransactionID: "1B", Email: "bob@example.com", Amt: 10},
+	})))
+	assert.Nil(t, s.SaveMonth("", 2020, 2, saved(Transactions{})))
+	assert.Nil(t, s.SaveMonth("", 2019, 12, saved(Transactions{
+		{Timestamp: time.Date(2019, time.December, 30, 0, 0, 0, 0, time.UTC), TransactionID: "0A",
+			Email: "Ann@Example.com", Amt: 20},
+	})))
+	assert.Nil(t, s.SaveMonth("europe", 2020, 1, saved(Transactions{
+		{Timestamp: day(time.January, 3), TransactionID: "EA", Email: "ann@example.com", Amt: 7},
+	})))
+}
+
+func TestStorageLoadAndSave(t *testing.T) {
+	eachStorage(t, func(t *testing.T, s Storage) {
+		saveStorageTxns(t, s)
+
+		months, err := s.LoadYear("", 2020)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(months))
+		assert.Equal(t, 2, len(months[1].Transactions))
+		assert.Equal(t, "1B", months[1].Transactions[1].TransactionID)
+		assert.Equal(t, float32(10), months[1].Transactions[1].Amt)
+		// Empty months are still saved
+		assert.NotNil(t, months[2])
+		assert.Empty(t, months[2].Transactions)
+
+		months, err = s.LoadYear("europe", 2020)
+		assert.Nil(t, err)
+		assert.Equal(t, "EA", months[1].Transactions[0].TransactionID)
+
+		months, err = s.LoadYear("missing", 2020)
+		assert.Nil(t, err)
+		assert.Empty(t, months)
+
+		years, err := s.Years("")
+		assert.Nil(t, err)
+		assert.Equal(t, []int{2019, 2020}, years)
+		years, err = s.Years("missing")
+		assert.Nil(t, err)
+		assert.Empty(t, years)
+
+		// Saving a month again replaces it
+		assert.Nil(t, s.SaveMonth("", 2020, 1, saved(Transactions{
+			{Timestamp: day(time.January, 2), TransactionID: "1A", Email: "ann@example.com", Amt: 5, Status: "Refunded"},
+		})))
+		months, err = s.LoadYear("", 2020)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(months[1].Transactions))
+		assert.Equal(t, "Refunded", months[1].Transactions[0].Status)
+
+		// Along with the coverage
+		coverage := &Coverage{Start: day(time.March, 1), End: day(time.March, 20),
+			FetchedAt: day(time.March, 20), Source: APIRest}
+		assert.Nil(t, s.SaveMonth("", 2020, 3, &SavedMonth{Transactions: Transactions{}, Coverage: coverage}))
+		months, err = s.LoadYear("", 2020)
+		assert.Nil(t, err)
+		assert.Equal(t, coverage, months[3].Coverage)
+		assert.Nil(t, months[1].Coverage)
+	})
+}
+
+func TestStorageFind(t *testing.T) {
+	eachStorage(t, func(t *testing.T, s Storage) {
+		saveStorageTxns(t, s)
+
+		found, err := s.FindTransaction("", "1B")
+		assert.Nil(t, err)
+		assert.Equal(t, "bob@example.com", found.Email)
+		found, err = s.FindTransaction("", "EA")
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+
+		// Across years and ignoring case
+		txns, err := s.FindByEmail("", "ann@example.com")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(txns))
+		assert.Equal(t, "0A", txns[0].TransactionID)
+		assert.Equal(t, "1A", txns[1].TransactionID)
+
+		txns, err = s.FindBetween("", day(time.January, 1), day(time.January, 9))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(txns))
+		assert.Equal(t, "1A", txns[0].TransactionID)
+
+		// Replaced transactions are not found anymore
+		assert.Nil(t, s.SaveMonth("", 2020, 1, saved(Transactions{
+			{Timestamp: day(time.January, 9), TransactionID: "1B", Email: "bob@example.com", Amt: 10},
+		})))
+		found, err = s.FindTransaction("", "1A")
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+		txns, err = s.FindByEmail("", "ann@example.com")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(txns))
+		txns, err = s.FindBetween("", day(time.January, 1), day(time.February, 1))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(txns))
+		assert.Equal(t, "1B", txns[0].TransactionID)
+	})
+}
+
+func TestOpenBoltStorageInUse(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "paypal.db")
+	s, err := OpenBoltStorage(filename)
+	assert.Nil(t, err)
+	defer s.Close()
+
+	_, err = OpenBoltStorage(filename)
+	assert.Contains(t, err.Error(), "it is being used by another command")
+}
+
+func TestOpenBoltStorageReadOnly(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "paypal.db")
+	_, err := OpenBoltStorageReadOnly(filename)
+	assert.Contains(t, err.Error(), "does not exist yet")
+
+	s, err := OpenBoltStorage(filename)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Close())
+
+	// Readers can open it together, but not with a writer
+	first, err := OpenBoltStorageReadOnly(filename)
+	assert.Nil(t, err)
+	defer first.Close()
+	second, err := OpenBoltStorageReadOnly(filename)
+	assert.Nil(t, err)
+	defer second.Close()
+	_, err = OpenBoltStorage(filename)
+	assert.Contains(t, err.Error(), "it is being used by another command")
+}
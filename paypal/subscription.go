@@ -0,0 +1,366 @@
+package paypal
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The kinds of events in the life of a subscription
+const (
+	SubscriptionCreated     = "Created"
+	SubscriptionPayment     = "Payment"
+	SubscriptionSkipped     = "Skipped"
+	SubscriptionSuspended   = "Suspended"
+	SubscriptionReactivated = "Reactivated"
+	SubscriptionCancelled   = "Cancelled"
+)
+
+// The statuses a subscription can have
+const (
+	StatusActive    = "Active"
+	StatusSuspended = "Suspended"
+	StatusCancelled = "Cancelled"
+	// A subscription known only from its payments, which have stopped
+	StatusLapsed = "Lapsed"
+)
+
+// A subscription known only from its payments is taken to have stopped when
+// there has been no payment for this long after the usual time between them.
+const lapseGrace = 31 * 24 * time.Hour
+
+// billingPeriods are the usual times between subscription payments, in days,
+// with how many payments of each make up a month
+var billingPeriods = []struct {
+	days     float64
+	perMonth float32
+}{
+	{7, 52.0 / 12},
+	{365.25 / 24, 2},
+	{365.25 / 12, 1},
+	{365.25 / 4, 1.0 / 3},
+	{365.25 / 2, 1.0 / 6},
+	{365.25, 1.0 / 12},
+}
+
+// profileEventKinds maps the status of profile events to the kind of event.
+// PayPal uses both spellings of cancelled.
+var profileEventKinds = map[string]string{
+	"created":     SubscriptionCreated,
+	"skipped":     SubscriptionSkipped,
+	"suspended":   SubscriptionSuspended,
+	"reactivated": SubscriptionReactivated,
+	"canceled":    SubscriptionCancelled,
+	"cancelled":   SubscriptionCancelled,
+	"expired":     SubscriptionCancelled,
+}
+
+// SubscriptionEvent is something which happened to a subscription.
+type SubscriptionEvent struct {
+	Timestamp     time.Time
+	Kind          string
+	Amt           float32
+	CurrencyCode  string
+	TransactionID string
+}
+
+// Subscription is a recurring donation, keyed by the PayPal recurring
+// payments profile ID.
+type Subscription struct {
+	ProfileID    string
+	Name         string
+	Email        string
+	Amt          float32
+	CurrencyCode string
+	Start        time.Time
+	LastPayment  time.Time
+	Payments     int
+	Status       string
+	Events       []*SubscriptionEvent
+}
+
+// StatusAt returns the status of the subscription at the given time, or an
+// empty string if it had not started yet. A subscription known only from its
+// payments has lapsed once they stop, since it never gets cancelled.
+func (s *Subscription) StatusAt(t time.Time) string {
+	status := ""
+
+	for _, e := range s.Events {
+		if e.Timestamp.After(t) {
+			break
+		}
+		status = nextStatus(status, e.Kind)
+	}
+	if lapsedAt := s.LapsedAt(); status == StatusActive && !lapsedAt.IsZero() && !t.Before(lapsedAt) {
+		return StatusLapsed
+	}
+
+	return status
+}
+
+// onlyPayments is true when nothing but payments is known of the
+// subscription, like when its profile events were never fetched.
+func (s *Subscription) onlyPayments() bool {
+	for _, e := range s.Events {
+		if e.Kind != SubscriptionPayment {
+			return false
+		}
+	}
+
+	return len(s.Events) > 0
+}
+
+// LapsedAt returns when a subscription known only from its payments is taken
+// to have stopped: a month after the next payment was due, going by the time
+// between its last two payments, or monthly if there was only one. It is a
+// zero time for any other subscription.
+func (s *Subscription) LapsedAt() time.Time {
+	if !s.onlyPayments() {
+		return time.Time{}
+	}
+
+	interval := lapseGrace
+	if n := len(s.Events); n > 1 {
+		if gap := s.Events[n-1].Timestamp.Sub(s.Events[n-2].Timestamp); gap > interval {
+			interval = gap
+		}
+	}
+
+	return s.Events[len(s.Events)-1].Timestamp.Add(interval + lapseGrace)
+}
+
+// MonthlyAmt returns the latest payment of the subscription as a monthly
+// amount. The billing period is taken to be the usual one nearest to the
+// shortest time between its payments, so a skipped payment does not change
+// it, or monthly when there was only one payment.
+func (s *Subscription) MonthlyAmt() float32 {
+	shortest := 0.0
+	var last time.Time
+	for _, e := range s.Events {
+		if e.Kind != SubscriptionPayment {
+			continue
+		}
+		// Payments on the same day are not a billing period
+		if days := e.Timestamp.Sub(last).Hours() / 24; !last.IsZero() && days >= 1 &&
+			(shortest == 0 || days < shortest) {
+			shortest = days
+		}
+		last = e.Timestamp
+	}
+	if shortest == 0 {
+		return s.Amt
+	}
+
+	nearest := billingPeriods[0]
+	for _, period := range billingPeriods {
+		if math.Abs(math.Log(shortest/period.days)) < math.Abs(math.Log(shortest/nearest.days)) {
+			nearest = period
+		}
+	}
+
+	return s.Amt * nearest.perMonth
+}
+
+// CancelledAt returns when the subscription was last cancelled, or a zero
+// time if it never was.
+func (s *Subscription) CancelledAt() time.Time {
+	result := time.Time{}
+
+	for _, e := range s.Events {
+		if e.Kind == SubscriptionCancelled {
+			result = e.Timestamp
+		}
+	}
+
+	return result
+}
+
+func nextStatus(status, kind string) string {
+	switch kind {
+	case SubscriptionSuspended:
+		return StatusSuspended
+	case SubscriptionCancelled:
+		return StatusCancelled
+	case SubscriptionSkipped:
+		// A skipped payment does not change anything
+		if status != "" {
+			return status
+		}
+	}
+
+	return StatusActive
+}
+
+// cancelledBefore is true if the subscription was cancelled before the time
+func (s *Subscription) cancelledBefore(t time.Time) bool {
+	cancelledAt := s.CancelledAt()
+	return !cancelledAt.IsZero() && cancelledAt.Before(t)
+}
+
+// replay sorts the events and sets everything known from them
+func (s *Subscription) replay() {
+	sort.SliceStable(s.Events, func(i, j int) bool {
+		return s.Events[i].Timestamp.Before(s.Events[j].Timestamp)
+	})
+
+	s.Status = ""
+	s.Payments = 0
+	s.LastPayment = time.Time{}
+	s.Amt = 0
+	s.CurrencyCode = ""
+	for _, e := range s.Events {
+		s.Status = nextStatus(s.Status, e.Kind)
+		if e.Kind == SubscriptionPayment {
+			s.Payments++
+			s.LastPayment = e.Timestamp
+			s.Amt = e.Amt
+			s.CurrencyCode = e.CurrencyCode
+		}
+	}
+	if len(s.Events) > 0 {
+		s.Start = s.Events[0].Timestamp
+	}
+}
+
+// asOf returns a copy of the subscription as it was at the time, leaving out
+// anything which happened after it.
+func (s *Subscription) asOf(t time.Time) *Subscription {
+	result := *s
+	result.Events = nil
+	for _, e := range s.Events {
+		if !e.Timestamp.After(t) {
+			result.Events = append(result.Events, e)
+		}
+	}
+	result.replay()
+
+	return &result
+}
+
+type Subscriptions []*Subscription
+
+// TrackSubscriptions builds the subscriptions from the profile events and
+// subscription payments in the transactions.
+//
+// PayPal only gives the profile ID of a payment in its details, so payments
+// without one are matched to the only subscription of the payer which was
+// not cancelled by then. If there is none, or several, the payment gets a
+// subscription keyed by the payer's email.
+func TrackSubscriptions(txns Transactions) Subscriptions {
+	byProfile := map[string]*Subscription{}
+	byEmail := map[string][]*Subscription{}
+	result := Subscriptions{}
+
+	get := func(profileID string, t *Transaction) *Subscription {
+		s, found := byProfile[profileID]
+		if !found {
+			s = &Subscription{ProfileID: profileID}
+			byProfile[profileID] = s
+			email := strings.ToLower(t.Email)
+			byEmail[email] = append(byEmail[email], s)
+			result = append(result, s)
+		}
+		if t.Name != "" {
+			s.Name = t.Name
+		}
+		if t.Email != "" {
+			s.Email = t.Email
+		}
+		return s
+	}
+
+	// Profile events and payments with a known profile first, so the other
+	// payments can be matched to them
+	unmatched := Transactions{}
+	for _, t := range txns {
+		switch {
+		case t.IsProfileEvent():
+			kind, found := profileEventKinds[strings.ToLower(t.Status)]
+			if t.Type == "Subscription Cancellation" {
+				kind, found = SubscriptionCancelled, true
+			}
+			if !found {
+				continue
+			}
+			sub := get(t.TransactionID, t)
+			sub.Events = append(sub.Events, &SubscriptionEvent{
+				Timestamp:     t.Timestamp,
+				Kind:          kind,
+				TransactionID: t.TransactionID,
+			})
+
+		case t.IsSubscription():
+			if t.ProfileID == "" {
+				unmatched = append(unmatched, t)
+				continue
+			}
+			sub := get(t.ProfileID, t)
+			sub.Events = append(sub.Events, paymentEvent(t))
+		}
+	}
+
+	for _, t := range unmatched {
+		profileID := t.Email
+		candidates := Subscriptions{}
+		for _, sub := range byEmail[strings.ToLower(t.Email)] {
+			if sub.ProfileID != t.Email && !sub.cancelledBefore(t.Timestamp) {
+				candidates = append(candidates, sub)
+			}
+		}
+		if len(candidates) == 1 {
+			profileID = candidates[0].ProfileID
+		}
+		sub := get(profileID, t)
+		sub.Events = append(sub.Events, paymentEvent(t))
+	}
+
+	for _, sub := range result {
+		sub.replay()
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+
+	return result
+}
+
+func paymentEvent(t *Transaction) *SubscriptionEvent {
+	return &SubscriptionEvent{
+		Timestamp:     t.Timestamp,
+		Kind:          SubscriptionPayment,
+		Amt:           t.Amt,
+		CurrencyCode:  t.CurrencyCode,
+		TransactionID: t.TransactionID,
+	}
+}
+
+// During returns the subscriptions which were active at some point from start
+// to end, and those which were cancelled or lapsed then, ordered by their
+// start. Each is as it was at the end, so later payments are left out.
+func (s Subscriptions) During(start, end time.Time) (active Subscriptions, cancelled Subscriptions) {
+	for _, sub := range s {
+		if sub.Start.After(end) {
+			continue
+		}
+		sub = sub.asOf(end)
+
+		switch status := sub.StatusAt(end); {
+		case status == StatusCancelled && !sub.CancelledAt().Before(start),
+			status == StatusLapsed && !sub.LapsedAt().Before(start):
+			cancelled = append(cancelled, sub)
+		case status != StatusCancelled && status != StatusLapsed:
+			active = append(active, sub)
+		}
+	}
+
+	byStart := func(subs Subscriptions) {
+		sort.SliceStable(subs, func(i, j int) bool {
+			return subs[i].Start.Before(subs[j].Start)
+		})
+	}
+	byStart(active)
+	byStart(cancelled)
+
+	return active, cancelled
+}
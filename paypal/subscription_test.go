@@ -0,0 +1,206 @@
+package paypal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func day(month time.Month, d int) time.Time {
+	return time.Date(2020, month, d, 12, 0, 0, 0, time.UTC)
+}
+
+func subscriptionTxns() Transactions {
+	return Transactions{
+		{Timestamp: day(time.January, 5), Type: "Recurring Payment", TransactionID: "I-ONE", Status: "Created",
+			Name: "Bruce Wayne", Email: "bruce@wayneenterprises.com"},
+		{Timestamp: day(time.January, 5), Type: "Recurring Payment", TransactionID: "1A", Status: "Completed",
+			Email: "bruce@wayneenterprises.com", Amt: 10, FeeAmt: -0.59, CurrencyCode: "USD"},
+		{Timestamp: day(time.January, 20), Type: "Recurring Payment", TransactionID: "I-TWO", Status: "Created",
+			Name: "Selina Kyle", Email: "cat@woman.com"},
+		{Timestamp: day(time.January, 20), Type: "Recurring Payment", TransactionID: "2A", Status: "Completed",
+			Email: "cat@woman.com", Amt: 5, FeeAmt: -0.45, CurrencyCode: "EUR", ProfileID: "I-TWO"},
+		{Timestamp: day(time.February, 5), Type: "Recurring Payment", TransactionID: "I-ONE", Status: "Skipped",
+			Email: "bruce@wayneenterprises.com"},
+		{Timestamp: day(time.February, 20), Type: "Recurring Payment", TransactionID: "2B", Status: "Completed",
+			Email: "cat@woman.com", Amt: 5, FeeAmt: -0.45, CurrencyCode: "EUR", ProfileID: "I-TWO"},
+		{Timestamp: day(time.March, 5), Type: "Recurring Payment", TransactionID: "1B", Status: "Completed",
+			Email: "bruce@wayneenterprises.com", Amt: 20, FeeAmt: -0.88, CurrencyCode: "USD"},
+		{Timestamp: day(time.March, 10), Type: "Recurring Payment", TransactionID: "I-TWO", Status: "Canceled",
+			Email: "cat@woman.com"},
+		{Timestamp: day(time.March, 12), Type: "Donation", TransactionID: "3A", Status: "Completed",
+			Email: "clarkkent@gmail.com", Amt: 50, CurrencyCode: "USD"},
+	}
+}
+
+//==============================================================================
+// TrackSubscriptions
+//==============================================================================
+
+func TestTrackSubscriptions(t *testing.T) {
+	subs := TrackSubscriptions(subscriptionTxns())
+
+	assert.Equal(t, 2, len(subs))
+
+	bruce := subs[0]
+	assert.Equal(t, "I-ONE", bruce.ProfileID)
+	assert.Equal(t, "Bruce Wayne", bruce.Name)
+	assert.Equal(t, StatusActive, bruce.Status)
+	assert.Equal(t, 2, bruce.Payments)
+	assert.Equal(t, float32(20), bruce.Amt)
+	assert.Equal(t, "USD", bruce.CurrencyCode)
+	assert.Equal(t, day(time.January, 5), bruce.Start)
+	assert.Equal(t, day(time.March, 5), bruce.LastPayment)
+	assert.Equal(t, 4, len(bruce.Events))
+	assert.Equal(t, SubscriptionSkipped, bruce.Events[2].Kind)
+
+	selina := subs[1]
+	assert.Equal(t, "I-TWO", selina.ProfileID)
+	assert.Equal(t, StatusCancelled, selina.Status)
+	assert.Equal(t, 2, selina.Payments)
+	assert.Equal(t, "EUR", selina.CurrencyCode)
+	assert.Equal(t, day(time.March, 10), selina.CancelledAt())
+}
+
+func TestTrackSubscriptionsWithUnknownProfile(t *testing.T) {
+	subs := TrackSubscriptions(Transactions{
+		{Timestamp: day(time.January, 5), Type: "Recurring Payment", TransactionID: "1A", Status: "Completed",
+			Email: "diana@themyscira.gr", Amt: 10, CurrencyCode: "USD"},
+	})
+
+	assert.Equal(t, 1, len(subs))
+	assert.Equal(t, "diana@themyscira.gr", subs[0].ProfileID)
+	assert.Equal(t, StatusActive, subs[0].Status)
+}
+
+func TestSubscriptionsWithOnlyPaymentsLapse(t *testing.T) {
+	payment := func(ts time.Time, id string) *Transaction {
+		return &Transaction{Timestamp: ts, Type: "Recurring Payment", TransactionID: id, Status: "Completed",
+			Email: "diana@themyscira.gr", Amt: 10, CurrencyCode: "USD"}
+	}
+	monthly := TrackSubscriptions(Transactions{payment(day(time.January, 5), "1A"), payment(day(time.February, 5), "1B")})[0]
+
+	// Two months after the last payment there is still no cancellation
+	assert.Equal(t, StatusActive, monthly.StatusAt(day(time.March, 20)))
+	assert.Equal(t, StatusLapsed, monthly.StatusAt(day(time.April, 10)))
+	assert.Equal(t, day(time.February, 5).Add(62*24*time.Hour), monthly.LapsedAt())
+
+	active, cancelled := Subscriptions{monthly}.During(day(time.April, 1), day(time.April, 30))
+	assert.Empty(t, active)
+	assert.Equal(t, 1, len(cancelled))
+	active, cancelled = Subscriptions{monthly}.During(day(time.June, 1), day(time.June, 30))
+	assert.Empty(t, active)
+	assert.Empty(t, cancelled)
+
+	// A yearly one goes by the time between its payments
+	yearly := TrackSubscriptions(Transactions{payment(day(time.January, 5).AddDate(-1, 0, 0), "0A"),
+		payment(day(time.January, 5), "1A")})[0]
+	assert.Equal(t, StatusActive, yearly.StatusAt(day(time.December, 31)))
+
+	// Those with profile events only end when they are cancelled
+	assert.True(t, TrackSubscriptions(subscriptionTxns())[0].LapsedAt().IsZero())
+}
+
+func TestTrackSubscriptionsSuspendedAndReactivated(t *testing.T) {
+	txns := Transactions{
+		{Timestamp: day(time.January, 5), Type: "Recurring Payment", TransactionID: "I-ONE", Status: "Created"},
+		{Timestamp: day(time.February, 5), Type: "Recurring Payment", TransactionID: "I-ONE", Status: "Suspended"},
+		{Timestamp: day(time.March, 5), Type: "Recurring Payment", TransactionID: "I-ONE", Status: "Reactivated"},
+	}
+	sub := TrackSubscriptions(txns)[0]
+
+	assert.Equal(t, "", sub.StatusAt(day(time.January, 1)))
+	assert.Equal(t, StatusActive, sub.StatusAt(day(time.January, 31)))
+	assert.Equal(t, StatusSuspended, sub.StatusAt(day(time.February, 28)))
+	assert.Equal(t, StatusActive, sub.Status)
+}
+
+//==============================================================================
+// During
+//==============================================================================
+
+func TestSubscriptionsDuring(t *testing.T) {
+	subs := TrackSubscriptions(subscriptionTxns())
+
+	active, cancelled := subs.During(day(time.February, 1), day(time.February, 28))
+	assert.Equal(t, 2, len(active))
+	assert.Equal(t, 0, len(cancelled))
+
+	active, cancelled = subs.During(day(time.March, 1), day(time.March, 31))
+	assert.Equal(t, 1, len(active))
+	assert.Equal(t, "I-ONE", active[0].ProfileID)
+	assert.Equal(t, 1, len(cancelled))
+	assert.Equal(t, "I-TWO", cancelled[0].ProfileID)
+
+	active, cancelled = subs.During(day(time.April, 1), day(time.April, 30))
+	assert.Equal(t, 1, len(active))
+	assert.Equal(t, 0, len(cancelled))
+}
+
+func TestSubscriptionsDuringLeavesOutLaterEvents(t *testing.T) {
+	subs := TrackSubscriptions(subscriptionTxns())
+
+	// Bruce raised his payment in March and Selina cancelled then
+	active, cancelled := subs.During(day(time.February, 1), day(time.February, 28))
+	assert.Equal(t, 2, len(active))
+	assert.Empty(t, cancelled)
+	bruce := active[0]
+	assert.Equal(t, float32(10), bruce.Amt)
+	assert.Equal(t, 1, bruce.Payments)
+	assert.Equal(t, day(time.January, 5), bruce.LastPayment)
+	assert.Equal(t, StatusActive, active[1].Status)
+
+	// What was tracked is left as it was
+	assert.Equal(t, float32(20), subs[0].Amt)
+	assert.Equal(t, 2, subs[0].Payments)
+}
+
+func TestMonthlyAmt(t *testing.T) {
+	payments := func(amt float32, dates ...time.Time) *Subscription {
+		txns := Transactions{}
+		for _, date := range dates {
+			txns = append(txns, &Transaction{Timestamp: date, Type: "Recurring Payment", Status: "Completed",
+				Email: "bruce@wayneenterprises.com", Amt: amt, CurrencyCode: "USD"})
+		}
+		return TrackSubscriptions(txns)[0]
+	}
+
+	// Only one payment is taken to be monthly
+	assert.Equal(t, float32(10), payments(10, day(time.January, 5)).MonthlyAmt())
+	// A skipped month does not make it quarterly
+	assert.Equal(t, float32(10),
+		payments(10, day(time.January, 5), day(time.February, 5), day(time.April, 5)).MonthlyAmt())
+	assert.InDelta(t, 43.33,
+		payments(10, day(time.January, 5), day(time.January, 12), day(time.January, 19)).MonthlyAmt(), 0.01)
+	assert.Equal(t, float32(10), payments(120, day(time.January, 5), day(time.January, 5).AddDate(1, 0, 0)).MonthlyAmt())
+	assert.Equal(t, float32(10), payments(30, day(time.January, 5), day(time.April, 5)).MonthlyAmt())
+}
+
+//==============================================================================
+// Merge
+//==============================================================================
+
+func TestMergeKeepsProfileEvents(t *testing.T) {
+	txns := subscriptionTxns()
+	merged := txns[:4].Merge(txns)
+
+	assert.Equal(t, len(txns), len(merged))
+}
+
+func TestTrackSubscriptionsWithPaymentBeforeCreated(t *testing.T) {
+	// The first payment can have the same timestamp as the created event and
+	// so be sorted before it
+	txns := Transactions{
+		{Timestamp: day(time.January, 5), Type: "Recurring Payment", TransactionID: "1A", Status: "Completed",
+			Email: "diana@themyscira.gr", Amt: 10, CurrencyCode: "USD"},
+		{Timestamp: day(time.January, 5), Type: "Recurring Payment", TransactionID: "I-ONE", Status: "Created",
+			Email: "diana@themyscira.gr"},
+	}
+	subs := TrackSubscriptions(txns)
+
+	assert.Equal(t, 1, len(subs))
+	assert.Equal(t, "I-ONE", subs[0].ProfileID)
+	assert.Equal(t, 1, subs[0].Payments)
+	assert.Equal(t, SubscriptionCreated, subs[0].Events[0].Kind)
+}
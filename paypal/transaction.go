@@ -24,6 +24,26 @@ type Transaction struct {
 	FeeAmt        float32   `json:"fee_amt,omitempty"`
 	NetAmt        float32   `json:"net_amt,omitempty"`
 	CurrencyCode  string    `json:"currency_code,omitempty"`
+
+	// These are only known when the transaction details have been fetched
+	Note        string `json:"note,omitempty"`
+	CountryCode string `json:"country_code,omitempty"`
+	Custom      string `json:"custom,omitempty"`
+	InvoiceID   string `json:"invoice_id,omitempty"`
+	ItemName    string `json:"item_name,omitempty"`
+	Receiver    string `json:"receiver,omitempty"`
+
+	// The recurring payments profile of a subscription payment, when known
+	ProfileID string `json:"profile_id,omitempty"`
+	// The transaction a refund, reversal or chargeback returns money from
+	ParentTransactionID string `json:"parent_transaction_id,omitempty"`
+
+	// The name of the PayPal account, when there are several
+	Account string `json:"account,omitempty"`
+
+	// Whether this is a side of the conversion of a donation, which is only
+	// known once LinkConversions has paired it with the donation
+	convertsDonation bool
 }
 
 func NewTransaction(tran map[string]string) *Transaction {
@@ -53,21 +73,104 @@ func NewTransaction(tran map[string]string) *Transaction {
 	return result
 }
 
+// ApplyDetails sets the extra fields from a GetTransactionDetails response.
+func (p *Transaction) ApplyDetails(details NameValues) {
+	p.Note = details["NOTE"]
+	p.CountryCode = details["COUNTRYCODE"]
+	p.Custom = details["CUSTOM"]
+	p.InvoiceID = details["INVNUM"]
+	p.ItemName = details["L_NAME0"]
+	p.Receiver = details["RECEIVEREMAIL"]
+	if id := details["SUBSCRIPTIONID"]; id != "" {
+		p.ProfileID = id
+	}
+	if id := details["PARENTTRANSACTIONID"]; id != "" {
+		p.ParentTransactionID = id
+	}
+}
+
+// Statuses of a transaction which matter here
+const (
+	StatusCompleted = "Completed"
+	StatusPending   = "Pending"
+)
+
+// completedStatuses are the statuses of transactions whose money was
+// received, or given back for a return. A payment which was later refunded
+// still counts, since its refund is counted on its own. Transactions saved
+// without a status are assumed to be completed.
+var completedStatuses = map[string]bool{
+	"":                   true,
+	StatusCompleted:      true,
+	"Cleared":            true,
+	"Refunded":           true,
+	"Partially Refunded": true,
+}
+
+// IsCompleted is true when the money of the transaction counts, unlike those
+// which are pending, denied, reversed or unclaimed.
+func (p *Transaction) IsCompleted() bool {
+	return completedStatuses[p.Status]
+}
+
+func (p *Transaction) isSubscriptionPayment() bool {
+	return p.Amt > 0 && p.Category() == CategorySubscription
+}
+
+func (p *Transaction) isDonationPayment() bool {
+	return p.Amt > 0 && p.Category() == CategoryDonation
+}
+
 func (p *Transaction) IsSubscription() bool {
-	return p.Amt > 0 &&
-		(p.Type == "Payment" || p.Type == "Recurring Payment")
+	return p.isSubscriptionPayment() && p.IsCompleted()
 }
 
 func (p *Transaction) IsDonation() bool {
-	return p.Amt > 0 && p.Type == "Donation"
+	return p.isDonationPayment() && p.IsCompleted()
+}
+
+// IsPending is true for donations and subscription payments which are still
+// pending, so they do not count yet.
+func (p *Transaction) IsPending() bool {
+	return p.Status == StatusPending && (p.isDonationPayment() || p.isSubscriptionPayment())
+}
+
+// returnTypes are the transaction types which give money back to the payer
+var returnTypes = map[string]bool{
+	"Refund":     true,
+	"Reversal":   true,
+	"Chargeback": true,
+}
+
+// IsReturn is true for completed refunds, reversals and chargebacks of a
+// payment.
+func (p *Transaction) IsReturn() bool {
+	return p.Amt < 0 && returnTypes[p.Type] && p.IsCompleted()
+}
+
+// IsProfileEvent is true for the transactions PayPal lists when a recurring
+// payments profile changes, such as being created or cancelled. These have no
+// amount and use the profile ID as the transaction ID.
+func (p *Transaction) IsProfileEvent() bool {
+	return (p.Type == "Recurring Payment" && p.Amt == 0 && p.FeeAmt == 0) ||
+		p.Type == "Subscription Cancellation"
+}
+
+// Key identifies the transaction when merging. This is the transaction ID,
+// except for profile events which all share the profile ID.
+func (p *Transaction) Key() string {
+	if p.IsProfileEvent() {
+		return fmt.Sprintf("%s/%s/%s", p.TransactionID, p.Status, p.Timestamp.UTC().Format(PayPalDateFormat))
+	}
+
+	return p.TransactionID
 }
 
 func (p *Transaction) String() string {
 	tsStr := util.FormatDateTime(p.Timestamp)
 
 	// For subscription changes to display nicely
-	if (p.Type == "Recurring Payment" && p.Amt == 0 && p.FeeAmt == 0) ||
-		p.Type == "Subscription Cancellation" {
+	if p.IsProfileEvent() {
 		color := util.Red
 
 		if p.Status == "Created" {
@@ -85,11 +188,10 @@ func (p *Transaction) String() string {
 type Transactions []*Transaction
 
 func TransactionsFromNvp(nvp *NvpResult) Transactions {
-	result := make(Transactions, len(nvp.List))
+	result := make(Transactions, 0, len(nvp.List))
 
-	for i, item := range nvp.List {
-		result[i] = NewTransaction(item)
-		// fmt.Println(result[i])
+	for _, num := range nvp.ListNumbers() {
+		result = append(result, NewTransaction(nvp.List[num]))
 	}
 
 	return result
@@ -110,6 +212,19 @@ func (p Transactions) Sort() {
 	sort.Sort(ByDate{p})
 }
 
+// EarliestPending returns the earliest of the transactions which is pending,
+// or nil if none are.
+func (p Transactions) EarliestPending() *Transaction {
+	var result *Transaction
+	for _, t := range p {
+		if t.Status == StatusPending && (result == nil || t.Timestamp.Before(result.Timestamp)) {
+			result = t
+		}
+	}
+
+	return result
+}
+
 func (p Transactions) TotalByCurrency() util.CurrencyAmounts {
 	result := make(util.CurrencyAmounts)
 
@@ -120,12 +235,16 @@ func (p Transactions) TotalByCurrency() util.CurrencyAmounts {
 	return result
 }
 
+// FilterDonations splits the transactions into donations, including pending
+// donations, any returns of donations and conversions LinkConversions linked
+// to donations, and everything else.
 func (p Transactions) FilterDonations() (Transactions, Transactions) {
 	donations := make(Transactions, 0, len(p))
 	other := make(Transactions, 0, len(p))
 
 	for _, item := range p {
-		if item.IsDonation() || item.IsSubscription() {
+		if item.IsDonation() || item.IsSubscription() || item.IsReturn() || item.IsPending() ||
+			item.convertsDonation {
 			donations = append(donations, item)
 		} else {
 			other = append(other, item)
@@ -135,39 +254,105 @@ func (p Transactions) FilterDonations() (Transactions, Transactions) {
 	return donations, other
 }
 
+// Summarize adds the completed donations, returns and conversions to the
+// summary of their month. Pending donations are kept apart from the totals.
 func (p Transactions) Summarize() util.MonthlySummaries {
 	result := make(util.MonthlySummaries)
 
 	for _, item := range p {
-		_, month, _ := item.Timestamp.Date()
+		_, month := util.MonthOf(item.Timestamp)
 		summary := result.ForMonth(month)
 
 		if item.IsDonation() {
 			summary.AddOneTime(item.Amt, item.FeeAmt, item.CurrencyCode)
 		} else if item.IsSubscription() {
 			summary.AddSubscription(item.Amt, item.FeeAmt, item.CurrencyCode)
+		} else if item.IsReturn() {
+			summary.AddReturn(item.Amt, item.FeeAmt, item.CurrencyCode)
+		} else if item.convertsDonation {
+			summary.AddConversion(item.Amt, item.CurrencyCode)
+		} else if item.IsPending() {
+			summary.AddPending(item.Amt, item.CurrencyCode)
 		}
 	}
 
 	return result
 }
 
+// Merge adds the other transactions which are not already in these. A
+// transaction which is already here is replaced when the other one has a
+// different status, such as a pending donation which has since completed.
 func (p Transactions) Merge(other Transactions) Transactions {
+	result, _ := p.MergeUpdated(other)
+
+	return result
+}
+
+// MergeUpdated is Merge which also returns how many transactions were
+// replaced because their status changed.
+func (p Transactions) MergeUpdated(other Transactions) (Transactions, int) {
+	return p.merge(other, func(older *Transaction) bool {
+		return true
+	})
+}
+
+// MergePending is MergeUpdated which only replaces transactions which are
+// pending here, for other transactions which are less trusted than these,
+// like those of an Activity download.
+func (p Transactions) MergePending(other Transactions) (Transactions, int) {
+	return p.merge(other, func(older *Transaction) bool {
+		return older.Status == StatusPending
+	})
+}
+
+// merge adds the other transactions which are not already in these, and
+// replaces those which have a different status when replace allows it.
+func (p Transactions) merge(other Transactions, replace func(older *Transaction) bool) (Transactions, int) {
 	result := make(Transactions, 0, len(p)+len(other))
-	tranIDs := map[string]bool{}
+	keys := map[string]int{}
+	updated := 0
 
-	// Add everything in our own list, tracking transaction IDs
+	// Add everything in our own list, tracking transaction keys
 	for _, item := range p {
-		tranIDs[item.TransactionID] = true
+		keys[item.Key()] = len(result)
 		result = append(result, item)
 	}
 
-	// Add anything new not already in the list
+	// Add anything new not already in the list, and update what changed
 	for _, item := range other {
-		if _, found := tranIDs[item.TransactionID]; !found {
+		i, found := keys[item.Key()]
+		if !found {
+			keys[item.Key()] = len(result)
 			result = append(result, item)
+		} else if result[i].Status != item.Status && replace(result[i]) {
+			result[i] = item.withDetailsOf(result[i])
+			updated++
 		}
 	}
 
-	return result
+	return result, updated
+}
+
+// withDetailsOf returns a copy of the transaction with anything only known
+// from the details, or from linking, taken from the older copy when it is not
+// known here.
+func (p *Transaction) withDetailsOf(older *Transaction) *Transaction {
+	result := *p
+	for _, field := range []struct{ dst, src *string }{
+		{&result.Note, &older.Note},
+		{&result.CountryCode, &older.CountryCode},
+		{&result.Custom, &older.Custom},
+		{&result.InvoiceID, &older.InvoiceID},
+		{&result.ItemName, &older.ItemName},
+		{&result.Receiver, &older.Receiver},
+		{&result.ProfileID, &older.ProfileID},
+		{&result.ParentTransactionID, &older.ParentTransactionID},
+		{&result.Account, &older.Account},
+	} {
+		if *field.dst == "" {
+			*field.dst = *field.src
+		}
+	}
+
+	return &result
 }
@@ -2,6 +2,7 @@ package paypal
 
 import (
 	"testing"
+	"time"
 
 	"github.com/leavengood/donation_tracker/util"
 	"github.com/stretchr/testify/assert"
@@ -119,3 +120,100 @@ func TestFilterDonationsWithADonationSubscriptionAndPayment(t *testing.T) {
 	assert.Equal(t, float32(5.43), result[0].Amt)
 	assert.Equal(t, float32(2.45), result[1].Amt)
 }
+
+func TestFilterDonationsOnlyCountsCompleted(t *testing.T) {
+	txns := Transactions{
+		{Amt: 10, Type: "Donation", CurrencyCode: "USD", Status: "Completed"},
+		{Amt: 20, Type: "Donation", CurrencyCode: "USD", Status: "Pending"},
+		{Amt: 30, Type: "Donation", CurrencyCode: "USD", Status: "Denied"},
+		{Amt: 40, Type: "Donation", CurrencyCode: "USD", Status: "Reversed"},
+		{Amt: 50, Type: "Donation", CurrencyCode: "USD", Status: "Unclaimed"},
+		{Amt: 60, Type: "Recurring Payment", CurrencyCode: "USD", Status: "Refunded"},
+		{Amt: -5, Type: "Refund", CurrencyCode: "USD", Status: "Pending"},
+	}
+
+	donations, other := txns.FilterDonations()
+	// The pending donation is kept to be shown on its own
+	assert.Equal(t, 3, len(donations))
+	assert.Equal(t, 4, len(other))
+
+	sums := donations.Summarize()[time.January]
+	assert.Equal(t, float32(10), sums.OneTimeAmt["USD"])
+	assert.Equal(t, 1, sums.OneTimeCount)
+	assert.Equal(t, 1, sums.SubscriptionCount)
+	assert.Equal(t, 1, sums.PendingCount)
+	assert.Equal(t, float32(20), sums.PendingAmt["USD"])
+	assert.Equal(t, 0, sums.ReturnedCount)
+}
+
+//==============================================================================
+// Merge
+//==============================================================================
+
+func TestMergeUpdatesChangedStatus(t *testing.T) {
+	stored := Transactions{
+		{TransactionID: "1A", Type: "Donation", Amt: 10, Status: "Pending", Note: "Keep it up", Account: "main"},
+		{TransactionID: "1B", Type: "Donation", Amt: 20, Status: "Completed"},
+	}
+	fetched := Transactions{
+		{TransactionID: "1A", Type: "Donation", Amt: 10, Status: "Completed"},
+		{TransactionID: "1B", Type: "Donation", Amt: 20, Status: "Completed"},
+		{TransactionID: "1C", Type: "Donation", Amt: 30, Status: "Completed"},
+	}
+
+	merged, updated := stored.MergeUpdated(fetched)
+	assert.Equal(t, 3, len(merged))
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, "Completed", merged[0].Status)
+	// What only the details gave is kept
+	assert.Equal(t, "Keep it up", merged[0].Note)
+	assert.Equal(t, "main", merged[0].Account)
+	// The stored copy is left alone
+	assert.Equal(t, "Pending", stored[0].Status)
+}
+
+func TestMergePendingOnlyUpdatesPending(t *testing.T) {
+	stored := Transactions{
+		{TransactionID: "1A", Type: "Donation", Amt: 10, Status: "Pending"},
+		{TransactionID: "1B", Type: "Donation", Amt: 20, Status: "Completed", Note: "From the API"},
+		{TransactionID: "1C", Type: "Donation", Amt: 30, Status: "Refunded"},
+	}
+	imported := Transactions{
+		{TransactionID: "1A", Type: "Donation", Amt: 10, Status: "Completed"},
+		// Statuses of the file which the API does not use
+		{TransactionID: "1B", Type: "Donation", Amt: 20, Status: "Abgeschlossen"},
+		{TransactionID: "1C", Type: "Donation", Amt: 30, Status: "Partially Refunded"},
+		{TransactionID: "1D", Type: "Donation", Amt: 40, Status: "Completed"},
+	}
+
+	merged, updated := stored.MergePending(imported)
+	assert.Equal(t, 4, len(merged))
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, "Completed", merged[0].Status)
+	assert.Equal(t, "Completed", merged[1].Status)
+	assert.Equal(t, "From the API", merged[1].Note)
+	assert.Equal(t, "Refunded", merged[2].Status)
+	assert.Equal(t, "1D", merged[3].TransactionID)
+}
+
+//==============================================================================
+// Time zones
+//==============================================================================
+
+func TestMonthsInLocation(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	util.SetLocation(ny)
+	defer util.SetLocation(time.UTC)
+
+	assert.Equal(t, "2020-03-01T05:00:00Z", GetStartDate(2020, 3))
+	assert.Equal(t, "2020-04-01T03:59:59Z", GetEndDate(2020, 3))
+
+	sums := Transactions{
+		{Timestamp: time.Date(2020, time.April, 1, 2, 0, 0, 0, time.UTC), Type: "Donation", Amt: 10, CurrencyCode: "USD"},
+		{Timestamp: time.Date(2020, time.April, 1, 5, 0, 0, 0, time.UTC), Type: "Donation", Amt: 20, CurrencyCode: "USD"},
+	}.Summarize()
+
+	assert.Equal(t, float32(10), sums[time.March].OneTimeAmt["USD"])
+	assert.Equal(t, float32(20), sums[time.April].OneTimeAmt["USD"])
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/leavengood/donation_tracker/githubsponsors"
+	"github.com/leavengood/donation_tracker/opencollective"
 	"github.com/leavengood/donation_tracker/other"
 	"github.com/leavengood/donation_tracker/paypal"
+	"github.com/leavengood/donation_tracker/stripe"
 	"github.com/leavengood/donation_tracker/util"
 )
 
@@ -27,20 +33,90 @@ func AddTransactions(year int, m util.MonthlySummaries) {
 	fmt.Printf("Total for other transactions: %s\n", otherSummary)
 }
 
-func SummarizeYear(year int, eurToUsdRate float32, fm *paypal.FileManager) *DonationSummary {
+// otherSources are the donations of a year which did not come through
+// PayPal.
+type otherSources struct {
+	// Nil if Stripe is not configured
+	Stripe         *stripe.FileManager
+	OpenCollective opencollective.Transactions
+	GitHubSponsors githubsponsors.Transactions
+}
+
+// loadOtherSources loads the saved donations of the year from the sources
+// besides PayPal.
+func loadOtherSources(year int) (*otherSources, error) {
+	stripeFM, err := loadStripeYear(year)
+	if err != nil {
+		return nil, err
+	}
+	ocTxns, err := opencollective.LoadYear(year)
+	if err != nil {
+		return nil, err
+	}
+	sponsorTxns, err := githubsponsors.LoadYear(year)
+	if err != nil {
+		return nil, err
+	}
+
+	return &otherSources{Stripe: stripeFM, OpenCollective: ocTxns, GitHubSponsors: sponsorTxns}, nil
+}
+
+// updateOtherSources gets any new donations of the year from the sources
+// besides PayPal which have an API configured, and loads the rest.
+func updateOtherSources(ctx context.Context, year int) (*otherSources, error) {
+	stripeFM, err := updateStripeYear(ctx, year)
+	if err != nil {
+		return nil, err
+	}
+	ocTxns, err := updateOpenCollectiveYear(ctx, year)
+	if err != nil {
+		return nil, err
+	}
+	// GitHub Sponsors can only be imported
+	sponsorTxns, err := githubsponsors.LoadYear(year)
+	if err != nil {
+		return nil, err
+	}
+
+	return &otherSources{Stripe: stripeFM, OpenCollective: ocTxns, GitHubSponsors: sponsorTxns}, nil
+}
+
+// SummarizeYear prints the monthly and yearly totals of the PayPal
+// transactions, the other transactions and those of the other sources, if
+// any, and returns the summary to upload.
+func SummarizeYear(year int, eurToUsdRate float32, fm *paypal.FileManager, others *otherSources) *DonationSummary {
 	summaries := util.MonthlySummaries{}
+
+	// Link returns to their donations, which could be in an earlier month
+	all := paypal.Transactions{}
+	for _, month := range fm.GetExistingMonths() {
+		all = append(all, fm.Months[month]...)
+	}
+	all.LinkReturns()
+	conversions := all.LinkConversions()
+
 	// TODO: Extract this so it can be used for the one month process. Maybe put it into
 	// MonthlySummaries itself.
 	summarizeMonth := func(month time.Month, txns paypal.Transactions) {
-		donations, other := txns.FilterDonations()
+		counted, voided := txns.WithoutVoided(all)
+		donations, other := counted.FilterDonations()
+		other = append(other, voided...)
 		monthStr := util.Colorize(util.Blue, fmt.Sprintf("%s %d", month, year))
+		count := 0
+		for _, t := range donations {
+			if t.IsDonation() || t.IsSubscription() {
+				count++
+			}
+		}
 		fmt.Printf("%s: There are %d donations from %d transactions\n",
-			monthStr, len(donations), len(txns))
+			monthStr, count, len(txns))
 		// TODO: Maybe this Summarize in PayPalTxns needs to be moved into
 		// MonthlySummaries, so this all becomes less awkward.
 		sums := donations.Summarize()
 		if len(sums) > 1 {
 			fmt.Printf("    WARNING: multiple months found in summary for %s\n", monthStr)
+			fmt.Printf("    Only %s in the %s time zone is counted, fetch the month again to fix this\n",
+				month, util.Location())
 		}
 		monthSummary := sums[month]
 		// At the beginning of the month in the current year, this could be empty
@@ -48,6 +124,32 @@ func SummarizeYear(year int, eurToUsdRate float32, fm *paypal.FileManager) *Dona
 			fmt.Printf("    Donations: %s\n", monthSummary)
 			summaries[month] = monthSummary
 		}
+		pending := false
+		for _, t := range donations {
+			if !t.IsPending() {
+				continue
+			}
+			if !pending {
+				fmt.Println("\n    Pending donations, not counted yet:")
+				pending = true
+			}
+			fmt.Printf("        %s\n", util.Colorize(util.BrightYellow, t.String()))
+		}
+		returned := false
+		for _, t := range donations {
+			if !t.IsReturn() {
+				continue
+			}
+			if !returned {
+				fmt.Println("\n    Returned donations:")
+				returned = true
+			}
+			if parent := all.Parent(t); parent != nil {
+				fmt.Printf("        %s\n            for %s\n", util.Colorize(util.Red, t.String()), parent)
+			} else {
+				fmt.Printf("        %s\n            for an unknown donation\n", util.Colorize(util.Red, t.String()))
+			}
+		}
 		if len(other) > 0 {
 			fmt.Println("\n    Other transactions:")
 			for _, t := range other {
@@ -67,96 +169,284 @@ func SummarizeYear(year int, eurToUsdRate float32, fm *paypal.FileManager) *Dona
 
 	// Add in special transactions to each monthly summary
 	AddTransactions(year, summaries)
+	stripeTotal := AddStripe(year, summaries, others.Stripe)
+	ocTotal := AddOpenCollective(year, summaries, others.OpenCollective)
+	sponsorsTotal := AddGitHubSponsors(year, summaries, others.GitHubSponsors)
 
 	// Create totals and return the summary
 	total := summaries.Total()
 	fmt.Printf("\nTotal: %s\n", total)
 	grossTotal := total.GrossTotal()
 	fmt.Printf("Combined Total: %s\n", grossTotal)
+	if total.ReturnedCount > 0 {
+		fmt.Printf("Returned: %s (%d refunds, reversals and chargebacks)\n", total.ReturnedAmt, total.ReturnedCount)
+	}
+	if total.PendingCount > 0 {
+		fmt.Printf("Pending: %s (%d donations not counted until they complete)\n", total.PendingAmt, total.PendingCount)
+	}
+	if len(conversions) > 0 {
+		printConversions(conversions, eurToUsdRate)
+	}
+	fmt.Printf("Unconverted after fees: %s\n", total.Unconverted())
 	grandTotal := grossTotal.GrandTotal(eurToUsdRate)
 	fmt.Println(util.Colorize(util.Yellow, fmt.Sprintf("Grand Total (at EUR to USD rate of %f): %.02f",
 		eurToUsdRate, grandTotal)))
 
 	return &DonationSummary{
-		UpdatedAt:      time.Now().UTC(),
+		UpdatedAt:      now().UTC(),
 		UsdDonations:   grossTotal["USD"],
 		EurDonations:   grossTotal["EUR"],
 		EurToUsdRate:   eurToUsdRate,
 		TotalDonations: grandTotal,
+		UsdReturned:    -total.ReturnedAmt["USD"],
+		EurReturned:    -total.ReturnedAmt["EUR"],
+
+		StripeDonations:         stripeTotal.GrossTotal().GrandTotal(eurToUsdRate),
+		OpenCollectiveDonations: ocTotal.GrossTotal().GrandTotal(eurToUsdRate),
+		GitHubSponsorsDonations: sponsorsTotal.GrossTotal().GrandTotal(eurToUsdRate),
+	}
+}
+
+// printConversions prints how much of each currency PayPal converted, what it
+// was converted into, and so the USD really received, and what fixer.io
+// would have made of it in USD.
+func printConversions(conversions []*paypal.Conversion, eurToUsdRate float32) {
+	from := util.CurrencyAmounts{}
+	to := map[string]util.CurrencyAmounts{}
+	for _, c := range conversions {
+		currency := c.From.CurrencyCode
+		from[currency] -= c.From.Amt
+		if to[currency] == nil {
+			to[currency] = util.CurrencyAmounts{}
+		}
+		to[currency][c.To.CurrencyCode] += c.To.Amt
+	}
+	currencies := make([]string, 0, len(from))
+	for currency := range from {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	fmt.Println("Converted by PayPal:")
+	for _, currency := range currencies {
+		line := fmt.Sprintf("    %s %.02f into %s", currency, from[currency], formatAmounts(to[currency]))
+		rate, err := usdRate(currency, eurToUsdRate)
+		if err != nil {
+			line += fmt.Sprintf(", with no fixer.io rate: %v", err)
+		} else {
+			line += fmt.Sprintf(", which fixer.io would make USD %.02f", from[currency]*rate)
+		}
+		fmt.Println(line)
+	}
+}
+
+// formatAmounts formats amounts in any currency, in order of currency.
+func formatAmounts(amounts util.CurrencyAmounts) string {
+	currencies := make([]string, 0, len(amounts))
+	for currency := range amounts {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	parts := make([]string, len(currencies))
+	for i, currency := range currencies {
+		parts[i] = fmt.Sprintf("%s %.02f", currency, amounts[currency])
 	}
+
+	return strings.Join(parts, ", ")
+}
+
+// usdRates are the rates to USD fetched from fixer.io for currencies besides
+// EUR, whose rate is fetched for every command
+var usdRates = map[string]float32{"USD": 1}
+
+// usdRate returns how many USD one of the currency is worth.
+func usdRate(currency string, eurToUsdRate float32) (float32, error) {
+	if currency == "EUR" {
+		return eurToUsdRate, nil
+	}
+	if rate, found := usdRates[currency]; found {
+		return rate, nil
+	}
+
+	rate, err := GetUsdRate(config.FixerIoAccessKey, currency)
+	if err != nil {
+		return 0, err
+	}
+	usdRates[currency] = rate
+
+	return rate, nil
 }
 
 // ProcessYear will take the provided year and EUR to USD conversion rate and
 // perform the summary process which involves loading current data for the given
-// year, getting any missing data, and then summarizing it all.
-func ProcessYear(client *paypal.Client, year int, eurToUsdRate float32) (*DonationSummary, error) {
-	currentYear, currentMonth, _ := time.Now().UTC().Date()
+// year of each PayPal account, getting any missing data, and then summarizing it
+// all. The totals of each account are also printed when byAccount is true.
+func ProcessYear(ctx context.Context, accounts []*payPalAccount, year int, eurToUsdRate float32, byAccount bool) (*DonationSummary, error) {
+	currentYear, _, _ := util.InLocation(now()).Date()
+	finished := map[int]bool{}
 
-	// Load current files for the year
-	fm, err := paypal.NewFileManager(year)
+	fms := make([]*paypal.FileManager, 0, len(accounts))
+	for _, acct := range accounts {
+		if acct.Name != "" {
+			fmt.Printf("Updating the %s PayPal account\n", acct.Name)
+		}
+		// Top up the years before, like the end of the one which just closed
+		if year == currentYear {
+			years, err := finishEarlierYears(ctx, acct, year)
+			if err != nil {
+				return nil, wrapAccountError(acct.Name, err)
+			}
+			for _, y := range years {
+				finished[y] = true
+			}
+		}
+		fm, err := updateAccountYear(ctx, acct, year)
+		if err != nil {
+			return nil, wrapAccountError(acct.Name, err)
+		}
+		fms = append(fms, fm)
+	}
+	others, err := updateOtherSources(ctx, year)
 	if err != nil {
 		return nil, err
 	}
 
-	// First deal with the latest month we have saved. It could be several
-	// months ago depending on how long it has been between runs.
-	latest := fm.GetLatestMonth()
-	if latest != 0 {
-		// Assume this is a partial month
-		t := fm.GetLatestTransaction()
-
-		month := time.Month(latest)
-		var day int
-		// There may not be any transactions so far this month
-		if t == nil {
-			// Go to the beginning of the month
-			day = 1
-		} else {
-			day = t.Timestamp.Day()
-		}
-		// fmt.Printf("The latest transaction is: %#v, with timestamp: %s\n", t, t.Timestamp)
-
-		// Start from the beginning of this day so we don't miss anything
-		startDate := fmt.Sprintf("%d-%02d-%02dT00:00:00Z", year, month, day)
-		fmt.Printf("Fetching PayPal transactions newer than: %s\n", startDate)
-		newTxns := client.GetTransactions(startDate, paypal.GetEndDate(year, int(month)))
-		fmt.Printf("Found %d new transactions\n", len(newTxns))
-		previous := fm.Months[latest]
-		// Merge will remove any duplicates
-		txns := previous.Merge(newTxns)
-
-		// Only save if we got new transactions
-		if len(txns) > len(previous) {
-			if err := fm.SaveMonth(latest, txns); err != nil {
-				return nil, err
-			}
-		} else {
-			fmt.Printf("No new transactions found for latest month: %d\n", latest)
+	// The final totals of each year which was just finished
+	earlier := make([]int, 0, len(finished))
+	for y := range finished {
+		earlier = append(earlier, y)
+	}
+	sort.Ints(earlier)
+	for _, y := range earlier {
+		if err := reportFinishedYear(ctx, accounts, y, eurToUsdRate); err != nil {
+			return nil, fmt.Errorf("could not report the final totals of %d: %w", y, err)
 		}
 	}
 
-	missing := fm.GetMissingMonths()
-	// If the current year is being processed, remove future months
-	if year == currentYear {
-		newMissing := []int{}
-		for _, month := range missing {
-			if month > int(currentMonth) {
-				break
-			}
-			newMissing = append(newMissing, month)
+	summary := SummarizeYear(year, eurToUsdRate, paypal.CombineFileManagers(year, fms...), others)
+	if byAccount {
+		fmt.Println("")
+		printAccountBreakdown(year, eurToUsdRate, fms)
+	}
+
+	return summary, nil
+}
+
+// updateAccountYear loads the saved months of the year for the account and
+// gets what they do not cover yet from PayPal, going by what was recorded
+// about each month when it was fetched.
+func updateAccountYear(ctx context.Context, acct *payPalAccount, year int) (*paypal.FileManager, error) {
+	// Load current files for the year
+	fm, err := paypal.NewFileManager(acct.Name, year)
+	if err != nil {
+		return nil, err
+	}
+
+	// The rest of partly fetched months, and any pending transactions again
+	// so their final status is saved
+	missing, partial := fm.PlanFetches()
+	for _, f := range partial {
+		if err := paypal.FetchAndMerge(ctx, acct.source, fm, f); err != nil {
+			return nil, err
 		}
-		missing = newMissing
 	}
 	fmt.Printf("The missing months are: %v\n", missing)
 
-	// Get missing months from PayPal API and save them
-	for _, month := range missing {
-		if err := client.GetAndSaveMonth(year, month, fm); err != nil {
+	// Get missing months from PayPal API and save them, several at once
+	if err := paypal.GetAndSaveMonths(ctx, acct.source, year, missing, fm, acct.BackfillWorkers); err != nil {
+		return nil, err
+	}
+
+	fmt.Println("")
+
+	return fm, nil
+}
+
+// finishEarlierYears gets what is not covered yet of the years before the
+// given one which have anything saved for the account, from the latest saved
+// month of each on, so the last days of December are not left out once it is
+// January. Those are the previous year, which may have been saved before the
+// coverage was recorded, and any earlier year whose recorded coverage stops
+// before its end. The years which were topped up are returned.
+func finishEarlierYears(ctx context.Context, acct *payPalAccount, year int) ([]int, error) {
+	finished := []int{}
+
+	years, err := paypal.SavedYears(acct.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, earlier := range years {
+		if earlier >= year {
+			continue
+		}
+		fm, err := paypal.NewFileManager(acct.Name, earlier)
+		if err != nil {
 			return nil, err
 		}
+		// PayPal may not even have older years anymore, so they are left
+		// alone unless they are known to be unfinished
+		if earlier < year-1 && !fm.StopsEarly() {
+			continue
+		}
+
+		topped, err := finishAccountYear(ctx, acct, fm)
+		if err != nil {
+			return nil, fmt.Errorf("could not finish %d: %w", earlier, err)
+		}
+		if topped {
+			finished = append(finished, earlier)
+		}
+	}
+
+	return finished, nil
+}
+
+// finishAccountYear gets what is not covered yet of the months of an earlier
+// year from its latest saved month on, returning whether anything needed to
+// be fetched.
+func finishAccountYear(ctx context.Context, acct *payPalAccount, fm *paypal.FileManager) (bool, error) {
+	latest := fm.GetLatestMonth()
+	missing, partial := fm.PlanFetches()
+	after := []int{}
+	for _, month := range missing {
+		if month > latest {
+			after = append(after, month)
+		}
+	}
+	if len(partial) == 0 && len(after) == 0 {
+		return false, nil
+	}
+
+	fmt.Printf("Finishing the PayPal transactions of %d first\n", fm.Year)
+	for _, f := range partial {
+		if err := paypal.FetchAndMerge(ctx, acct.source, fm, f); err != nil {
+			return false, err
+		}
+	}
+	if err := paypal.GetAndSaveMonths(ctx, acct.source, fm.Year, after, fm, acct.BackfillWorkers); err != nil {
+		return false, err
+	}
+	fmt.Println("")
+
+	return true, nil
+}
+
+// reportFinishedYear gets what is new of the other sources of a year which was
+// just finished and prints its final totals.
+func reportFinishedYear(ctx context.Context, accounts []*payPalAccount, year int, eurToUsdRate float32) error {
+	fm, _, err := loadYear(accounts, year)
+	if err != nil {
+		return err
+	}
+	others, err := updateOtherSources(ctx, year)
+	if err != nil {
+		return err
 	}
 
+	fmt.Printf("%s\n\n", util.Colorize(util.Green, fmt.Sprintf("Final Totals for %d", year)))
+	SummarizeYear(year, eurToUsdRate, fm, others)
 	fmt.Println("")
 
-	return SummarizeYear(year, eurToUsdRate, fm), nil
+	return nil
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/leavengood/donation_tracker/httprecord"
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// now returns the current time, or the time of the recording when replaying,
+// so the same requests are made.
+var now = time.Now
+
+// setupRecording makes all HTTP traffic, to PayPal and fixer.io, be recorded
+// to or replayed from the given directory. At most one should be provided.
+func setupRecording(recordDir, replayDir string) error {
+	switch {
+	case recordDir != "" && replayDir != "":
+		return errors.New("only one of -record or -replay can be used")
+
+	case recordDir != "":
+		recorder, err := httprecord.NewRecorder(recordDir, http.DefaultTransport)
+		if err != nil {
+			return err
+		}
+		session := &httprecord.Session{Time: now().UTC(), Args: os.Args[1:]}
+		if err := httprecord.SaveSession(recordDir, session); err != nil {
+			return err
+		}
+		http.DefaultTransport = recorder
+		fmt.Printf("Recording HTTP traffic to %s\n", recordDir)
+
+	case replayDir != "":
+		replayer, err := httprecord.NewReplayer(replayDir)
+		if err != nil {
+			return err
+		}
+		session, err := httprecord.LoadSession(replayDir)
+		if err != nil {
+			return fmt.Errorf("could not load the recorded session: %w", err)
+		}
+		recorded := session.Time
+		now = func() time.Time { return recorded }
+		http.DefaultTransport = replayer
+		fmt.Printf("Replaying HTTP traffic recorded on %s from %s\n", recorded.Format(time.RFC1123), replayDir)
+	}
+
+	return nil
+}
+
+// recordedDataDir is where a recording keeps its copy of the data directory
+func recordedDataDir(dir string) string {
+	return filepath.Join(dir, "data")
+}
+
+// saveRecordedData copies the data directory, as it is before the command
+// changes anything, into the recording so a replay starts from the same data.
+// The data directory should be locked.
+func saveRecordedData(recordDir string) error {
+	dir := recordedDataDir(recordDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := util.CopyDir(util.DataDir(), dir); err != nil {
+		return fmt.Errorf("could not copy the data directory to the recording: %w", err)
+	}
+
+	return nil
+}
+
+// useReplayData makes the command use a scratch copy of the data saved in the
+// recording, so a replay never changes the real data directory. Recordings
+// made without a copy of the data start from a copy of the real data
+// directory instead. The returned function removes the scratch copy.
+func useReplayData(replayDir string) (func(), error) {
+	src := recordedDataDir(replayDir)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		fmt.Printf("%s The recording has no copy of the data directory, so a copy of %s is used.\n",
+			util.Colorize(util.BrightYellow, "!"), util.DataDir())
+		src = util.DataDir()
+	}
+
+	scratch, err := ioutil.TempDir("", "donation-tracker-replay-")
+	if err != nil {
+		return nil, err
+	}
+	remove := func() { os.RemoveAll(scratch) }
+	dir := filepath.Join(scratch, "data")
+	if err := util.CopyDir(src, dir); err != nil {
+		remove()
+		return nil, fmt.Errorf("could not copy the data to replay with: %w", err)
+	}
+	util.SetDataDir(dir)
+
+	return remove, nil
+}
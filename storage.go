@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/leavengood/donation_tracker/paypal"
+)
+
+// storageKind returns the kind of storage, with the default filled in
+func storageKind(kind string) string {
+	if kind == "" {
+		return paypal.StorageJSON
+	}
+
+	return kind
+}
+
+// migrateStorage copies every saved month of the accounts from one kind of
+// storage to the other, replacing any months already saved there, and checks
+// that the destination then has the same transactions.
+func migrateStorage(accounts []*payPalAccount, from, to string) error {
+	from, to = storageKind(from), storageKind(to)
+	if from == to {
+		return fmt.Errorf("the transactions are already in %s storage", from)
+	}
+
+	src, err := paypal.OpenStorage(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := paypal.OpenStorage(to)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	for _, acct := range accounts {
+		monthCount, txnCount, err := migrateAccount(src, dst, acct.Name)
+		if err != nil {
+			return wrapAccountError(acct.Name, err)
+		}
+		fmt.Printf("Copied %d months with %d transactions of the %s account from %s to %s storage.\n",
+			monthCount, txnCount, accountName(acct.Name), from, to)
+	}
+
+	if to != storageKind(config.Storage) {
+		fmt.Printf("\nSet \"storage\": %q in %s to use it.\n", to, ConfigFile)
+	}
+
+	return nil
+}
+
+// migrateAccount copies the months of one account, returning how many months
+// and transactions were copied.
+func migrateAccount(src, dst paypal.Storage, account string) (int, int, error) {
+	monthCount, txnCount := 0, 0
+
+	years, err := src.Years(account)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, year := range years {
+		months, err := src.LoadYear(account, year)
+		if err != nil {
+			return 0, 0, fmt.Errorf("could not load %d: %w", year, err)
+		}
+		order := make([]int, 0, len(months))
+		for month := range months {
+			order = append(order, month)
+		}
+		sort.Ints(order)
+
+		for _, month := range order {
+			if err := dst.SaveMonth(account, year, month, months[month]); err != nil {
+				return 0, 0, fmt.Errorf("could not save %d-%02d: %w", year, month, err)
+			}
+			monthCount++
+			txnCount += len(months[month].Transactions)
+		}
+
+		copied, err := dst.LoadYear(account, year)
+		if err != nil {
+			return 0, 0, fmt.Errorf("could not check %d: %w", year, err)
+		}
+		for month, saved := range months {
+			if copied[month] == nil || len(copied[month].Transactions) != len(saved.Transactions) {
+				return 0, 0, fmt.Errorf("%d-%02d was not copied with all %d transactions",
+					year, month, len(saved.Transactions))
+			}
+		}
+	}
+
+	return monthCount, txnCount, nil
+}
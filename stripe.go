@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leavengood/donation_tracker/stripe"
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// updateStripeYear gets the months of the year from Stripe which have not
+// been saved since they ended, since those could be partial. It returns nil
+// if Stripe is not configured.
+func updateStripeYear(ctx context.Context, year int) (*stripe.FileManager, error) {
+	if config.Stripe == nil {
+		return nil, nil
+	}
+	currentYear, currentMonth, _ := util.InLocation(now()).Date()
+
+	fm, err := stripe.NewFileManager(year)
+	if err != nil {
+		return nil, fmt.Errorf("could not load Stripe files for year %d: %w", year, err)
+	}
+
+	client := stripe.NewClient(config.Stripe)
+	for month := 1; month <= 12; month++ {
+		// Skip future months of the current year
+		if fm.IsComplete(month) || year == currentYear && month > int(currentMonth) {
+			continue
+		}
+		if err := stripe.GetAndSaveMonth(ctx, client, year, month, fm); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Println("")
+
+	return fm, nil
+}
+
+// loadStripeYear loads the saved Stripe transactions of the year. It returns
+// nil if Stripe is not configured.
+func loadStripeYear(year int) (*stripe.FileManager, error) {
+	if config.Stripe == nil {
+		return nil, nil
+	}
+
+	fm, err := stripe.NewFileManager(year)
+	if err != nil {
+		return nil, fmt.Errorf("could not load Stripe files for year %d: %w", year, err)
+	}
+
+	return fm, nil
+}
+
+// AddStripe adds the Stripe donations to the monthly summaries and returns
+// their total.
+func AddStripe(year int, m util.MonthlySummaries, fm *stripe.FileManager) *util.Summary {
+	total := util.NewSummary()
+	if fm == nil {
+		return total
+	}
+
+	fmt.Printf("\n%s\n\n", util.Colorize(util.Green, "Stripe Monthly Totals"))
+	for _, month := range fm.GetExistingMonths() {
+		monthStr := util.Colorize(util.Blue, fmt.Sprintf("%s %d", time.Month(month), year))
+		txns := fm.Months[month]
+		summary := txns.Summarize()[time.Month(month)]
+		if summary == nil {
+			fmt.Printf("%s: There are no Stripe donations from %d transactions\n", monthStr, len(txns))
+			continue
+		}
+		fmt.Printf("%s: There are %d Stripe donations from %d transactions\n",
+			monthStr, summary.OneTimeCount+summary.SubscriptionCount, len(txns))
+		fmt.Printf("    Donations: %s\n", summary)
+		for _, t := range txns {
+			if t.IsReturn() {
+				fmt.Printf("    Refunded: %s\n", util.Colorize(util.Red, t.String()))
+			}
+		}
+
+		m.ForMonth(time.Month(month)).Add(summary)
+		total.Add(summary)
+	}
+	fmt.Printf("Total for Stripe: %s\n", total)
+
+	return total
+}
@@ -0,0 +1,248 @@
+package stripe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	balanceTransactionsPath = "/v1/balance_transactions"
+	chargesPath             = "/v1/charges/"
+
+	// The most Stripe returns in one page of a list
+	pageSize = 100
+)
+
+// zeroDecimal are the currencies Stripe gives in whole units instead of cents
+var zeroDecimal = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true, "KRW": true, "MGA": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// amount converts an amount in the smallest unit of the currency, as Stripe
+// gives them, to a normal amount.
+func amount(units int64, currency string) float32 {
+	if zeroDecimal[currency] {
+		return float32(units)
+	}
+
+	return float32(units) / 100
+}
+
+// Error is returned when Stripe responds with an error.
+type Error struct {
+	StatusCode int
+	Type       string `json:"type"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Stripe returned HTTP status %d: %s (%s)", e.StatusCode, e.Message, e.Type)
+}
+
+// IsAuthError returns true if Stripe did not accept the key.
+func (e *Error) IsAuthError() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// Client gets transactions from the Stripe API
+type Client struct {
+	config *Config
+	client *http.Client
+}
+
+func NewClient(config *Config) *Client {
+	timeout := config.TimeoutSeconds
+	if timeout == 0 {
+		timeout = DefaultTimeoutSeconds
+	}
+
+	return &Client{
+		config: config,
+		client: &http.Client{Timeout: time.Duration(timeout) * time.Second},
+	}
+}
+
+func (c *Client) endpoint() string {
+	if c.config.Endpoint != "" {
+		return strings.TrimSuffix(c.config.Endpoint, "/")
+	}
+
+	return DefaultEndpoint
+}
+
+// get calls the API and decodes the JSON response into result.
+func (c *Client) get(ctx context.Context, path string, query url.Values, result interface{}) error {
+	u := c.endpoint() + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.config.SecretKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		errResp := struct {
+			Error *Error `json:"error"`
+		}{}
+		if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error == nil {
+			return &Error{StatusCode: resp.StatusCode, Message: string(body)}
+		}
+		errResp.Error.StatusCode = resp.StatusCode
+		return errResp.Error
+	}
+
+	return json.Unmarshal(body, result)
+}
+
+type charge struct {
+	ID             string `json:"id"`
+	Object         string `json:"object"`
+	Amount         int64  `json:"amount"`
+	Currency       string `json:"currency"`
+	Description    string `json:"description"`
+	ReceiptEmail   string `json:"receipt_email"`
+	Invoice        string `json:"invoice"`
+	BillingDetails struct {
+		Name    string `json:"name"`
+		Email   string `json:"email"`
+		Address struct {
+			Country string `json:"country"`
+		} `json:"address"`
+	} `json:"billing_details"`
+
+	// For refunds, which are otherwise the same
+	Charge string `json:"charge"`
+}
+
+type balanceTransaction struct {
+	ID       string          `json:"id"`
+	Amount   int64           `json:"amount"`
+	Created  int64           `json:"created"`
+	Currency string          `json:"currency"`
+	Fee      int64           `json:"fee"`
+	Net      int64           `json:"net"`
+	Status   string          `json:"status"`
+	Type     string          `json:"type"`
+	Source   json.RawMessage `json:"source"`
+}
+
+type balanceTransactionList struct {
+	Data    []*balanceTransaction `json:"data"`
+	HasMore bool                  `json:"has_more"`
+}
+
+// GetTransactions gets the balance transactions created from start up to but
+// not including end, with the donor of each charge and refund, sorted by date.
+func (c *Client) GetTransactions(ctx context.Context, start, end time.Time) (Transactions, error) {
+	result := Transactions{}
+	// Refunds only have the ID of their charge, which may be much older
+	charges := map[string]*charge{}
+
+	query := url.Values{}
+	query.Set("created[gte]", strconv.FormatInt(start.Unix(), 10))
+	query.Set("created[lt]", strconv.FormatInt(end.Unix(), 10))
+	query.Set("limit", strconv.Itoa(pageSize))
+	query.Add("expand[]", "data.source")
+
+	for {
+		list := balanceTransactionList{}
+		if err := c.get(ctx, balanceTransactionsPath, query, &list); err != nil {
+			return nil, fmt.Errorf("could not get Stripe balance transactions: %w", err)
+		}
+
+		for _, bt := range list.Data {
+			t, err := c.transaction(ctx, bt, charges)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, t)
+		}
+
+		if !list.HasMore || len(list.Data) == 0 {
+			break
+		}
+		query.Set("starting_after", list.Data[len(list.Data)-1].ID)
+	}
+	result.Sort()
+
+	return result, nil
+}
+
+// transaction converts a balance transaction, getting the charge of a refund
+// if it has not been seen yet.
+func (c *Client) transaction(ctx context.Context, bt *balanceTransaction, charges map[string]*charge) (*Transaction, error) {
+	currency := strings.ToUpper(bt.Currency)
+	t := &Transaction{
+		ID:       bt.ID,
+		Created:  time.Unix(bt.Created, 0).UTC(),
+		Type:     bt.Type,
+		Status:   bt.Status,
+		Amount:   amount(bt.Amount, currency),
+		Fee:      -amount(bt.Fee, currency),
+		Net:      amount(bt.Net, currency),
+		Currency: currency,
+	}
+
+	// The source is an object when expanded, or else just its ID
+	source := &charge{}
+	if len(bt.Source) > 0 && bt.Source[0] == '{' {
+		if err := json.Unmarshal(bt.Source, source); err != nil {
+			return nil, fmt.Errorf("invalid source of Stripe balance transaction %s: %w", bt.ID, err)
+		}
+	}
+
+	var ch *charge
+	switch source.Object {
+	case "charge":
+		ch = source
+		charges[ch.ID] = ch
+	case "refund":
+		ch = charges[source.Charge]
+		if ch == nil && source.Charge != "" {
+			ch = &charge{}
+			if err := c.get(ctx, chargesPath+url.PathEscape(source.Charge), nil, ch); err != nil {
+				return nil, fmt.Errorf("could not get Stripe charge %s: %w", source.Charge, err)
+			}
+			charges[ch.ID] = ch
+		}
+	}
+
+	if ch != nil {
+		t.ChargeID = ch.ID
+		t.Name = ch.BillingDetails.Name
+		t.Email = ch.BillingDetails.Email
+		if t.Email == "" {
+			t.Email = ch.ReceiptEmail
+		}
+		t.CountryCode = ch.BillingDetails.Address.Country
+		t.Description = ch.Description
+		t.InvoiceID = ch.Invoice
+		if original := strings.ToUpper(ch.Currency); source.Object == "charge" && original != currency {
+			t.OriginalAmount = amount(ch.Amount, original)
+			t.OriginalCurrency = original
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,185 @@
+package stripe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// Test server
+//==============================================================================
+
+// testServer stands in for the Stripe API, serving the balance transactions
+// one per page so pagination is exercised.
+type testServer struct {
+	*httptest.Server
+	transactions []map[string]interface{}
+	charges      map[string]map[string]interface{}
+	listCalls    int
+	chargeCalls  int
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{charges: map[string]map[string]interface{}{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(balanceTransactionsPath, func(w http.ResponseWriter, r *http.Request) {
+		s.listCalls++
+		if r.Header.Get("Authorization") != "Bearer sk_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"type": "invalid_request_error", "message": "Invalid API Key provided"}}`))
+			return
+		}
+		q := r.URL.Query()
+		assert.Equal(t, "data.source", q.Get("expand[]"))
+		gte, _ := strconv.ParseInt(q.Get("created[gte]"), 10, 64)
+		lt, _ := strconv.ParseInt(q.Get("created[lt]"), 10, 64)
+
+		// Stripe lists the newest first
+		page := []map[string]interface{}{}
+		after := q.Get("starting_after")
+		for i := len(s.transactions) - 1; i >= 0; i-- {
+			bt := s.transactions[i]
+			created := bt["created"].(int64)
+			if created < gte || created >= lt {
+				continue
+			}
+			if after != "" {
+				if bt["id"] == after {
+					after = ""
+				}
+				continue
+			}
+			page = append(page, bt)
+		}
+		hasMore := len(page) > 1
+		if hasMore {
+			page = page[:1]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"object": "list", "data": page, "has_more": hasMore})
+	})
+	mux.HandleFunc(chargesPath, func(w http.ResponseWriter, r *http.Request) {
+		s.chargeCalls++
+		ch, found := s.charges[strings.TrimPrefix(r.URL.Path, chargesPath)]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"type": "invalid_request_error", "message": "No such charge"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(ch)
+	})
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+func testCharge(id, name, email string, amount int64, currency, invoice string) map[string]interface{} {
+	ch := map[string]interface{}{
+		"id":       id,
+		"object":   "charge",
+		"amount":   amount,
+		"currency": currency,
+		"billing_details": map[string]interface{}{
+			"name":    name,
+			"email":   email,
+			"address": map[string]interface{}{"country": "US"},
+		},
+	}
+	if invoice != "" {
+		ch["invoice"] = invoice
+	}
+	return ch
+}
+
+func march(day int) time.Time {
+	return time.Date(2020, time.March, day, 12, 0, 0, 0, time.UTC)
+}
+
+//==============================================================================
+// Client
+//==============================================================================
+
+func TestClientGetTransactions(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	s.charges["ch_old"] = testCharge("ch_old", "Selina Kyle", "cat@woman.com", 1000, "usd", "")
+	s.transactions = []map[string]interface{}{
+		{"id": "txn_1", "created": march(2).Unix(), "type": "charge", "status": "available", "currency": "usd",
+			"amount": 2500, "fee": 103, "net": 2397,
+			"source": testCharge("ch_1", "Bruce Wayne", "bruce@wayneenterprises.com", 2500, "usd", "")},
+		{"id": "txn_2", "created": march(3).Unix(), "type": "charge", "status": "pending", "currency": "usd",
+			"amount": 1070, "fee": 61, "net": 1009,
+			"source": testCharge("ch_2", "Diana Prince", "diana@themyscira.gr", 1000, "eur", "in_1")},
+		{"id": "txn_3", "created": march(4).Unix(), "type": "refund", "currency": "usd",
+			"amount": -1000, "fee": 0, "net": -1000,
+			"source": map[string]interface{}{"id": "re_1", "object": "refund", "charge": "ch_old"}},
+		{"id": "txn_4", "created": march(5).Unix(), "type": "payout", "currency": "usd",
+			"amount": -5000, "fee": 0, "net": -5000, "source": "po_1"},
+		// Outside of the dates
+		{"id": "txn_5", "created": march(20).Unix(), "type": "charge", "currency": "jpy",
+			"amount": 1000, "fee": 66, "net": 934, "source": "ch_5"},
+	}
+
+	client := NewClient(&Config{SecretKey: "sk_test", Endpoint: s.URL})
+	txns, err := client.GetTransactions(context.Background(), march(1), march(10))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, s.listCalls)
+	assert.Equal(t, 1, s.chargeCalls)
+	assert.Equal(t, 4, len(txns))
+
+	donation := txns[0]
+	assert.Equal(t, "txn_1", donation.ID)
+	assert.Equal(t, march(2), donation.Created)
+	assert.Equal(t, float32(25), donation.Amount)
+	assert.Equal(t, float32(-1.03), donation.Fee)
+	assert.Equal(t, float32(23.97), donation.Net)
+	assert.Equal(t, "USD", donation.Currency)
+	assert.Equal(t, "ch_1", donation.ChargeID)
+	assert.Equal(t, "bruce@wayneenterprises.com", donation.Email)
+	assert.Equal(t, "US", donation.CountryCode)
+	assert.True(t, donation.IsDonation())
+
+	// Converted by Stripe from EUR
+	subscription := txns[1]
+	assert.True(t, subscription.IsSubscription())
+	assert.Equal(t, float32(10.70), subscription.Amount)
+	assert.Equal(t, float32(10), subscription.OriginalAmount)
+	assert.Equal(t, "EUR", subscription.OriginalCurrency)
+
+	refund := txns[2]
+	assert.True(t, refund.IsReturn())
+	assert.Equal(t, "ch_old", refund.ChargeID)
+	assert.Equal(t, "cat@woman.com", refund.Email)
+
+	payout := txns[3]
+	assert.False(t, payout.IsDonation() || payout.IsSubscription() || payout.IsReturn())
+	assert.Equal(t, "", payout.ChargeID)
+}
+
+func TestClientReturnsErrors(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	client := NewClient(&Config{SecretKey: "sk_wrong", Endpoint: s.URL})
+	_, err := client.GetTransactions(context.Background(), march(1), march(10))
+
+	var stripeErr *Error
+	assert.True(t, errors.As(err, &stripeErr))
+	assert.True(t, stripeErr.IsAuthError())
+	assert.Equal(t, "Invalid API Key provided", stripeErr.Message)
+}
+
+func TestAmount(t *testing.T) {
+	assert.Equal(t, float32(12.34), amount(1234, "USD"))
+	assert.Equal(t, float32(1234), amount(1234, "JPY"))
+}
@@ -0,0 +1,19 @@
+package stripe
+
+// DefaultEndpoint is the Stripe API
+const DefaultEndpoint = "https://api.stripe.com"
+
+// DefaultTimeoutSeconds is used when the config has no timeout
+const DefaultTimeoutSeconds = 60
+
+// Config has the settings for getting Stripe transactions
+type Config struct {
+	// A secret or restricted key which can read balance transactions, charges
+	// and refunds
+	SecretKey string `json:"secret_key"`
+
+	// Only needed to use something besides the Stripe API, like a test server
+	Endpoint string `json:"endpoint,omitempty"`
+
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
@@ -0,0 +1,120 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// The start of the name of each month file, as in stripe-2020-01.json
+const filePrefix = "stripe"
+
+// now is the time, which is not the real time when replaying
+var now = time.Now
+
+// SetNow sets how the current time is found.
+func SetNow(f func() time.Time) {
+	now = f
+}
+
+// FileManager manages files containing Stripe transactions, one for each
+// month, in the data directory.
+type FileManager struct {
+	Year   int
+	Months map[int]Transactions
+	// When each saved month was fetched, which older files do not have
+	FetchedAt map[int]time.Time
+}
+
+// NewFileManager loads any Stripe files for the given year.
+func NewFileManager(year int) (*FileManager, error) {
+	result := NewEmptyFileManager(year)
+
+	saved := map[int]*monthJSON{}
+	err := util.LoadMonthFiles(util.DataDir(), filePrefix, year, func(month int) interface{} {
+		saved[month] = &monthJSON{}
+		return saved[month]
+	})
+	if err != nil {
+		return nil, err
+	}
+	for month, m := range saved {
+		result.Months[month] = m.Transactions
+		if !m.FetchedAt.IsZero() {
+			result.FetchedAt[month] = m.FetchedAt
+		}
+	}
+
+	return result, nil
+}
+
+// NewEmptyFileManager returns a file manager for the year without loading
+// anything, for saving months which are fetched again.
+func NewEmptyFileManager(year int) *FileManager {
+	return &FileManager{
+		Year:      year,
+		Months:    map[int]Transactions{},
+		FetchedAt: map[int]time.Time{},
+	}
+}
+
+// IsComplete returns whether the month was saved after it ended, so fetching
+// it again would not find anything new.
+func (p *FileManager) IsComplete(month int) bool {
+	fetchedAt, found := p.FetchedAt[month]
+
+	return found && fetchedAt.After(util.MonthEnd(p.Year, time.Month(month)))
+}
+
+// GetExistingMonths returns the months which have been saved.
+func (p *FileManager) GetExistingMonths() []int {
+	result := []int{}
+
+	for i := 1; i <= 12; i++ {
+		if _, found := p.Months[i]; found {
+			result = append(result, i)
+		}
+	}
+
+	return result
+}
+
+// SaveMonth saves the transactions of the month, which were just fetched, to
+// its file.
+func (p *FileManager) SaveMonth(month int, txns Transactions) error {
+	fetchedAt := now().UTC().Truncate(time.Second)
+	saved := &monthJSON{Transactions: txns, FetchedAt: fetchedAt}
+	if err := util.SaveMonthFile(util.DataDir(), filePrefix, p.Year, month, saved); err != nil {
+		return err
+	}
+
+	p.Months[month] = txns
+	p.FetchedAt[month] = fetchedAt
+
+	return nil
+}
+
+// GetAndSaveMonth gets all the transactions of the month from Stripe and saves
+// them, replacing what was saved before.
+func GetAndSaveMonth(ctx context.Context, client *Client, year, month int, fm *FileManager) error {
+	monthStr := util.Colorize(util.Green, fmt.Sprintf("%s %d", time.Month(month), year))
+	fmt.Printf("Fetching Stripe transactions for %s...", monthStr)
+
+	start := util.MonthStart(year, time.Month(month))
+	txns, err := client.GetTransactions(ctx, start, util.MonthStart(year, time.Month(month)+1))
+	if err != nil {
+		fmt.Println("failed.")
+		return fmt.Errorf("could not get Stripe transactions for %s %d: %w", time.Month(month), year, err)
+	}
+	fmt.Printf("got %d.\n", len(txns))
+
+	return fm.SaveMonth(month, txns)
+}
+
+// monthJSON is what is saved in each file
+type monthJSON struct {
+	Transactions Transactions `json:"transactions"`
+	FetchedAt    time.Time    `json:"fetched_at"`
+}
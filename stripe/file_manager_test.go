@@ -0,0 +1,45 @@
+package stripe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leavengood/donation_tracker/util"
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// FileManager
+//==============================================================================
+
+func TestFileManagerIsComplete(t *testing.T) {
+	defer util.SetDataDir(util.DataDir())
+	util.SetDataDir(t.TempDir())
+	defer SetNow(time.Now)
+
+	fm := NewEmptyFileManager(2020)
+	txns := Transactions{{ID: "txn_1", Created: march(2), Type: TypeCharge, Amount: 25, Currency: "USD"}}
+	// Saved part way through March, and again after it ended
+	SetNow(func() time.Time { return march(20) })
+	assert.Nil(t, fm.SaveMonth(3, txns))
+	assert.False(t, fm.IsComplete(3))
+	SetNow(func() time.Time { return march(20).AddDate(0, 1, 0) })
+	assert.Nil(t, fm.SaveMonth(2, Transactions{}))
+	assert.True(t, fm.IsComplete(2))
+	assert.False(t, fm.IsComplete(4))
+
+	loaded, err := NewFileManager(2020)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2, 3}, loaded.GetExistingMonths())
+	assert.Equal(t, "txn_1", loaded.Months[3][0].ID)
+	assert.False(t, loaded.IsComplete(3))
+	assert.True(t, loaded.IsComplete(2))
+
+	// Files from before the time was saved are not known to be complete
+	assert.Nil(t, util.WriteJSONFile(util.MonthFileName(util.DataDir(), filePrefix, 2020, 1),
+		map[string]Transactions{"transactions": txns}))
+	loaded, err = NewFileManager(2020)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(loaded.Months[1]))
+	assert.False(t, loaded.IsComplete(1))
+}
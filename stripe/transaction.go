@@ -0,0 +1,114 @@
+package stripe
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// Balance transaction types which are donations or give them back
+const (
+	TypeCharge        = "charge"
+	TypePayment       = "payment"
+	TypeRefund        = "refund"
+	TypePaymentRefund = "payment_refund"
+)
+
+// Transaction is a Stripe balance transaction, along with the donor from the
+// charge it is for. The amounts are in the currency the money was settled
+// in, so what was actually received.
+type Transaction struct {
+	// The balance transaction ID, starting with txn_
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Type    string    `json:"type"`
+	Status  string    `json:"status,omitempty"`
+	Amount  float32   `json:"amount"`
+	// Negative, like PayPal fees
+	Fee      float32 `json:"fee,omitempty"`
+	Net      float32 `json:"net"`
+	Currency string  `json:"currency"`
+
+	// The charge this is for, or which it refunds
+	ChargeID    string `json:"charge_id,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Email       string `json:"email,omitempty"`
+	CountryCode string `json:"country_code,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Set for charges of a subscription
+	InvoiceID string `json:"invoice_id,omitempty"`
+
+	// What the donor paid, when Stripe converted it to another currency
+	OriginalAmount   float32 `json:"original_amount,omitempty"`
+	OriginalCurrency string  `json:"original_currency,omitempty"`
+}
+
+// IsDonation is true for one-time charges.
+func (t *Transaction) IsDonation() bool {
+	return (t.Type == TypeCharge || t.Type == TypePayment) && t.Amount > 0 && t.InvoiceID == ""
+}
+
+// IsSubscription is true for charges of a subscription invoice.
+func (t *Transaction) IsSubscription() bool {
+	return (t.Type == TypeCharge || t.Type == TypePayment) && t.Amount > 0 && t.InvoiceID != ""
+}
+
+// IsReturn is true for refunds of charges.
+func (t *Transaction) IsReturn() bool {
+	return (t.Type == TypeRefund || t.Type == TypePaymentRefund) && t.Amount < 0
+}
+
+func (t *Transaction) String() string {
+	return fmt.Sprintf("%s: %s <%s> Stripe %s, %s %0.02f (%0.02f fee) = %0.02f", util.FormatDateTime(t.Created),
+		t.Name, t.Email, t.Type, t.Currency, t.Amount, t.Fee, t.Net)
+}
+
+type Transactions []*Transaction
+
+// Sort sorts the transactions by date.
+func (p Transactions) Sort() {
+	sort.SliceStable(p, func(i, j int) bool {
+		return p[i].Created.Before(p[j].Created)
+	})
+}
+
+// Merge adds the other transactions which are not already in these.
+func (p Transactions) Merge(other Transactions) Transactions {
+	result := make(Transactions, 0, len(p)+len(other))
+	ids := map[string]bool{}
+
+	for _, t := range p {
+		ids[t.ID] = true
+		result = append(result, t)
+	}
+	for _, t := range other {
+		if !ids[t.ID] {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
+// Summarize adds the donations and refunds to the summary of their month,
+// leaving out payouts and other transactions.
+func (p Transactions) Summarize() util.MonthlySummaries {
+	result := make(util.MonthlySummaries)
+
+	for _, t := range p {
+		_, month := util.MonthOf(t.Created)
+
+		switch {
+		case t.IsDonation():
+			result.ForMonth(month).AddOneTime(t.Amount, t.Fee, t.Currency)
+		case t.IsSubscription():
+			result.ForMonth(month).AddSubscription(t.Amount, t.Fee, t.Currency)
+		case t.IsReturn():
+			result.ForMonth(month).AddReturn(t.Amount, t.Fee, t.Currency)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,45 @@
+package stripe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// Transactions
+//==============================================================================
+
+func TestSummarize(t *testing.T) {
+	txns := Transactions{
+		{ID: "txn_1", Created: march(2), Type: TypeCharge, Amount: 25, Fee: -1.03, Currency: "USD"},
+		{ID: "txn_2", Created: march(3), Type: TypeCharge, Amount: 10, Fee: -0.59, Currency: "USD", InvoiceID: "in_1"},
+		{ID: "txn_3", Created: march(4), Type: TypeRefund, Amount: -25, Currency: "USD"},
+		{ID: "txn_4", Created: march(5), Type: "payout", Amount: -100, Currency: "USD"},
+		{ID: "txn_5", Created: march(5).AddDate(0, 1, 0), Type: TypePayment, Amount: 20, Fee: -0.88, Currency: "EUR"},
+	}
+
+	sums := txns.Summarize()
+	assert.Equal(t, 2, len(sums))
+
+	m := sums[time.March]
+	assert.Equal(t, 1, m.OneTimeCount)
+	assert.Equal(t, float32(25), m.OneTimeAmt["USD"])
+	assert.Equal(t, 1, m.SubscriptionCount)
+	assert.Equal(t, float32(10), m.SubscriptionAmt["USD"])
+	assert.Equal(t, 1, m.ReturnedCount)
+	assert.Equal(t, float32(10), m.GrossTotal()["USD"])
+	assert.InDelta(t, 8.38, m.NetTotal()["USD"], 0.001)
+
+	assert.Equal(t, float32(20), sums[time.April].OneTimeAmt["EUR"])
+}
+
+func TestMerge(t *testing.T) {
+	saved := Transactions{{ID: "txn_1", Amount: 25}}
+	merged := saved.Merge(Transactions{{ID: "txn_1", Amount: 30}, {ID: "txn_2", Amount: 10}})
+
+	assert.Equal(t, 2, len(merged))
+	assert.Equal(t, float32(25), merged[0].Amount)
+	assert.Equal(t, "txn_2", merged[1].ID)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/leavengood/donation_tracker/paypal"
+	"github.com/leavengood/donation_tracker/util"
+)
+
+// firstYear is the earliest year the tracker has data for
+const firstYear = 2010
+
+// loadTransactionsThrough loads the saved transactions of the accounts for
+// every year up to and including the given one, sorted by date. Subscriptions
+// can be many years old, so their whole history is needed.
+func loadTransactionsThrough(accounts []*payPalAccount, year int) (paypal.Transactions, error) {
+	result := paypal.Transactions{}
+
+	for y := firstYear; y <= year; y++ {
+		fm, _, err := loadYear(accounts, y)
+		if err != nil {
+			return nil, err
+		}
+		for _, month := range fm.GetExistingMonths() {
+			result = append(result, fm.Months[month]...)
+		}
+	}
+	result.Sort()
+
+	return result, nil
+}
+
+// printSubscriptions lists the subscriptions which were active or cancelled
+// from start to end.
+func printSubscriptions(accounts []*payPalAccount, start, end time.Time) error {
+	txns, err := loadTransactionsThrough(accounts, end.Year())
+	if err != nil {
+		return err
+	}
+
+	active, cancelled := paypal.TrackSubscriptions(txns).During(start, end)
+
+	printSub := func(sub *paypal.Subscription) {
+		lastPayment := "never"
+		if !sub.LastPayment.IsZero() {
+			lastPayment = util.FormatDate(sub.LastPayment)
+		}
+		fmt.Printf("  %s: %s %0.02f since %s, %d payments, last on %s [%s]\n",
+			util.Colorize(util.Yellow, fmt.Sprintf("%s <%s>", sub.Name, sub.Email)),
+			sub.CurrencyCode, sub.Amt, util.FormatDate(sub.Start), sub.Payments, lastPayment, sub.ProfileID)
+	}
+
+	fmt.Printf("There were %d active subscriptions from %s to %s:\n",
+		len(active), util.FormatDate(start), util.FormatDate(end))
+	totals := util.CurrencyAmounts{}
+	for _, sub := range active {
+		printSub(sub)
+		if status := sub.StatusAt(end); status != paypal.StatusActive {
+			fmt.Printf("      %s\n", util.Colorize(util.BrightYellow, status))
+		}
+		totals[sub.CurrencyCode] += sub.MonthlyAmt()
+	}
+	fmt.Printf("Monthly total of active subscriptions: %s\n\n", totals)
+
+	fmt.Printf("There were %d cancelled or lapsed subscriptions:\n", len(cancelled))
+	for _, sub := range cancelled {
+		printSub(sub)
+		if sub.StatusAt(end) == paypal.StatusLapsed {
+			fmt.Printf("      %s\n", util.Colorize(util.Red, "Lapsed on "+util.FormatDate(sub.LapsedAt())+
+				", with no payment since the last one"))
+		} else {
+			fmt.Printf("      %s\n", util.Colorize(util.Red, "Cancelled on "+util.FormatDate(sub.CancelledAt())))
+		}
+	}
+
+	return nil
+}
@@ -13,6 +13,29 @@ type Donor struct {
 	Total     CurrencyAmounts
 	Count     int
 	Anonymous bool
+
+	// From the transaction details, when they are known
+	CountryCode string
+	Notes       []string
+
+	// Where they donated, like PayPal or Open Collective
+	Sources []string
+}
+
+// AddSource records that the donor gave through the source, if that is not
+// already known.
+func (d *Donor) AddSource(source string) {
+	for _, s := range d.Sources {
+		if s == source {
+			return
+		}
+	}
+	d.Sources = append(d.Sources, source)
+}
+
+// OnlyThrough is true if the donor only gave through the source.
+func (d *Donor) OnlyThrough(source string) bool {
+	return len(d.Sources) == 1 && d.Sources[0] == source
 }
 
 // TODO: Implement String()
@@ -0,0 +1,171 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The suffix of the temporary files written before being renamed into place
+const tempSuffix = ".tmp"
+
+// dataDir is where everything fetched and imported is saved
+var dataDir = "data"
+
+// SetDataDir sets where everything fetched and imported is saved, such as a
+// scratch copy of the data which can be changed freely.
+func SetDataDir(dir string) {
+	dataDir = dir
+}
+
+// DataDir returns where everything fetched and imported is saved.
+func DataDir() string {
+	return dataDir
+}
+
+// WriteFileAtomic writes a file by calling write with a temporary file in the
+// same directory, which is synced to disk and then renamed over the file. A
+// crash part way through leaves the old file as it was, along with the
+// temporary file.
+func WriteFileAtomic(filename string, write func(w io.Writer) error) error {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, "."+base+".*"+tempSuffix)
+	if err != nil {
+		return err
+	}
+	// Nothing is left behind when anything fails
+	tempName := f.Name()
+	defer os.Remove(tempName)
+
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write %s: %w", filename, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write %s: %w", filename, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not write %s: %w", filename, err)
+	}
+	if err := os.Chmod(tempName, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempName, filename); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// syncDir makes sure a rename in the directory is on disk. Not every system
+// can sync a directory, so that is not an error.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	d.Sync()
+	return nil
+}
+
+// WriteJSONFile atomically writes the value to the file as indented JSON.
+func WriteJSONFile(filename string, v interface{}) error {
+	return WriteFileAtomic(filename, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	})
+}
+
+// isTempFile returns whether the name is of a file WriteFileAtomic writes
+// before renaming it.
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, tempSuffix)
+}
+
+// RemoveTempFiles removes the temporary files left in the directory, or any
+// directory inside it, by writes which never finished, returning their names.
+// The files they were replacing are still as they were before. The directory
+// should be locked so no write is going on.
+func RemoveTempFiles(dir string) ([]string, error) {
+	removed := []string{}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isTempFile(info.Name()) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed = append(removed, path)
+		return nil
+	})
+
+	return removed, err
+}
+
+// CopyDir copies the files in the directory, and any directory inside it, to
+// another directory, which is created if needed. The lock and any temporary
+// files are left out. Nothing is copied when there is no directory to copy.
+func CopyDir(src, dst string) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == src {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if info.Name() == lockFile || isTempFile(info.Name()) {
+			return nil
+		}
+
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("could not copy %s: %w", src, err)
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,147 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// WriteFileAtomic
+//==============================================================================
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "paypal-2020-01.json")
+
+	assert.Nil(t, WriteJSONFile(filename, []string{"first"}))
+	content, err := ioutil.ReadFile(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, "[\n  \"first\"\n]\n", string(content))
+
+	// A failed write leaves the file as it was, with nothing else left behind
+	err = WriteFileAtomic(filename, func(w io.Writer) error {
+		io.WriteString(w, "[\n  \"sec")
+		return errors.New("disk full")
+	})
+	assert.Contains(t, err.Error(), "disk full")
+	content, err = ioutil.ReadFile(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, "[\n  \"first\"\n]\n", string(content))
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+	assert.Equal(t, os.FileMode(0644), files[0].Mode().Perm())
+}
+
+//==============================================================================
+// RemoveTempFiles
+//==============================================================================
+
+func TestRemoveTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "europe"), 0755))
+	for _, name := range []string{"paypal-2020-01.json", ".paypal-2020-01.json.123.tmp",
+		"europe/.paypal-2020-02.json.456.tmp", ".lock"} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("{"), 0644))
+	}
+
+	removed, err := RemoveTempFiles(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, ".paypal-2020-01.json.123.tmp"),
+		filepath.Join(dir, "europe", ".paypal-2020-02.json.456.tmp"),
+	}, removed)
+	_, err = os.Stat(filepath.Join(dir, "paypal-2020-01.json"))
+	assert.Nil(t, err)
+
+	removed, err = RemoveTempFiles(filepath.Join(dir, "missing"))
+	assert.Nil(t, err)
+	assert.Empty(t, removed)
+}
+
+//==============================================================================
+// CopyDir
+//==============================================================================
+
+func TestCopyDir(t *testing.T) {
+	src := t.TempDir()
+	assert.Nil(t, os.Mkdir(filepath.Join(src, "europe"), 0755))
+	for _, name := range []string{"paypal-2020-01.json", "europe/paypal-2020-02.json",
+		".paypal-2020-03.json.123.tmp", ".lock"} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(src, name), []byte(name), 0644))
+	}
+
+	dst := filepath.Join(t.TempDir(), "data")
+	assert.Nil(t, CopyDir(src, dst))
+	content, err := ioutil.ReadFile(filepath.Join(dst, "europe", "paypal-2020-02.json"))
+	assert.Nil(t, err)
+	assert.Equal(t, "europe/paypal-2020-02.json", string(content))
+	// The lock and partly written files are left out
+	files, err := ioutil.ReadDir(dst)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(files))
+
+	// There may be no data yet
+	empty := filepath.Join(t.TempDir(), "empty")
+	assert.Nil(t, CopyDir(filepath.Join(src, "missing"), empty))
+	files, err = ioutil.ReadDir(empty)
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+}
+
+//==============================================================================
+// LockDir
+//==============================================================================
+
+func TestLockDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	lock, err := LockDir(context.Background(), dir, 0)
+	assert.Nil(t, err)
+
+	// Someone else waits and then gives up
+	_, err = LockDir(context.Background(), dir, 300*time.Millisecond)
+	assert.Contains(t, err.Error(), "is being used by another command (process")
+
+	// Or gets it once it is let go
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		lock.Unlock()
+	}()
+	next, err := LockDir(context.Background(), dir, time.Minute)
+	assert.Nil(t, err)
+	assert.Nil(t, next.Unlock())
+}
+
+func TestLockDirShared(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	first, err := LockDirShared(context.Background(), dir, 0)
+	assert.Nil(t, err)
+
+	// Readers share the lock
+	second, err := LockDirShared(context.Background(), dir, 0)
+	assert.Nil(t, err)
+
+	// But a writer waits for all of them
+	_, err = LockDir(context.Background(), dir, 300*time.Millisecond)
+	assert.Contains(t, err.Error(), "is being used by another command")
+	assert.Nil(t, first.Unlock())
+	_, err = LockDir(context.Background(), dir, 300*time.Millisecond)
+	assert.Contains(t, err.Error(), "is being used by another command")
+	assert.Nil(t, second.Unlock())
+
+	lock, err := LockDir(context.Background(), dir, 0)
+	assert.Nil(t, err)
+
+	// And readers wait for the writer
+	_, err = LockDirShared(context.Background(), dir, 300*time.Millisecond)
+	assert.Contains(t, err.Error(), "is being used by another command")
+	assert.Nil(t, lock.Unlock())
+}
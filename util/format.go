@@ -4,13 +4,15 @@ import "time"
 
 const (
 	dateFormat     = "Jan 2, 2006"
-	dateTimeFormat = "Jan 2, 2006 3:05 pm UTC"
+	dateTimeFormat = "Jan 2, 2006 3:04 pm MST"
 )
 
+// FormatDate formats the date in the organization's time zone.
 func FormatDate(t time.Time) string {
-	return t.Format(dateFormat)
+	return InLocation(t).Format(dateFormat)
 }
 
+// FormatDateTime formats the date and time in the organization's time zone.
 func FormatDateTime(t time.Time) string {
-	return t.Format(dateTimeFormat)
+	return InLocation(t).Format(dateTimeFormat)
 }
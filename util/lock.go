@@ -0,0 +1,107 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const lockFile = ".lock"
+
+// How often a lock held by someone else is tried again
+var lockRetryInterval = 200 * time.Millisecond
+
+// errWouldBlock is returned by tryLock when someone else has the lock
+var errWouldBlock = errors.New("the lock is held")
+
+// DirLock is an advisory lock on a directory, so only one command at a time
+// changes it. The lock is let go when the program exits, however it exits.
+type DirLock struct {
+	f *os.File
+}
+
+// LockDir locks the directory for a command which changes it, creating it if
+// needed. When another program has it locked, this waits up to wait for it to
+// finish before failing.
+func LockDir(ctx context.Context, dir string, wait time.Duration) (*DirLock, error) {
+	return lockDir(ctx, dir, wait, false)
+}
+
+// LockDirShared locks the directory for a command which only reads it. Any
+// number of these share the lock, but not with a command which changes it.
+func LockDirShared(ctx context.Context, dir string, wait time.Duration) (*DirLock, error) {
+	return lockDir(ctx, dir, wait, true)
+}
+
+func lockDir(ctx context.Context, dir string, wait time.Duration, shared bool) (*DirLock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	filename := filepath.Join(dir, lockFile)
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	waiting := false
+	for {
+		err := tryLock(f, shared)
+		if err == nil {
+			break
+		}
+		if err != errWouldBlock {
+			f.Close()
+			return nil, fmt.Errorf("could not lock %s: %w", dir, err)
+		}
+		if !time.Now().Before(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%s is being used by another command%s", dir, lockHolder(filename))
+		}
+		if !waiting {
+			fmt.Printf("Waiting for another command%s using %s to finish...\n", lockHolder(filename), dir)
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	// Say who has the lock, for anyone waiting for it
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return &DirLock{f: f}, nil
+}
+
+// lockHolder returns who has the lock, as part of a message
+func lockHolder(filename string) string {
+	content, err := ioutil.ReadFile(filename)
+	pid := strings.TrimSpace(string(content))
+	if err != nil || pid == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" (process %s)", pid)
+}
+
+// Unlock lets go of the lock.
+func (l *DirLock) Unlock() error {
+	if err := unlock(l.f); err != nil {
+		l.f.Close()
+		return err
+	}
+
+	return l.f.Close()
+}
@@ -0,0 +1,31 @@
+package util
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Locks on Windows stop others reading and writing the locked bytes, so a
+// byte far past the end of the file is locked, leaving the process ID in the
+// file readable.
+func lockedRange() *windows.Overlapped {
+	return &windows.Overlapped{OffsetHigh: 1}
+}
+
+func tryLock(f *os.File, shared bool) error {
+	flags := uint32(windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if !shared {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, lockedRange())
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return errWouldBlock
+	}
+
+	return err
+}
+
+func unlock(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, lockedRange())
+}
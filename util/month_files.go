@@ -0,0 +1,111 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// MonthFileName returns the name of the JSON file of the month in the
+// directory, such as paypal-2020-01.json for the prefix paypal.
+func MonthFileName(dir, prefix string, year, month int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s-%d-%02d.json", prefix, year, month))
+}
+
+func monthFileRegexp(prefix, year string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(`^%s-(%s)-([0-9]{2})\.json$`, regexp.QuoteMeta(prefix), year))
+}
+
+// LoadMonthFiles decodes the file of each saved month of the year in the
+// directory, but not its subdirectories, into what newMonth returns for that
+// month. A missing directory has no months.
+func LoadMonthFiles(dir, prefix string, year int, newMonth func(month int) interface{}) error {
+	re := monthFileRegexp(prefix, strconv.Itoa(year))
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, info := range files {
+		match := re.FindStringSubmatch(info.Name())
+		if info.IsDir() || match == nil {
+			continue
+		}
+		month, err := strconv.Atoi(match[2])
+		if err != nil {
+			return err
+		}
+		if err := loadJSONFile(filepath.Join(dir, info.Name()), newMonth(month)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func loadJSONFile(filename string, v interface{}) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("could not load %s: %w", filename, err)
+	}
+
+	return nil
+}
+
+// SaveMonthFile replaces the file of the month in the directory with the
+// value as JSON, creating the directory if needed.
+func SaveMonthFile(dir, prefix string, year, month int, v interface{}) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return WriteJSONFile(MonthFileName(dir, prefix, year, month), v)
+}
+
+// MonthFileYears returns the years with any saved months in the directory, in
+// order.
+func MonthFileYears(dir, prefix string) ([]int, error) {
+	result := []int{}
+	re := monthFileRegexp(prefix, "[0-9]{4}")
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[int]bool{}
+	for _, info := range files {
+		match := re.FindStringSubmatch(info.Name())
+		if info.IsDir() || match == nil {
+			continue
+		}
+		year, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, err
+		}
+		if !found[year] {
+			found[year] = true
+			result = append(result, year)
+		}
+	}
+	sort.Ints(result)
+
+	return result, nil
+}
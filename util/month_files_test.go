@@ -0,0 +1,58 @@
+package util
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//==============================================================================
+// Month files
+//==============================================================================
+
+type testMonth struct {
+	Count int `json:"count"`
+}
+
+func TestMonthFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, SaveMonthFile(dir, "paypal", 2020, 1, &testMonth{Count: 1}))
+	assert.Nil(t, SaveMonthFile(dir, "paypal", 2020, 11, &testMonth{Count: 11}))
+	assert.Nil(t, SaveMonthFile(dir, "paypal", 2018, 3, &testMonth{Count: 3}))
+	// Other prefixes and subdirectories are left out
+	assert.Nil(t, SaveMonthFile(dir, "stripe", 2020, 2, &testMonth{Count: 2}))
+	assert.Nil(t, SaveMonthFile(filepath.Join(dir, "europe"), "paypal", 2019, 2, &testMonth{Count: 2}))
+	assert.Equal(t, filepath.Join(dir, "paypal-2020-01.json"), MonthFileName(dir, "paypal", 2020, 1))
+
+	months := map[int]*testMonth{}
+	err := LoadMonthFiles(dir, "paypal", 2020, func(month int) interface{} {
+		months[month] = &testMonth{}
+		return months[month]
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]*testMonth{1: {Count: 1}, 11: {Count: 11}}, months)
+
+	years, err := MonthFileYears(dir, "paypal")
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2018, 2020}, years)
+
+	// A missing directory has no months
+	missing := filepath.Join(dir, "missing")
+	err = LoadMonthFiles(missing, "paypal", 2020, func(month int) interface{} {
+		t.Errorf("month %d should not be loaded", month)
+		return nil
+	})
+	assert.Nil(t, err)
+	years, err = MonthFileYears(missing, "paypal")
+	assert.Nil(t, err)
+	assert.Empty(t, years)
+
+	// A broken file says which it is
+	assert.Nil(t, ioutil.WriteFile(MonthFileName(dir, "paypal", 2020, 5), []byte("{"), 0644))
+	err = LoadMonthFiles(dir, "paypal", 2020, func(month int) interface{} {
+		return &testMonth{}
+	})
+	assert.Contains(t, err.Error(), "could not load "+MonthFileName(dir, "paypal", 2020, 5))
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"time"
 )
@@ -13,6 +14,16 @@ type Summary struct {
 	SubscriptionAmt   CurrencyAmounts
 	SubscriptionCount int
 	FeeAmt            CurrencyAmounts
+	// Refunds, reversals and chargebacks, which are negative
+	ReturnedAmt   CurrencyAmounts
+	ReturnedCount int
+	// Donations converted by PayPal, taken out of one currency, which is
+	// negative, and put into another
+	ConvertedFrom CurrencyAmounts
+	ConvertedTo   CurrencyAmounts
+	// Donations which are still pending, which are not in any total
+	PendingAmt   CurrencyAmounts
+	PendingCount int
 }
 
 func NewSummary() *Summary {
@@ -21,28 +32,43 @@ func NewSummary() *Summary {
 	result.OneTimeAmt = make(CurrencyAmounts)
 	result.SubscriptionAmt = make(CurrencyAmounts)
 	result.FeeAmt = make(CurrencyAmounts)
+	result.ReturnedAmt = make(CurrencyAmounts)
+	result.ConvertedFrom = make(CurrencyAmounts)
+	result.ConvertedTo = make(CurrencyAmounts)
+	result.PendingAmt = make(CurrencyAmounts)
 
 	return result
 }
 
-// func (s1 *Summary) Add(s2 *Summary) *Summary {
-
-// result := NewSummary()
-
-// for _, summary := range ms {
-//   result.OneTimeAmt.Add(summary.OneTimeAmt)
-//   result.OneTimeCount += summary.OneTimeCount
-//   result.SubscriptionAmt.Add(summary.SubscriptionAmt)
-//   result.SubscriptionCount += summary.SubscriptionCount
-//   result.FeeAmt.Add(summary.FeeAmt)
-// }
-
-// return result
-// }
+// Add adds everything in the other summary to this one.
+func (s *Summary) Add(other *Summary) {
+	s.OneTimeAmt = s.OneTimeAmt.Add(other.OneTimeAmt)
+	s.OneTimeCount += other.OneTimeCount
+	s.SubscriptionAmt = s.SubscriptionAmt.Add(other.SubscriptionAmt)
+	s.SubscriptionCount += other.SubscriptionCount
+	s.FeeAmt = s.FeeAmt.Add(other.FeeAmt)
+	s.ReturnedAmt = s.ReturnedAmt.Add(other.ReturnedAmt)
+	s.ReturnedCount += other.ReturnedCount
+	s.ConvertedFrom = s.ConvertedFrom.Add(other.ConvertedFrom)
+	s.ConvertedTo = s.ConvertedTo.Add(other.ConvertedTo)
+	s.PendingAmt = s.PendingAmt.Add(other.PendingAmt)
+	s.PendingCount += other.PendingCount
+}
 
 func (s *Summary) String() string {
-	return fmt.Sprintf("OneTime: %s (%d), Subscriptions: %s (%d), Fees: %s",
+	result := fmt.Sprintf("OneTime: %s (%d), Subscriptions: %s (%d), Fees: %s",
 		s.OneTimeAmt, s.OneTimeCount, s.SubscriptionAmt, s.SubscriptionCount, s.FeeAmt)
+	if s.ReturnedCount > 0 {
+		result += fmt.Sprintf(", Returned: %s (%d)", s.ReturnedAmt, s.ReturnedCount)
+	}
+	if len(s.ConvertedFrom) > 0 {
+		result += fmt.Sprintf(", Converted: %s to %s", s.ConvertedFrom, s.ConvertedTo)
+	}
+	if s.PendingCount > 0 {
+		result += fmt.Sprintf(", Pending: %s (%d)", s.PendingAmt, s.PendingCount)
+	}
+
+	return result
 }
 
 func (s *Summary) AddOneTime(amt, fee float32, currency string) {
@@ -57,8 +83,56 @@ func (s *Summary) AddSubscription(amt, fee float32, currency string) {
 	s.FeeAmt[currency] += fee
 }
 
+// AddReturn adds a refund, reversal or chargeback. The amount is negative and
+// the fee is whatever part of the original fee was given back.
+func (s *Summary) AddReturn(amt, fee float32, currency string) {
+	if s.ReturnedAmt == nil {
+		s.ReturnedAmt = make(CurrencyAmounts)
+	}
+	s.ReturnedCount += 1
+	s.ReturnedAmt[currency] += amt
+	s.FeeAmt[currency] += fee
+}
+
+// AddFee adds a fee which is not part of a donation, such as one Open
+// Collective lists separately. The fee is negative.
+func (s *Summary) AddFee(fee float32, currency string) {
+	s.FeeAmt[currency] += fee
+}
+
+// AddPending adds a donation which is still pending. It is kept apart until
+// it completes.
+func (s *Summary) AddPending(amt float32, currency string) {
+	if s.PendingAmt == nil {
+		s.PendingAmt = make(CurrencyAmounts)
+	}
+	s.PendingCount += 1
+	s.PendingAmt[currency] += amt
+}
+
+// AddConversion adds one side of a currency conversion of a donation, which
+// is negative in the currency converted from.
+func (s *Summary) AddConversion(amt float32, currency string) {
+	if s.ConvertedFrom == nil {
+		s.ConvertedFrom = make(CurrencyAmounts)
+		s.ConvertedTo = make(CurrencyAmounts)
+	}
+	if amt < 0 {
+		s.ConvertedFrom[currency] += amt
+	} else {
+		s.ConvertedTo[currency] += amt
+	}
+}
+
+// Unconverted is what is left of the donations, after fees and returns, in
+// each currency they were received in.
+func (s *Summary) Unconverted() CurrencyAmounts {
+	return s.NetTotal().Add(s.ConvertedFrom)
+}
+
+// GrossTotal is the donations received less anything returned, before fees.
 func (s *Summary) GrossTotal() CurrencyAmounts {
-	return s.OneTimeAmt.Add(s.SubscriptionAmt)
+	return s.OneTimeAmt.Add(s.SubscriptionAmt).Add(s.ReturnedAmt)
 }
 
 func (s *Summary) NetTotal() CurrencyAmounts {
@@ -104,12 +178,7 @@ func (ms MonthlySummaries) Total() *Summary {
 	result := NewSummary()
 
 	for _, summary := range ms {
-		// FIXME: This sucks
-		result.OneTimeAmt = result.OneTimeAmt.Add(summary.OneTimeAmt)
-		result.OneTimeCount += summary.OneTimeCount
-		result.SubscriptionAmt = result.SubscriptionAmt.Add(summary.SubscriptionAmt)
-		result.SubscriptionCount += summary.SubscriptionCount
-		result.FeeAmt = result.FeeAmt.Add(summary.FeeAmt)
+		result.Add(summary)
 	}
 
 	return result
@@ -155,19 +224,9 @@ func StringToMonth(s string) (time.Month, error) {
 func (ms MonthlySummaries) Save(prefix string) error {
 	name := fmt.Sprintf("%s.json", prefix)
 
-	f, err := os.Create(name)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	e := json.NewEncoder(f)
-	err = e.Encode(ms)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return WriteFileAtomic(name, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(ms)
+	})
 }
 
 // TODO: Not needed now?
@@ -0,0 +1,50 @@
+package util
+
+import "time"
+
+// location is the time zone the organization's books are kept in, which
+// decides which month a transaction belongs to.
+var location = time.UTC
+
+// SetLocation sets the time zone the organization's books are kept in. This
+// should be done before anything else, since it changes month boundaries.
+func SetLocation(loc *time.Location) {
+	location = loc
+}
+
+// Location returns the time zone the organization's books are kept in,
+// defaulting to UTC.
+func Location() *time.Location {
+	return location
+}
+
+// InLocation returns the time in the organization's time zone.
+func InLocation(t time.Time) time.Time {
+	return t.In(location)
+}
+
+// MonthStart returns the first moment of the month in the organization's time
+// zone. Months past December go into the next year.
+func MonthStart(year int, month time.Month) time.Time {
+	return time.Date(year, month, 1, 0, 0, 0, 0, location)
+}
+
+// MonthEnd returns the last second of the month in the organization's time
+// zone.
+func MonthEnd(year int, month time.Month) time.Time {
+	return MonthStart(year, month+1).Add(-time.Second)
+}
+
+// DayStart returns the first moment of the day of the time in the
+// organization's time zone.
+func DayStart(t time.Time) time.Time {
+	year, month, day := InLocation(t).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, location)
+}
+
+// MonthOf returns the year and month the time belongs to in the
+// organization's time zone.
+func MonthOf(t time.Time) (int, time.Month) {
+	year, month, _ := InLocation(t).Date()
+	return year, month
+}
@@ -0,0 +1,32 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonthBoundsInLocation(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	SetLocation(ny)
+	defer SetLocation(time.UTC)
+
+	assert.Equal(t, time.Date(2020, time.February, 1, 5, 0, 0, 0, time.UTC), MonthStart(2020, time.February).UTC())
+	assert.Equal(t, time.Date(2020, time.March, 1, 4, 59, 59, 0, time.UTC), MonthEnd(2020, time.February).UTC())
+	assert.Equal(t, time.Date(2021, time.January, 1, 4, 59, 59, 0, time.UTC), MonthEnd(2020, time.December).UTC())
+
+	// Late in the evening of the last day of January in New York
+	year, month := MonthOf(time.Date(2020, time.February, 1, 3, 30, 0, 0, time.UTC))
+	assert.Equal(t, 2020, year)
+	assert.Equal(t, time.January, month)
+
+	assert.Equal(t, "Jan 31, 2020 10:30 pm EST", FormatDateTime(time.Date(2020, time.February, 1, 3, 30, 0, 0, time.UTC)))
+	assert.Equal(t, "Jan 31, 2020", FormatDate(time.Date(2020, time.February, 1, 3, 30, 0, 0, time.UTC)))
+}
+
+func TestMonthBoundsDefaultToUTC(t *testing.T) {
+	assert.Equal(t, time.Date(2020, time.February, 29, 23, 59, 59, 0, time.UTC), MonthEnd(2020, time.February))
+	assert.Equal(t, "Feb 29, 2020 11:59 pm UTC", FormatDateTime(MonthEnd(2020, time.February)))
+}